@@ -2,6 +2,7 @@ package main
 
 import (
 	"getherscan/pkg/api_server"
+	"getherscan/pkg/models"
 	"log"
 	"os"
 
@@ -13,6 +14,7 @@ func main() {
 	app.Name = "API Server"
 	app.Commands = []cli.Command{
 		api_server.ServeCommand,
+		models.MigrateCommand,
 	}
 
 	err := app.Run(os.Args)
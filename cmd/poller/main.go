@@ -1,6 +1,7 @@
 package main
 
 import (
+	"getherscan/pkg/models"
 	"getherscan/pkg/poller"
 	"log"
 	"os"
@@ -13,6 +14,8 @@ func main() {
 	app.Name = "Poller"
 	app.Commands = []cli.Command{
 		poller.PollCommand,
+		poller.BackfillCommand,
+		models.MigrateCommand,
 	}
 
 	err := app.Run(os.Args)
@@ -0,0 +1,201 @@
+package api_server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"getherscan/pkg/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+// Only lets requests carrying the admin token, as a bearer token in
+// their Authorization header, through to the given handler. Admin
+// endpoints are disabled when no admin token is configured
+func (apiServer *APIServer) RequireAdminToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if apiServer.AdminToken == "" {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusForbidden,
+				"Admin endpoints are disabled",
+			)
+			return
+		}
+
+		authorization := request.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		if !strings.HasPrefix(authorization, "Bearer ") || subtle.ConstantTimeCompare([]byte(token), []byte(apiServer.AdminToken)) != 1 {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusUnauthorized,
+				"Invalid admin token",
+			)
+			return
+		}
+
+		handler(writer, request)
+	}
+}
+
+func (apiServer *APIServer) HandleGetTrackedAddresses(writer http.ResponseWriter, request *http.Request) {
+	trackedAddresses, err := apiServer.Store.GetTrackedAddresses()
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		trackedAddresses,
+	)
+}
+
+func (apiServer *APIServer) HandleAddTrackedAddress(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	// Addresses are tracked in their checksummed form
+	address := common.HexToAddress(routeVars["address"]).Hex()
+
+	backfill := false
+	if request.URL.Query().Get("backfill") != "" {
+		var err error
+		backfill, err = strconv.ParseBool(request.URL.Query().Get("backfill"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				"Invalid backfill",
+			)
+			return
+		}
+	}
+
+	existingTrackedAddress, err := apiServer.Store.GetTrackedAddress(address)
+	if err == nil && existingTrackedAddress.RemovedAt == nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Address is already tracked",
+		)
+		return
+	}
+
+	if err != nil && !errors.Is(err, models.ErrRecordNotFound) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	// The poller picks the address up on its next update. Adding a
+	// removed address tracks it again
+	trackedAddress := &models.TrackedAddress{
+		Address:         address,
+		BackfillPending: backfill,
+	}
+
+	err = apiServer.Store.SaveTrackedAddress(trackedAddress)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		trackedAddress,
+	)
+}
+
+func (apiServer *APIServer) HandleRemoveTrackedAddress(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	address := common.HexToAddress(routeVars["address"]).Hex()
+
+	trackedAddress, err := apiServer.Store.GetTrackedAddress(address)
+	if errors.Is(err, models.ErrRecordNotFound) || (err == nil && trackedAddress.RemovedAt != nil) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusNotFound,
+			"Address isn't tracked",
+		)
+		return
+	}
+
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	// The address is kept as removed, so that the tracked addresses
+	// file doesn't track it again when the poller restarts. Balances
+	// already indexed for it are kept as well
+	removedAt := time.Now().UTC()
+	trackedAddress.RemovedAt = &removedAt
+	err = apiServer.Store.SaveTrackedAddress(trackedAddress)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		trackedAddress,
+	)
+}
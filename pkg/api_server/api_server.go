@@ -6,18 +6,24 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/mux"
 )
 
 type APIServer struct {
 	Server *http.Server
 	Router *mux.Router
-	DB     *models.DB
+	Store  models.Store
+	// Serves the JSON-RPC endpoint, from the same store
+	EthService *EthService
+	// Bearer token required by the admin endpoints, which are
+	// disabled if it's empty
+	AdminToken string
 }
 
 func (apiServer *APIServer) Initialize(dbConnectionString, port string) error {
-	apiServer.DB = new(models.DB)
-	err := apiServer.DB.Initialize(dbConnectionString)
+	var err error
+	apiServer.Store, err = models.OpenStore(dbConnectionString)
 	if err != nil {
 		return err
 	}
@@ -34,6 +40,16 @@ func (apiServer *APIServer) Initialize(dbConnectionString, port string) error {
 		apiServer.HandleGetHead,
 	).Methods("GET")
 
+	apiServer.Router.HandleFunc(
+		"/getFinalizedHead",
+		apiServer.HandleGetFinalizedHead,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getSafeHead",
+		apiServer.HandleGetSafeHead,
+	).Methods("GET")
+
 	apiServer.Router.HandleFunc(
 		"/getBlockByHash/{blockHash}",
 		apiServer.HandleGetBlockByHash,
@@ -54,11 +70,116 @@ func (apiServer *APIServer) Initialize(dbConnectionString, port string) error {
 		apiServer.HandleGetTransactionByHash,
 	).Methods("GET")
 
+	apiServer.Router.HandleFunc(
+		"/getTransactionsByAddress/{address}",
+		apiServer.HandleGetTransactionsByAddress,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getInternalTransactions/{transactionHash}",
+		apiServer.HandleGetInternalTransactions,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getInternalTransactionsByAddress/{address}",
+		apiServer.HandleGetInternalTransactionsByAddress,
+	).Methods("GET")
+
 	apiServer.Router.HandleFunc(
 		"/getAddressBalanceByBlockHash/{address}/{blockHash}",
 		apiServer.HandleGetAddressBalanceByBlockHash,
 	).Methods("GET")
 
+	apiServer.Router.HandleFunc(
+		"/getAddressBalance/{address}",
+		apiServer.HandleGetAddressBalance,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getAddressBalanceHistory/{address}",
+		apiServer.HandleGetAddressBalanceHistory,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getUnclesByBlockHash/{blockHash}",
+		apiServer.HandleGetUnclesByBlockHash,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getUncleByHash/{uncleHash}",
+		apiServer.HandleGetUncleByHash,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getWithdrawalsByBlockHash/{blockHash}",
+		apiServer.HandleGetWithdrawalsByBlockHash,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getWithdrawalsByAddress/{address}",
+		apiServer.HandleGetWithdrawalsByAddress,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getTransactionReceipt/{transactionHash}",
+		apiServer.HandleGetTransactionReceipt,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getLogsByBlockHash/{blockHash}",
+		apiServer.HandleGetLogsByBlockHash,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getLogs",
+		apiServer.HandleGetLogs,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getTokenTransfers/{address}",
+		apiServer.HandleGetTokenTransfers,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getTokenBalance/{token}/{holder}/{blockHash}",
+		apiServer.HandleGetTokenBalance,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getNFTOwners/{token}/{tokenId}/{blockHash}",
+		apiServer.HandleGetNFTOwners,
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/getNFTsByOwner/{address}/{blockHash}",
+		apiServer.HandleGetNFTsByOwner,
+	).Methods("GET")
+
+	// JSON-RPC endpoint answering eth_* methods from the index
+	rpcServer := rpc.NewServer()
+	apiServer.EthService = &EthService{Store: apiServer.Store}
+	err = rpcServer.RegisterName("eth", apiServer.EthService)
+	if err != nil {
+		return err
+	}
+
+	apiServer.Router.Handle("/rpc", rpcServer).Methods("POST")
+
+	apiServer.Router.HandleFunc(
+		"/getTrackedAddresses",
+		apiServer.RequireAdminToken(apiServer.HandleGetTrackedAddresses),
+	).Methods("GET")
+
+	apiServer.Router.HandleFunc(
+		"/addTrackedAddress/{address}",
+		apiServer.RequireAdminToken(apiServer.HandleAddTrackedAddress),
+	).Methods("POST")
+
+	apiServer.Router.HandleFunc(
+		"/removeTrackedAddress/{address}",
+		apiServer.RequireAdminToken(apiServer.HandleRemoveTrackedAddress),
+	).Methods("POST")
+
 	return nil
 }
 
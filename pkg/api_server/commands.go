@@ -19,6 +19,8 @@ func ServeAction(cliCtx *cli.Context) error {
 		return err
 	}
 
+	apiServer.AdminToken = cliCtx.String("admin-token")
+
 	go apiServer.Serve()
 
 	log.Printf("Listening on port %s\n", port)
@@ -37,4 +39,11 @@ var ServeCommand = cli.Command{
 	Usage:     "Listens for and serves query requests for the indexer on the provided port, using the provided PostgreSQL connection.",
 	ArgsUsage: "Provide a PostgreSQL connection string, and a port number.",
 	Action:    ServeAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:   "admin-token",
+			EnvVar: "GETHERSCAN_ADMIN_TOKEN",
+			Usage:  "Bearer token required by the endpoints managing tracked addresses, which are disabled if it isn't set.",
+		},
+	},
 }
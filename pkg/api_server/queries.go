@@ -1,7 +1,10 @@
 package api_server
 
 import (
+	"errors"
+	"fmt"
 	"getherscan/pkg/models"
+	"math/big"
 	"net/http"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -10,7 +13,7 @@ import (
 )
 
 func (apiServer *APIServer) HandleGetHead(writer http.ResponseWriter, request *http.Request) {
-	head, err := apiServer.DB.GetHead()
+	head, err := apiServer.Store.GetHead()
 	if err != nil {
 		RespondWithError(
 			request,
@@ -29,11 +32,51 @@ func (apiServer *APIServer) HandleGetHead(writer http.ResponseWriter, request *h
 	)
 }
 
+func (apiServer *APIServer) HandleGetFinalizedHead(writer http.ResponseWriter, request *http.Request) {
+	finalizedHead, err := apiServer.Store.GetFinalizedHead()
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		*finalizedHead,
+	)
+}
+
+func (apiServer *APIServer) HandleGetSafeHead(writer http.ResponseWriter, request *http.Request) {
+	safeHead, err := apiServer.Store.GetSafeHead()
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		*safeHead,
+	)
+}
+
 func (apiServer *APIServer) HandleGetBlockByHash(writer http.ResponseWriter, request *http.Request) {
 	routeVars := mux.Vars(request)
 	blockHash := routeVars["blockHash"]
 
-	block, err := apiServer.DB.GetBlockByHash(blockHash)
+	block, err := apiServer.Store.GetBlockByHash(blockHash)
 	if err != nil {
 		RespondWithError(
 			request,
@@ -66,7 +109,7 @@ func (apiServer *APIServer) HandleGetBlockByNumber(writer http.ResponseWriter, r
 		return
 	}
 
-	block, err := apiServer.DB.GetBlockByNumber(*blockNumber)
+	block, err := apiServer.Store.GetBlockByNumber(*blockNumber)
 	if err != nil {
 		RespondWithError(
 			request,
@@ -96,7 +139,7 @@ func (apiServer *APIServer) HandleGetBlocksByTransactionHash(writer http.Respons
 
 	payload := new(GetBlocksByTransactionHashPayload)
 
-	transaction, err := apiServer.DB.GetTransactionByHash(transactionHash, true)
+	transaction, err := apiServer.Store.GetTransactionByHash(transactionHash, true)
 	if err != nil {
 		RespondWithError(
 			request,
@@ -109,7 +152,7 @@ func (apiServer *APIServer) HandleGetBlocksByTransactionHash(writer http.Respons
 
 	payload.CanonicalBlock = transaction.Block
 
-	orphanedTransactions, err := apiServer.DB.GetOrphanedTransactionsByHash(transactionHash)
+	orphanedTransactions, err := apiServer.Store.GetOrphanedTransactionsByHash(transactionHash)
 	if err != nil {
 		RespondWithError(
 			request,
@@ -137,7 +180,7 @@ func (apiServer *APIServer) HandleGetTransactionByHash(writer http.ResponseWrite
 	routeVars := mux.Vars(request)
 	transactionHash := routeVars["transactionHash"]
 
-	transaction, err := apiServer.DB.GetTransactionByHash(transactionHash, false)
+	transaction, err := apiServer.Store.GetTransactionByHash(transactionHash, false)
 	if err != nil {
 		RespondWithError(
 			request,
@@ -168,10 +211,18 @@ func (apiServer *APIServer) HandleGetAddressBalanceByBlockHash(writer http.Respo
 		return
 	}
 
-	address := routeVars["address"]
+	// Balances are indexed in their checksummed form
+	address := common.HexToAddress(routeVars["address"]).Hex()
 	blockHash := routeVars["blockHash"]
 
-	balance, err := apiServer.DB.GetAddressBalanceByBlockHash(address, blockHash)
+	balance, err := apiServer.Store.GetAddressBalanceByBlockHash(address, blockHash)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		// The address isn't tracked, fall back on the balance
+		// deltas, which cover every address if the poller
+		// indexes them
+		balance, err = apiServer.getAddressBalanceFromDeltas(address, blockHash)
+	}
+
 	if err != nil {
 		RespondWithError(
 			request,
@@ -179,6 +230,7 @@ func (apiServer *APIServer) HandleGetAddressBalanceByBlockHash(writer http.Respo
 			http.StatusBadRequest,
 			err.Error(),
 		)
+		return
 	}
 
 	RespondWithJSON(
@@ -188,3 +240,1487 @@ func (apiServer *APIServer) HandleGetAddressBalanceByBlockHash(writer http.Respo
 		balance,
 	)
 }
+
+// Computes the address's balance at the canonical block with
+// blockHash from its balance deltas
+func (apiServer *APIServer) getAddressBalanceFromDeltas(address, blockHash string) (*models.Balance, error) {
+	block, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	balanceDelta, err := apiServer.Store.GetLatestAddressBalanceDelta(address, block.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Balance{
+		Address:     address,
+		BlockHash:   blockHash,
+		BlockNumber: block.Number,
+		Balance:     balanceDelta.Balance,
+	}, nil
+}
+
+// Resolves a block parameter, either a block number, a block hash,
+// "latest" (the default), "safe" or "finalized", to the canonical block
+// it refers to
+func (apiServer *APIServer) resolveBlock(blockParam string) (*models.Block, error) {
+	switch blockParam {
+	case "", "latest":
+		return apiServer.Store.GetHead()
+	case "safe":
+		return apiServer.Store.GetSafeHead()
+	case "finalized":
+		return apiServer.Store.GetFinalizedHead()
+	}
+
+	if IsHexHash(blockParam) {
+		return apiServer.Store.GetBlockByHash(blockParam)
+	}
+
+	blockNumber, err := ParseBlockNumber(blockParam)
+	if err != nil {
+		return nil, err
+	}
+
+	numeric, err := BigIntToNumeric(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return apiServer.Store.GetBlockByNumber(*numeric)
+}
+
+type GetAddressBalancePayload struct {
+	Address string
+	// Canonical block the balance was requested at
+	BlockHash   string
+	BlockNumber pgtype.Numeric
+	Balance     pgtype.Numeric
+	// Block the balance is known from, the requested block or the
+	// last one before it with a balance for the address
+	BalanceBlockHash   string
+	BalanceBlockNumber pgtype.Numeric
+}
+
+func (apiServer *APIServer) HandleGetAddressBalance(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	// Balances are indexed in their checksummed form
+	address := common.HexToAddress(routeVars["address"]).Hex()
+
+	block, err := apiServer.resolveBlock(request.URL.Query().Get("block"))
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	balance, err := getLatestAddressBalance(apiServer.Store, address, block.Number)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		GetAddressBalancePayload{
+			Address:            address,
+			BlockHash:          block.Hash,
+			BlockNumber:        block.Number,
+			Balance:            balance.Balance,
+			BalanceBlockHash:   balance.BlockHash,
+			BalanceBlockNumber: balance.BlockNumber,
+		},
+	)
+}
+
+// Fetches the address's most recent balance at or before the canonical
+// block with blockNumber, either the last tracked balance (carried
+// forward over the blocks indexed while the address wasn't tracked) or
+// the balance after the last balance delta, whichever is from the later
+// block. Tracked balances win ties, as they include block rewards
+func getLatestAddressBalance(store models.Store, address string, blockNumber pgtype.Numeric) (*models.Balance, error) {
+	balance, err := store.GetLatestAddressBalance(address, blockNumber)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		balance = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	balanceDelta, err := store.GetLatestAddressBalanceDelta(address, blockNumber)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		if balance == nil {
+			return nil, err
+		}
+
+		return balance, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if balance != nil && models.NumericToBigInt(balance.BlockNumber).Cmp(models.NumericToBigInt(balanceDelta.BlockNumber)) >= 0 {
+		return balance, nil
+	}
+
+	return &models.Balance{
+		Address:     address,
+		BlockHash:   balanceDelta.BlockHash,
+		BlockNumber: balanceDelta.BlockNumber,
+		Balance:     balanceDelta.Balance,
+	}, nil
+}
+
+const (
+	DefaultBalancesLimit = 1000
+	MaxBalancesLimit     = 10000
+)
+
+type GetAddressBalanceHistoryPayload struct {
+	// On the first page, the last balance before fromBlock, so that
+	// the series starts at fromBlock. Nil if there is none
+	StartingBalance *models.Balance
+	Balances        []models.Balance
+	// Pass as the cursor query parameter to fetch the next page of
+	// balances, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetAddressBalanceHistory(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultBalancesLimit, MaxBalancesLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.BalanceFilter{
+		Address: common.HexToAddress(routeVars["address"]).Hex(),
+		// Fetch one extra balance to know whether there is a
+		// next page
+		Limit: limit + 1,
+	}
+
+	var fromBlock, toBlock *big.Int
+	if query.Get("fromBlock") != "" {
+		fromBlock, err = ParseBlockNumber(query.Get("fromBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.FromBlock, err = BigIntToNumeric(fromBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if query.Get("toBlock") != "" {
+		toBlock, err = ParseBlockNumber(query.Get("toBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.ToBlock, err = BigIntToNumeric(toBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if fromBlock != nil && toBlock != nil && fromBlock.Cmp(toBlock) > 0 {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"fromBlock is after toBlock",
+		)
+		return
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	var startingBalance *models.Balance
+	if filter.After == nil && fromBlock != nil && fromBlock.Sign() > 0 {
+		beforeFromBlock, err := BigIntToNumeric(new(big.Int).Sub(fromBlock, big.NewInt(1)))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		startingBalance, err = apiServer.Store.GetLatestAddressBalance(filter.Address, *beforeFromBlock)
+		if errors.Is(err, models.ErrRecordNotFound) {
+			startingBalance, err = nil, nil
+		}
+
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusInternalServerError,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	balances, err := apiServer.Store.GetAddressBalances(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetAddressBalanceHistoryPayload{
+		StartingBalance: startingBalance,
+		Balances:        balances,
+	}
+	if payload.Balances == nil {
+		payload.Balances = []models.Balance{}
+	}
+
+	if len(balances) > limit {
+		payload.Balances = balances[:limit]
+		payload.NextCursor = FormatCursor(payload.Balances[limit-1].BlockNumber, 0)
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
+
+func (apiServer *APIServer) HandleGetTransactionReceipt(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	transactionHash := routeVars["transactionHash"]
+
+	receipt, err := apiServer.Store.GetReceiptByTransactionHash(transactionHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		receipt,
+	)
+}
+
+func (apiServer *APIServer) HandleGetLogsByBlockHash(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	blockHash := routeVars["blockHash"]
+
+	// Optionally filter by (any number of) emitting addresses
+	addresses := request.URL.Query()["address"]
+	for i, address := range addresses {
+		if !common.IsHexAddress(address) {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				"Invalid address",
+			)
+			return
+		}
+
+		// Addresses are indexed in their checksummed form
+		addresses[i] = common.HexToAddress(address).Hex()
+	}
+
+	logs, err := apiServer.Store.GetLogsForBlockHash(blockHash, addresses)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		logs,
+	)
+}
+
+const (
+	DefaultLogsLimit = 100
+	MaxLogsLimit     = 1000
+)
+
+type GetLogsPayload struct {
+	Logs []models.Log
+	// Pass as the cursor query parameter to fetch the next page of
+	// logs, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetLogs(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultLogsLimit, MaxLogsLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	// Like eth_getLogs, the block range defaults to the head
+	var toBlock *big.Int
+	if query.Get("toBlock") != "" {
+		toBlock, err = ParseBlockNumber(query.Get("toBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	} else {
+		head, err := apiServer.Store.GetHead()
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusInternalServerError,
+				err.Error(),
+			)
+			return
+		}
+
+		toBlock = models.NumericToBigInt(head.Number)
+	}
+
+	fromBlock := toBlock
+	if query.Get("fromBlock") != "" {
+		fromBlock, err = ParseBlockNumber(query.Get("fromBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if fromBlock.Cmp(toBlock) > 0 {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"fromBlock is after toBlock",
+		)
+		return
+	}
+
+	fromBlockNumber, err := BigIntToNumeric(fromBlock)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	toBlockNumber, err := BigIntToNumeric(toBlock)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.LogFilter{
+		FromBlock: *fromBlockNumber,
+		ToBlock:   *toBlockNumber,
+		// Fetch one extra log to know whether there is a next
+		// page
+		Limit: limit + 1,
+	}
+
+	filter.Addresses = SplitQueryValues(query["address"])
+	for i, address := range filter.Addresses {
+		if !common.IsHexAddress(address) {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				"Invalid address",
+			)
+			return
+		}
+
+		// Addresses are indexed in their checksummed form
+		filter.Addresses[i] = common.HexToAddress(address).Hex()
+	}
+
+	// Each topic position matches any of its (comma-separated)
+	// values
+	for i := range filter.Topics {
+		filter.Topics[i] = SplitQueryValues(query[fmt.Sprintf("topic%d", i)])
+		for j, topic := range filter.Topics[i] {
+			if !IsHexHash(topic) {
+				RespondWithError(
+					request,
+					writer,
+					http.StatusBadRequest,
+					"Invalid topic",
+				)
+				return
+			}
+
+			filter.Topics[i][j] = common.HexToHash(topic).Hex()
+		}
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	logs, err := apiServer.Store.GetLogs(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetLogsPayload{Logs: logs}
+	if payload.Logs == nil {
+		payload.Logs = []models.Log{}
+	}
+
+	if len(logs) > limit {
+		payload.Logs = logs[:limit]
+		lastLog := payload.Logs[limit-1]
+		payload.NextCursor = FormatCursor(lastLog.BlockNumber, lastLog.LogIndex)
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
+
+const (
+	DefaultTokenTransfersLimit = 100
+	MaxTokenTransfersLimit     = 1000
+)
+
+type GetTokenTransfersPayload struct {
+	TokenTransfers []models.TokenTransfer
+	// Pass as the cursor query parameter to fetch the next page of
+	// transfers, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetTokenTransfers(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultTokenTransfersLimit, MaxTokenTransfersLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.TokenTransferFilter{
+		Address: common.HexToAddress(routeVars["address"]).Hex(),
+		// Fetch one extra transfer to know whether there is a
+		// next page
+		Limit: limit + 1,
+	}
+
+	// Optionally only fetch transfers of a given token
+	if query.Get("token") != "" {
+		if !common.IsHexAddress(query.Get("token")) {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				"Invalid token",
+			)
+			return
+		}
+
+		filter.Token = common.HexToAddress(query.Get("token")).Hex()
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	tokenTransfers, err := apiServer.Store.GetTokenTransfers(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetTokenTransfersPayload{TokenTransfers: tokenTransfers}
+	if payload.TokenTransfers == nil {
+		payload.TokenTransfers = []models.TokenTransfer{}
+	}
+
+	if len(tokenTransfers) > limit {
+		payload.TokenTransfers = tokenTransfers[:limit]
+		lastTokenTransfer := payload.TokenTransfers[limit-1]
+		payload.NextCursor = FormatCursor(lastTokenTransfer.BlockNumber, lastTokenTransfer.LogIndex)
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
+
+type GetTokenBalancePayload struct {
+	Token     string
+	Holder    string
+	BlockHash string
+	Balance   pgtype.Numeric
+}
+
+func (apiServer *APIServer) HandleGetTokenBalance(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["token"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid token",
+		)
+		return
+	}
+
+	if !common.IsHexAddress(routeVars["holder"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid holder",
+		)
+		return
+	}
+
+	token := common.HexToAddress(routeVars["token"]).Hex()
+	holder := common.HexToAddress(routeVars["holder"]).Hex()
+	blockHash := routeVars["blockHash"]
+
+	// Balances are only kept for canonical blocks
+	block, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	balance, err := apiServer.Store.GetTokenBalance(token, holder, block.Number)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	balanceNumeric, err := BigIntToNumeric(balance)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		GetTokenBalancePayload{
+			Token:     token,
+			Holder:    holder,
+			BlockHash: blockHash,
+			Balance:   *balanceNumeric,
+		},
+	)
+}
+
+func (apiServer *APIServer) HandleGetNFTOwners(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["token"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid token",
+		)
+		return
+	}
+
+	token := common.HexToAddress(routeVars["token"]).Hex()
+	blockHash := routeVars["blockHash"]
+
+	tokenID, err := ParseUint256(routeVars["tokenId"])
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid token ID",
+		)
+		return
+	}
+
+	tokenIDNumeric, err := BigIntToNumeric(tokenID)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	// Ownership is only kept for canonical blocks
+	block, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	nftHoldings, err := apiServer.Store.GetNFTHoldingsForToken(token, *tokenIDNumeric, block.Number)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		nftHoldings,
+	)
+}
+
+func (apiServer *APIServer) HandleGetNFTsByOwner(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	address := common.HexToAddress(routeVars["address"]).Hex()
+	blockHash := routeVars["blockHash"]
+
+	// Ownership is only kept for canonical blocks
+	block, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	nftHoldings, err := apiServer.Store.GetNFTHoldingsForHolder(address, block.Number)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		nftHoldings,
+	)
+}
+
+const (
+	DefaultTransactionsLimit = 100
+	MaxTransactionsLimit     = 1000
+)
+
+type GetTransactionsByAddressPayload struct {
+	Transactions []models.Transaction
+	// Pass as the cursor query parameter to fetch the next page of
+	// transactions, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetTransactionsByAddress(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultTransactionsLimit, MaxTransactionsLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.TransactionFilter{
+		Address:   common.HexToAddress(routeVars["address"]).Hex(),
+		Direction: models.TransactionDirectionBoth,
+		// Fetch one extra transaction to know whether there is
+		// a next page
+		Limit: limit + 1,
+	}
+
+	switch query.Get("direction") {
+	case "", models.TransactionDirectionBoth:
+	case models.TransactionDirectionIn, models.TransactionDirectionOut:
+		filter.Direction = query.Get("direction")
+	default:
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid direction",
+		)
+		return
+	}
+
+	var fromBlock, toBlock *big.Int
+	if query.Get("fromBlock") != "" {
+		fromBlock, err = ParseBlockNumber(query.Get("fromBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.FromBlock, err = BigIntToNumeric(fromBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if query.Get("toBlock") != "" {
+		toBlock, err = ParseBlockNumber(query.Get("toBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.ToBlock, err = BigIntToNumeric(toBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if fromBlock != nil && toBlock != nil && fromBlock.Cmp(toBlock) > 0 {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"fromBlock is after toBlock",
+		)
+		return
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	transactions, err := apiServer.Store.GetTransactionsByAddress(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetTransactionsByAddressPayload{Transactions: transactions}
+	if payload.Transactions == nil {
+		payload.Transactions = []models.Transaction{}
+	}
+
+	if len(transactions) > limit {
+		payload.Transactions = transactions[:limit]
+		lastTransaction := payload.Transactions[limit-1]
+		payload.NextCursor = FormatCursor(lastTransaction.BlockNumber, lastTransaction.TransactionIndex)
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
+
+func (apiServer *APIServer) HandleGetWithdrawalsByBlockHash(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	blockHash := routeVars["blockHash"]
+
+	// Make sure the block is canonical, rather than answering with
+	// no withdrawals
+	_, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	withdrawals, err := apiServer.Store.GetWithdrawalsForBlockHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	if withdrawals == nil {
+		withdrawals = []models.Withdrawal{}
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		withdrawals,
+	)
+}
+
+const (
+	DefaultWithdrawalsLimit = 100
+	MaxWithdrawalsLimit     = 1000
+)
+
+type GetWithdrawalsByAddressPayload struct {
+	Withdrawals []models.Withdrawal
+	// Pass as the cursor query parameter to fetch the next page of
+	// withdrawals, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetWithdrawalsByAddress(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultWithdrawalsLimit, MaxWithdrawalsLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.WithdrawalFilter{
+		Address: common.HexToAddress(routeVars["address"]).Hex(),
+		// Fetch one extra withdrawal to know whether there is a
+		// next page
+		Limit: limit + 1,
+	}
+
+	var fromBlock, toBlock *big.Int
+	if query.Get("fromBlock") != "" {
+		fromBlock, err = ParseBlockNumber(query.Get("fromBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.FromBlock, err = BigIntToNumeric(fromBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if query.Get("toBlock") != "" {
+		toBlock, err = ParseBlockNumber(query.Get("toBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.ToBlock, err = BigIntToNumeric(toBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if fromBlock != nil && toBlock != nil && fromBlock.Cmp(toBlock) > 0 {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"fromBlock is after toBlock",
+		)
+		return
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	withdrawals, err := apiServer.Store.GetWithdrawalsByAddress(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetWithdrawalsByAddressPayload{Withdrawals: withdrawals}
+	if payload.Withdrawals == nil {
+		payload.Withdrawals = []models.Withdrawal{}
+	}
+
+	if len(withdrawals) > limit {
+		payload.Withdrawals = withdrawals[:limit]
+		lastWithdrawal := payload.Withdrawals[limit-1]
+		payload.NextCursor = FormatCursor(lastWithdrawal.BlockNumber, uint(lastWithdrawal.WithdrawalIndex))
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
+
+func (apiServer *APIServer) HandleGetUnclesByBlockHash(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	blockHash := routeVars["blockHash"]
+
+	// Make sure the block is canonical, rather than answering with
+	// no uncles
+	_, err := apiServer.Store.GetBlockByHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	uncles, err := apiServer.Store.GetUnclesForBlockHash(blockHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	if uncles == nil {
+		uncles = []models.Uncle{}
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		uncles,
+	)
+}
+
+func (apiServer *APIServer) HandleGetUncleByHash(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	uncleHash := routeVars["uncleHash"]
+
+	uncle, err := apiServer.Store.GetUncleByHash(uncleHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		uncle,
+	)
+}
+
+func (apiServer *APIServer) HandleGetInternalTransactions(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	transactionHash := routeVars["transactionHash"]
+
+	// Make sure the transaction is canonical, rather than
+	// answering with no internal transactions
+	_, err := apiServer.Store.GetTransactionByHash(transactionHash, false)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	internalTransactions, err := apiServer.Store.GetInternalTransactionsByTransactionHash(transactionHash)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	if internalTransactions == nil {
+		internalTransactions = []models.InternalTransaction{}
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		internalTransactions,
+	)
+}
+
+type GetInternalTransactionsByAddressPayload struct {
+	InternalTransactions []models.InternalTransaction
+	// Pass as the cursor query parameter to fetch the next page of
+	// internal transactions, empty if there are none left
+	NextCursor string
+}
+
+func (apiServer *APIServer) HandleGetInternalTransactionsByAddress(writer http.ResponseWriter, request *http.Request) {
+	routeVars := mux.Vars(request)
+	if !common.IsHexAddress(routeVars["address"]) {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid address",
+		)
+		return
+	}
+
+	query := request.URL.Query()
+
+	limit, err := ParseLimit(query, DefaultTransactionsLimit, MaxTransactionsLimit)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			err.Error(),
+		)
+		return
+	}
+
+	filter := models.TransactionFilter{
+		Address:   common.HexToAddress(routeVars["address"]).Hex(),
+		Direction: models.TransactionDirectionBoth,
+		// Fetch one extra internal transaction to know whether
+		// there is a next page
+		Limit: limit + 1,
+	}
+
+	switch query.Get("direction") {
+	case "", models.TransactionDirectionBoth:
+	case models.TransactionDirectionIn, models.TransactionDirectionOut:
+		filter.Direction = query.Get("direction")
+	default:
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"Invalid direction",
+		)
+		return
+	}
+
+	var fromBlock, toBlock *big.Int
+	if query.Get("fromBlock") != "" {
+		fromBlock, err = ParseBlockNumber(query.Get("fromBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.FromBlock, err = BigIntToNumeric(fromBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if query.Get("toBlock") != "" {
+		toBlock, err = ParseBlockNumber(query.Get("toBlock"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+
+		filter.ToBlock, err = BigIntToNumeric(toBlock)
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	if fromBlock != nil && toBlock != nil && fromBlock.Cmp(toBlock) > 0 {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusBadRequest,
+			"fromBlock is after toBlock",
+		)
+		return
+	}
+
+	if query.Get("cursor") != "" {
+		filter.After, err = ParseCursor(query.Get("cursor"))
+		if err != nil {
+			RespondWithError(
+				request,
+				writer,
+				http.StatusBadRequest,
+				err.Error(),
+			)
+			return
+		}
+	}
+
+	internalTransactions, err := apiServer.Store.GetInternalTransactionsByAddress(filter)
+	if err != nil {
+		RespondWithError(
+			request,
+			writer,
+			http.StatusInternalServerError,
+			err.Error(),
+		)
+		return
+	}
+
+	payload := GetInternalTransactionsByAddressPayload{InternalTransactions: internalTransactions}
+	if payload.InternalTransactions == nil {
+		payload.InternalTransactions = []models.InternalTransaction{}
+	}
+
+	if len(internalTransactions) > limit {
+		payload.InternalTransactions = internalTransactions[:limit]
+		lastInternalTransaction := payload.InternalTransactions[limit-1]
+		payload.NextCursor = FormatCursor(lastInternalTransaction.BlockNumber, lastInternalTransaction.TraceIndex)
+	}
+
+	RespondWithJSON(
+		request,
+		writer,
+		http.StatusOK,
+		payload,
+	)
+}
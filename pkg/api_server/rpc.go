@@ -0,0 +1,432 @@
+package api_server
+
+import (
+	"errors"
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc/eip4844"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jackc/pgtype"
+)
+
+// Serves the eth_* JSON-RPC methods that can be answered from the
+// indexed canonical chain, encoded the way geth encodes them, so that
+// the indexer can sit in front of clients as a cache. As with geth,
+// unknown blocks, transactions and receipts are null
+type EthService struct {
+	Store models.Store
+}
+
+// Transaction as encoded by geth, with the block it's included in
+type RPCTransaction struct {
+	BlockHash           *common.Hash      `json:"blockHash"`
+	BlockNumber         *hexutil.Big      `json:"blockNumber"`
+	From                common.Address    `json:"from"`
+	Gas                 hexutil.Uint64    `json:"gas"`
+	GasPrice            *hexutil.Big      `json:"gasPrice"`
+	GasFeeCap           *hexutil.Big      `json:"maxFeePerGas,omitempty"`
+	GasTipCap           *hexutil.Big      `json:"maxPriorityFeePerGas,omitempty"`
+	MaxFeePerBlobGas    *hexutil.Big      `json:"maxFeePerBlobGas,omitempty"`
+	Hash                common.Hash       `json:"hash"`
+	Input               hexutil.Bytes     `json:"input"`
+	Nonce               hexutil.Uint64    `json:"nonce"`
+	To                  *common.Address   `json:"to"`
+	TransactionIndex    *hexutil.Uint64   `json:"transactionIndex"`
+	Value               *hexutil.Big      `json:"value"`
+	Type                hexutil.Uint64    `json:"type"`
+	Accesses            *types.AccessList `json:"accessList,omitempty"`
+	ChainID             *hexutil.Big      `json:"chainId,omitempty"`
+	BlobVersionedHashes []common.Hash     `json:"blobVersionedHashes,omitempty"`
+	V                   *hexutil.Big      `json:"v"`
+	R                   *hexutil.Big      `json:"r"`
+	S                   *hexutil.Big      `json:"s"`
+	YParity             *hexutil.Uint64   `json:"yParity,omitempty"`
+}
+
+func (service *EthService) BlockNumber() (hexutil.Uint64, error) {
+	head, err := service.Store.GetHead()
+	if err != nil {
+		return 0, err
+	}
+
+	return hexutil.Uint64(models.NumericToBigInt(head.Number).Uint64()), nil
+}
+
+func (service *EthService) GetBlockByHash(blockHash common.Hash, fullTransactions bool) (map[string]interface{}, error) {
+	block, err := service.Store.GetBlockByHash(blockHash.Hex())
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return service.marshalBlock(block, fullTransactions)
+}
+
+func (service *EthService) GetBlockByNumber(blockNumber rpc.BlockNumber, fullTransactions bool) (map[string]interface{}, error) {
+	block, err := service.blockByNumber(blockNumber)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return service.marshalBlock(block, fullTransactions)
+}
+
+func (service *EthService) GetTransactionByHash(transactionHash common.Hash) (*RPCTransaction, error) {
+	transaction, err := service.Store.GetTransactionByHash(transactionHash.Hex(), true)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newRPCTransaction(transaction, &transaction.Block), nil
+}
+
+// Answers with the address's most recent balance at or before the
+// block, as /getAddressBalance does. Only answers for the balances the
+// index has: those of addresses tracked at some point up to the
+// block, or of any address if the poller indexes balance deltas
+func (service *EthService) GetBalance(address common.Address, blockNumberOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	var block *models.Block
+	var err error
+	if blockHash, ok := blockNumberOrHash.Hash(); ok {
+		block, err = service.Store.GetBlockByHash(blockHash.Hex())
+	} else if blockNumber, ok := blockNumberOrHash.Number(); ok {
+		block, err = service.blockByNumber(blockNumber)
+	} else {
+		return nil, errors.New("Invalid block number or hash")
+	}
+
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, fmt.Errorf("Block %s not found", blockNumberOrHash.String())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Balances are indexed in their checksummed form
+	balance, err := getLatestAddressBalance(service.Store, address.Hex(), block.Number)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, fmt.Errorf("Balance of %s at block %s is not indexed", address.Hex(), block.Hash)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return (*hexutil.Big)(models.NumericToBigInt(balance.Balance)), nil
+}
+
+func (service *EthService) GetTransactionReceipt(transactionHash common.Hash) (map[string]interface{}, error) {
+	receipt, err := service.Store.GetReceiptByTransactionHash(transactionHash.Hex())
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := service.Store.GetTransactionByHash(transactionHash.Hex(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalReceipt(receipt, transaction), nil
+}
+
+// Resolves a block number or tag to the canonical block it refers to.
+// The index has no pending block, so "pending" is the head
+func (service *EthService) blockByNumber(blockNumber rpc.BlockNumber) (*models.Block, error) {
+	switch blockNumber {
+	case rpc.LatestBlockNumber, rpc.PendingBlockNumber:
+		return service.Store.GetHead()
+	case rpc.SafeBlockNumber:
+		return service.Store.GetSafeHead()
+	case rpc.FinalizedBlockNumber:
+		return service.Store.GetFinalizedHead()
+	}
+
+	numeric, err := BigIntToNumeric(big.NewInt(blockNumber.Int64()))
+	if err != nil {
+		return nil, err
+	}
+
+	return service.Store.GetBlockByNumber(*numeric)
+}
+
+func numericToHexBig(numeric pgtype.Numeric) *hexutil.Big {
+	return (*hexutil.Big)(models.NumericToBigInt(numeric))
+}
+
+// Encodes a block the way geth's eth_getBlockByHash does. The total
+// difficulty isn't known, as the index doesn't start at genesis, so
+// it's left out
+func (service *EthService) marshalBlock(block *models.Block, fullTransactions bool) (map[string]interface{}, error) {
+	fields := map[string]interface{}{
+		"number":           numericToHexBig(block.Number),
+		"hash":             common.HexToHash(block.Hash),
+		"parentHash":       common.HexToHash(block.ParentHash),
+		"nonce":            types.EncodeNonce(models.NumericToBigInt(block.Nonce).Uint64()),
+		"mixHash":          common.HexToHash(block.MixDigest),
+		"sha3Uncles":       common.HexToHash(block.UncleHash),
+		"logsBloom":        types.BytesToBloom(block.Bloom),
+		"stateRoot":        common.HexToHash(block.Root),
+		"miner":            common.HexToAddress(block.Coinbase),
+		"difficulty":       numericToHexBig(block.Difficulty),
+		"extraData":        hexutil.Bytes(block.Extra),
+		"size":             hexutil.Uint64(block.Size),
+		"gasLimit":         hexutil.Uint64(block.GasLimit),
+		"gasUsed":          hexutil.Uint64(block.GasUsed),
+		"timestamp":        hexutil.Uint64(block.Time),
+		"transactionsRoot": common.HexToHash(block.TxHash),
+		"receiptsRoot":     common.HexToHash(block.ReceiptHash),
+	}
+
+	if block.BaseFee != nil {
+		fields["baseFeePerGas"] = numericToHexBig(*block.BaseFee)
+	}
+
+	if block.WithdrawalsRoot != "" {
+		fields["withdrawalsRoot"] = common.HexToHash(block.WithdrawalsRoot)
+	}
+
+	if block.BlobGasUsed != nil {
+		fields["blobGasUsed"] = hexutil.Uint64(*block.BlobGasUsed)
+	}
+
+	if block.ExcessBlobGas != nil {
+		fields["excessBlobGas"] = hexutil.Uint64(*block.ExcessBlobGas)
+	}
+
+	transactions, err := service.Store.GetTransactionsForBlockHash(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		return transactions[i].TransactionIndex < transactions[j].TransactionIndex
+	})
+
+	rpcTransactions := make([]interface{}, len(transactions))
+	for i := range transactions {
+		if fullTransactions {
+			rpcTransactions[i] = newRPCTransaction(&transactions[i], block)
+		} else {
+			rpcTransactions[i] = common.HexToHash(transactions[i].Hash)
+		}
+	}
+
+	fields["transactions"] = rpcTransactions
+
+	uncles, err := service.Store.GetUnclesForBlockHash(block.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	uncleHashes := make([]common.Hash, len(uncles))
+	for i, uncle := range uncles {
+		uncleHashes[i] = common.HexToHash(uncle.Hash)
+	}
+
+	fields["uncles"] = uncleHashes
+
+	if block.WithdrawalsRoot != "" {
+		withdrawals, err := service.Store.GetWithdrawalsForBlockHash(block.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		rpcWithdrawals := make(types.Withdrawals, len(withdrawals))
+		for i, withdrawal := range withdrawals {
+			rpcWithdrawals[i] = &types.Withdrawal{
+				Index:     withdrawal.WithdrawalIndex,
+				Validator: withdrawal.ValidatorIndex,
+				Address:   common.HexToAddress(withdrawal.Address),
+				Amount:    withdrawal.Amount,
+			}
+		}
+
+		fields["withdrawals"] = rpcWithdrawals
+	}
+
+	return fields, nil
+}
+
+// Encodes a transaction included in the given block the way geth's
+// eth_getTransactionByHash does
+func newRPCTransaction(transaction *models.Transaction, block *models.Block) *RPCTransaction {
+	blockHash := common.HexToHash(block.Hash)
+	transactionIndex := hexutil.Uint64(transaction.TransactionIndex)
+
+	rpcTransaction := &RPCTransaction{
+		BlockHash:        &blockHash,
+		BlockNumber:      numericToHexBig(block.Number),
+		From:             common.HexToAddress(transaction.From),
+		Gas:              hexutil.Uint64(transaction.Gas),
+		GasPrice:         numericToHexBig(transaction.GasPrice),
+		Hash:             common.HexToHash(transaction.Hash),
+		Input:            hexutil.Bytes(transaction.Data),
+		Nonce:            hexutil.Uint64(models.NumericToBigInt(transaction.Nonce).Uint64()),
+		TransactionIndex: &transactionIndex,
+		Value:            numericToHexBig(transaction.Value),
+		Type:             hexutil.Uint64(transaction.Type),
+		V:                numericToHexBig(transaction.V),
+		R:                numericToHexBig(transaction.R),
+		S:                numericToHexBig(transaction.S),
+	}
+
+	// Empty for contract creations
+	if transaction.To != "" {
+		to := common.HexToAddress(transaction.To)
+		rpcTransaction.To = &to
+	}
+
+	chainID := models.NumericToBigInt(transaction.ChainID)
+
+	if transaction.Type == types.LegacyTxType {
+		// Only EIP-155 transactions have a chain ID
+		if chainID.Sign() != 0 {
+			rpcTransaction.ChainID = (*hexutil.Big)(chainID)
+		}
+
+		return rpcTransaction
+	}
+
+	accessList := make(types.AccessList, len(transaction.AccessList))
+	for i, accessTuple := range transaction.AccessList {
+		storageKeys := make([]common.Hash, len(accessTuple.StorageKeys))
+		for j, storageKey := range accessTuple.StorageKeys {
+			storageKeys[j] = common.HexToHash(storageKey)
+		}
+
+		accessList[i] = types.AccessTuple{
+			Address:     common.HexToAddress(accessTuple.Address),
+			StorageKeys: storageKeys,
+		}
+	}
+
+	yParity := hexutil.Uint64(models.NumericToBigInt(transaction.V).Sign())
+	rpcTransaction.Accesses = &accessList
+	rpcTransaction.ChainID = (*hexutil.Big)(chainID)
+	rpcTransaction.YParity = &yParity
+
+	if transaction.Type == types.AccessListTxType {
+		return rpcTransaction
+	}
+
+	// Since EIP-1559, the gas price is the price actually paid:
+	// min(gasTipCap + baseFee, gasFeeCap)
+	gasFeeCap := models.NumericToBigInt(transaction.GasFeeCap)
+	gasTipCap := models.NumericToBigInt(transaction.GasTipCap)
+	rpcTransaction.GasFeeCap = (*hexutil.Big)(gasFeeCap)
+	rpcTransaction.GasTipCap = (*hexutil.Big)(gasTipCap)
+
+	gasPrice := gasFeeCap
+	if block.BaseFee != nil {
+		gasPrice = new(big.Int).Add(gasTipCap, models.NumericToBigInt(*block.BaseFee))
+		if gasPrice.Cmp(gasFeeCap) > 0 {
+			gasPrice = gasFeeCap
+		}
+	}
+
+	rpcTransaction.GasPrice = (*hexutil.Big)(gasPrice)
+
+	if transaction.BlobGasFeeCap != nil {
+		rpcTransaction.MaxFeePerBlobGas = numericToHexBig(*transaction.BlobGasFeeCap)
+
+		blobHashes := make([]common.Hash, len(transaction.BlobHashes))
+		for i, blobHash := range transaction.BlobHashes {
+			blobHashes[i] = common.HexToHash(blobHash)
+		}
+
+		rpcTransaction.BlobVersionedHashes = blobHashes
+	}
+
+	return rpcTransaction
+}
+
+// Encodes a receipt the way geth's eth_getTransactionReceipt does.
+// The transaction must have been fetched along with its block
+func marshalReceipt(receipt *models.Receipt, transaction *models.Transaction) map[string]interface{} {
+	blockNumber := models.NumericToBigInt(transaction.Block.Number).Uint64()
+
+	logs := make([]*types.Log, len(receipt.Logs))
+	for i, log := range receipt.Logs {
+		topics := make([]common.Hash, len(log.Topics()))
+		for j, topic := range log.Topics() {
+			topics[j] = common.HexToHash(topic)
+		}
+
+		logs[i] = &types.Log{
+			Address:     common.HexToAddress(log.Address),
+			Topics:      topics,
+			Data:        log.Data,
+			BlockNumber: blockNumber,
+			TxHash:      common.HexToHash(log.TransactionHash),
+			TxIndex:     log.TransactionIndex,
+			BlockHash:   common.HexToHash(log.BlockHash),
+			Index:       log.LogIndex,
+		}
+	}
+
+	var to *common.Address
+	if transaction.To != "" {
+		address := common.HexToAddress(transaction.To)
+		to = &address
+	}
+
+	fields := map[string]interface{}{
+		"blockHash":         common.HexToHash(receipt.BlockHash),
+		"blockNumber":       hexutil.Uint64(blockNumber),
+		"transactionHash":   common.HexToHash(receipt.TransactionHash),
+		"transactionIndex":  hexutil.Uint64(receipt.TransactionIndex),
+		"from":              common.HexToAddress(transaction.From),
+		"to":                to,
+		"gasUsed":           hexutil.Uint64(receipt.GasUsed),
+		"cumulativeGasUsed": hexutil.Uint64(receipt.CumulativeGasUsed),
+		"contractAddress":   nil,
+		"logs":              logs,
+		"logsBloom":         types.BytesToBloom(receipt.Bloom),
+		"type":              hexutil.Uint(receipt.Type),
+		"effectiveGasPrice": numericToHexBig(receipt.EffectiveGasPrice),
+	}
+
+	// Receipts from before Byzantium have a post-transaction state
+	// root instead of a status
+	if len(receipt.PostState) > 0 {
+		fields["root"] = hexutil.Bytes(receipt.PostState)
+	} else {
+		fields["status"] = hexutil.Uint(receipt.Status)
+	}
+
+	// Blob gas isn't indexed, but follows from the transaction's
+	// blob count and the block's excess blob gas
+	if receipt.Type == types.BlobTxType && transaction.Block.ExcessBlobGas != nil {
+		fields["blobGasUsed"] = hexutil.Uint64(uint64(len(transaction.BlobHashes)) * params.BlobTxBlobGasPerBlob)
+		fields["blobGasPrice"] = (*hexutil.Big)(eip4844.CalcBlobFee(*transaction.Block.ExcessBlobGas))
+	}
+
+	if receipt.ContractAddress != "" {
+		fields["contractAddress"] = common.HexToAddress(receipt.ContractAddress)
+	}
+
+	return fields
+}
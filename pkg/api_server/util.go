@@ -2,8 +2,18 @@ package api_server
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"getherscan/pkg/models"
 	"log"
+	"math/big"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgtype"
 )
 
 func RespondWithJSON(request *http.Request, writer http.ResponseWriter, code int, payload interface{}) {
@@ -15,3 +25,105 @@ func RespondWithJSON(request *http.Request, writer http.ResponseWriter, code int
 func RespondWithError(request *http.Request, writer http.ResponseWriter, code int, message string) {
 	RespondWithJSON(request, writer, code, map[string]string{"error": message})
 }
+
+// Splits query parameter values that may each hold a comma-separated
+// list, so that both ?a=x&a=y and ?a=x,y are accepted
+func SplitQueryValues(values []string) []string {
+	var splitValues []string
+	for _, value := range values {
+		for _, splitValue := range strings.Split(value, ",") {
+			if splitValue != "" {
+				splitValues = append(splitValues, splitValue)
+			}
+		}
+	}
+
+	return splitValues
+}
+
+func IsHexHash(s string) bool {
+	bytes, err := hexutil.Decode(s)
+	return err == nil && len(bytes) == 32
+}
+
+func BigIntToNumeric(n *big.Int) (*pgtype.Numeric, error) {
+	numeric := new(pgtype.Numeric)
+	err := numeric.Set(n.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return numeric, nil
+}
+
+// Parses a non-negative decimal integer, e.g. a block number as
+// accepted by getBlockByNumber
+func ParseUint256(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return nil, fmt.Errorf("Invalid number %q", s)
+	}
+
+	return n, nil
+}
+
+func ParseBlockNumber(s string) (*big.Int, error) {
+	blockNumber, err := ParseUint256(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid block number %q", s)
+	}
+
+	return blockNumber, nil
+}
+
+// Cursors are of the form <block number>:<index in block>, pointing
+// to the last item of the previous page
+func FormatCursor(blockNumber pgtype.Numeric, index uint) string {
+	return fmt.Sprintf("%s:%d", models.NumericToBigInt(blockNumber).String(), index)
+}
+
+func ParseCursor(cursor string) (*models.Position, error) {
+	parts := strings.Split(cursor, ":")
+	if len(parts) != 2 {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	blockNumber, err := ParseBlockNumber(parts[0])
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	index, err := strconv.ParseUint(parts[1], 10, strconv.IntSize)
+	if err != nil {
+		return nil, errors.New("Invalid cursor")
+	}
+
+	numeric, err := BigIntToNumeric(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Position{
+		BlockNumber: *numeric,
+		Index:       uint(index),
+	}, nil
+}
+
+// Parses the limit query parameter of paginated queries, defaulting to
+// defaultLimit and capped at maxLimit
+func ParseLimit(query url.Values, defaultLimit, maxLimit int) (int, error) {
+	if query.Get("limit") == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 {
+		return 0, errors.New("Invalid limit")
+	}
+
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the columns and tables added by the
+// add_signatures_and_access_lists migration
+
+type transactionV2 struct {
+	Hash       string          `gorm:"primaryKey"`
+	AccessList []accessTupleV2 `gorm:"foreignKey:TransactionHash;references:Hash"`
+	V          pgtype.Numeric  `gorm:"type:numeric"`
+	R          pgtype.Numeric  `gorm:"type:numeric"`
+	S          pgtype.Numeric  `gorm:"type:numeric"`
+	Raw        []byte
+}
+
+func (transactionV2) TableName() string {
+	return "transactions"
+}
+
+type orphanedTransactionV2 struct {
+	Hash              string                  `gorm:"primaryKey"`
+	OrphanedBlockHash string                  `gorm:"primaryKey"`
+	AccessList        []orphanedAccessTupleV2 `gorm:"foreignKey:TransactionHash,OrphanedBlockHash;references:Hash,OrphanedBlockHash"`
+	V                 pgtype.Numeric          `gorm:"type:numeric"`
+	R                 pgtype.Numeric          `gorm:"type:numeric"`
+	S                 pgtype.Numeric          `gorm:"type:numeric"`
+	Raw               []byte
+}
+
+func (orphanedTransactionV2) TableName() string {
+	return "orphaned_transactions"
+}
+
+type accessTupleV2 struct {
+	TransactionHash string `gorm:"primaryKey"`
+	TupleIndex      uint   `gorm:"primaryKey;autoIncrement:false"`
+	Address         string
+	StorageKeys     string `gorm:"type:text"`
+	BlockHash       string `gorm:"index"`
+}
+
+func (accessTupleV2) TableName() string {
+	return "access_tuples"
+}
+
+type orphanedAccessTupleV2 struct {
+	TransactionHash   string `gorm:"primaryKey"`
+	OrphanedBlockHash string `gorm:"primaryKey"`
+	TupleIndex        uint   `gorm:"primaryKey;autoIncrement:false"`
+	Address           string
+	StorageKeys       string `gorm:"type:text"`
+}
+
+func (orphanedAccessTupleV2) TableName() string {
+	return "orphaned_access_tuples"
+}
+
+var signatureColumns = []string{"V", "R", "S", "Raw"}
+
+func addSignaturesAndAccessLists(tx *gorm.DB) error {
+	for _, table := range []interface{}{&transactionV2{}, &orphanedTransactionV2{}} {
+		for _, column := range signatureColumns {
+			err := tx.Migrator().AddColumn(table, column)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// The transaction tables are parsed first, so that the access
+	// list tables get their foreign keys
+	return tx.Migrator().CreateTable(&accessTupleV2{}, &orphanedAccessTupleV2{})
+}
+
+func dropSignaturesAndAccessLists(tx *gorm.DB) error {
+	err := tx.Migrator().DropTable(&orphanedAccessTupleV2{}, &accessTupleV2{})
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []interface{}{&transactionV2{}, &orphanedTransactionV2{}} {
+		for _, column := range signatureColumns {
+			err = tx.Migrator().DropColumn(table, column)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
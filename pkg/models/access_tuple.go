@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+)
+
+// Entry of an EIP-2930 access list: an address, and the storage slots
+// of it that the transaction plans to access
+type AccessTuple struct {
+	TransactionHash string `json:"transaction_hash" gorm:"primaryKey"`
+	// Index of the tuple in the access list
+	TupleIndex  uint        `json:"tuple_index" gorm:"primaryKey;autoIncrement:false"`
+	Address     string      `json:"address"`
+	StorageKeys StorageKeys `json:"storage_keys" gorm:"type:text"`
+	BlockHash   string      `json:"block_hash" gorm:"index"`
+}
+
+// Hex storage keys, stored as a single comma-separated column since
+// they are only ever read along with their tuple
+type StorageKeys []string
+
+func (storageKeys StorageKeys) Value() (driver.Value, error) {
+	return strings.Join(storageKeys, ","), nil
+}
+
+func (storageKeys *StorageKeys) Scan(value interface{}) error {
+	var joinedStorageKeys string
+	switch value := value.(type) {
+	case string:
+		joinedStorageKeys = value
+	case []byte:
+		joinedStorageKeys = string(value)
+	case nil:
+	default:
+		return fmt.Errorf("Can't scan %T into storage keys", value)
+	}
+
+	*storageKeys = StorageKeys{}
+	if joinedStorageKeys != "" {
+		*storageKeys = strings.Split(joinedStorageKeys, ",")
+	}
+
+	return nil
+}
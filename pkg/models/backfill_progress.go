@@ -0,0 +1,19 @@
+package models
+
+// Tracks how far a backfill of the [FromBlock, ToBlock] range has
+// gotten, so that it can be resumed if interrupted
+type BackfillProgress struct {
+	FromBlock uint64 `json:"from_block" gorm:"primaryKey;autoIncrement:false"`
+	ToBlock   uint64 `json:"to_block" gorm:"primaryKey;autoIncrement:false"`
+	// Number of the next block to index
+	NextBlock uint64 `json:"next_block"`
+}
+
+func (db *DB) GetBackfillProgress(fromBlock, toBlock uint64) (*BackfillProgress, error) {
+	var backfillProgress BackfillProgress
+	return &backfillProgress, db.Where("from_block = ? AND to_block = ?", fromBlock, toBlock).First(&backfillProgress).Error
+}
+
+func (db *DB) SaveBackfillProgress(backfillProgress *BackfillProgress) error {
+	return db.Save(backfillProgress).Error
+}
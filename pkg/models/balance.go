@@ -3,14 +3,68 @@ package models
 import "github.com/jackc/pgtype"
 
 type Balance struct {
-	Address   string `json:"address" gorm:"primaryKey"`
+	Address   string `json:"address" gorm:"primaryKey;index:idx_balances_address,priority:1"`
 	BlockHash string `json:"block_hash" gorm:"primaryKey"`
 	// Not sure if we need this belongs_to relationship
-	Block   Block          `json:"block" gorm:"foreignKey:BlockHash"`
-	Balance pgtype.Numeric `json:"balance" gorm:"type:numeric"`
+	Block       Block          `json:"block" gorm:"foreignKey:BlockHash"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index:idx_balances_address,priority:2;type:numeric"`
+	Balance     pgtype.Numeric `json:"balance" gorm:"type:numeric"`
+}
+
+type BalanceFilter struct {
+	Address string
+	// Optional block range bounds (inclusive)
+	FromBlock *pgtype.Numeric
+	ToBlock   *pgtype.Numeric
+	// If set, only matches balances at blocks after the given
+	// position's block number, used to paginate through results
+	After *Position
+	Limit int
 }
 
 func (db *DB) GetAddressBalanceByBlockHash(address, blockHash string) (*Balance, error) {
 	var balance Balance
 	return &balance, db.Where("address = ? AND block_hash = ?", address, blockHash).First(&balance).Error
 }
+
+// Fetches the address's most recent balance at a canonical block up to
+// blockNumber. Balances are only fetched for the blocks indexed while
+// the address is tracked, and the balance carries over the blocks in
+// between
+func (db *DB) GetLatestAddressBalance(address string, blockNumber pgtype.Numeric) (*Balance, error) {
+	var balance Balance
+	return &balance, db.Where(
+		"address = ? AND block_number <= ?",
+		address,
+		blockNumber,
+	).Order("block_number DESC").First(&balance).Error
+}
+
+// Fetches the balances matching the filter, ordered by block number
+func (db *DB) GetAddressBalances(filter BalanceFilter) ([]Balance, error) {
+	var balances []Balance
+
+	query := db.Where("address = ?", filter.Address)
+
+	if filter.FromBlock != nil {
+		query = query.Where("block_number >= ?", *filter.FromBlock)
+	}
+
+	if filter.ToBlock != nil {
+		query = query.Where("block_number <= ?", *filter.ToBlock)
+	}
+
+	if filter.After != nil {
+		query = query.Where("block_number > ?", filter.After.BlockNumber)
+	}
+
+	return balances, query.Order("block_number").Limit(filter.Limit).Find(&balances).Error
+}
+
+func (db *DB) CreateBalance(balance *Balance) error {
+	return db.Create(balance).Error
+}
+
+func (db *DB) DeleteBalancesForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&Balance{}).Error
+}
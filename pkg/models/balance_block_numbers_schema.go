@@ -0,0 +1,46 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshot of the column added by the add_balance_block_numbers
+// migration
+
+type balanceV9 struct {
+	Address     string         `gorm:"primaryKey;index:idx_balances_address,priority:1"`
+	BlockHash   string         `gorm:"primaryKey"`
+	BlockNumber pgtype.Numeric `gorm:"index:idx_balances_address,priority:2;type:numeric"`
+}
+
+func (balanceV9) TableName() string {
+	return "balances"
+}
+
+func addBalanceBlockNumbers(tx *gorm.DB) error {
+	err := tx.Migrator().AddColumn(&balanceV9{}, "BlockNumber")
+	if err != nil {
+		return err
+	}
+
+	// Balances are only kept for canonical blocks, so every
+	// existing balance has its block
+	err = tx.Exec(
+		"UPDATE balances SET block_number = (SELECT number FROM blocks WHERE blocks.hash = balances.block_hash)",
+	).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().CreateIndex(&balanceV9{}, "idx_balances_address")
+}
+
+func dropBalanceBlockNumbers(tx *gorm.DB) error {
+	err := tx.Migrator().DropIndex(&balanceV9{}, "idx_balances_address")
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().DropColumn(&balanceV9{}, "BlockNumber")
+}
@@ -0,0 +1,63 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+// The net change in an address's Ether balance over a canonical block,
+// derived from the state diffs of the block's transactions and from
+// its withdrawals. Unlike balances, deltas are kept for every address
+// the block touches, so they don't need addresses to be tracked
+type BalanceDelta struct {
+	Address     string         `json:"address" gorm:"primaryKey;index:idx_balance_deltas_address,priority:1"`
+	BlockHash   string         `json:"block_hash" gorm:"primaryKey"`
+	Block       Block          `json:"block" gorm:"foreignKey:BlockHash"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index:idx_balance_deltas_address,priority:2;type:numeric"`
+	Delta       pgtype.Numeric `json:"delta" gorm:"type:numeric"`
+	// Balance before the block, nil if the block's transactions
+	// didn't touch the address (i.e. it only received withdrawals)
+	PreviousBalance *pgtype.Numeric `json:"previous_balance" gorm:"type:numeric"`
+	// Balance after the block, from the previous balance or else
+	// from the balance after the address's previous delta. If no
+	// transaction touched the address, its balance before indexing
+	// started is assumed to be 0
+	Balance pgtype.Numeric `json:"balance" gorm:"type:numeric"`
+}
+
+// Fetches the address's last delta at or before the canonical block
+// with blockNumber, whose balance is the address's balance at that
+// block
+func (db *DB) GetLatestAddressBalanceDelta(address string, blockNumber pgtype.Numeric) (*BalanceDelta, error) {
+	var balanceDelta BalanceDelta
+	return &balanceDelta, db.Where(
+		"address = ? AND block_number <= ?",
+		address,
+		blockNumber,
+	).Order("block_number DESC").First(&balanceDelta).Error
+}
+
+// Fetches the address's deltas after the block with blockNumber,
+// ordered by block number
+func (db *DB) GetLaterAddressBalanceDeltas(address string, blockNumber pgtype.Numeric) ([]BalanceDelta, error) {
+	var balanceDeltas []BalanceDelta
+	return balanceDeltas, db.Where(
+		"address = ? AND block_number > ?",
+		address,
+		blockNumber,
+	).Order("block_number").Find(&balanceDeltas).Error
+}
+
+func (db *DB) GetBalanceDeltasForBlockHash(blockHash string) ([]BalanceDelta, error) {
+	var balanceDeltas []BalanceDelta
+	return balanceDeltas, db.Where("block_hash = ?", blockHash).Order("address").Find(&balanceDeltas).Error
+}
+
+func (db *DB) CreateBalanceDelta(balanceDelta *BalanceDelta) error {
+	return db.Create(balanceDelta).Error
+}
+
+func (db *DB) SaveBalanceDelta(balanceDelta *BalanceDelta) error {
+	return db.Save(balanceDelta).Error
+}
+
+func (db *DB) DeleteBalanceDeltasForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&BalanceDelta{}).Error
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshot of the column added by the add_balance_delta_balances
+// migration
+
+type balanceDeltaV10 struct {
+	Address         string          `gorm:"primaryKey;index:idx_balance_deltas_address,priority:1"`
+	BlockHash       string          `gorm:"primaryKey"`
+	BlockNumber     pgtype.Numeric  `gorm:"index:idx_balance_deltas_address,priority:2;type:numeric"`
+	Delta           pgtype.Numeric  `gorm:"type:numeric"`
+	PreviousBalance *pgtype.Numeric `gorm:"type:numeric"`
+	Balance         pgtype.Numeric  `gorm:"type:numeric"`
+}
+
+func (balanceDeltaV10) TableName() string {
+	return "balance_delta"
+}
+
+func addBalanceDeltaBalances(tx *gorm.DB) error {
+	err := tx.Migrator().AddColumn(&balanceDeltaV10{}, "Balance")
+	if err != nil {
+		return err
+	}
+
+	// Numerics are stored as text on SQLite, so the running
+	// balances are added up here rather than in SQL, one address at
+	// a time
+	var addresses []string
+	err = tx.Model(&balanceDeltaV10{}).Distinct().Pluck("address", &addresses).Error
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		var balanceDeltas []balanceDeltaV10
+		err = tx.Where("address = ?", address).Order("block_number").Find(&balanceDeltas).Error
+		if err != nil {
+			return err
+		}
+
+		balance := new(big.Int)
+		for _, balanceDelta := range balanceDeltas {
+			if balanceDelta.PreviousBalance != nil {
+				balance = NumericToBigInt(*balanceDelta.PreviousBalance)
+			}
+
+			balance.Add(balance, NumericToBigInt(balanceDelta.Delta))
+
+			balanceDeltaBalance := new(pgtype.Numeric)
+			err = balanceDeltaBalance.Set(balance.String())
+			if err != nil {
+				return err
+			}
+
+			err = tx.Model(&balanceDeltaV10{}).Where(
+				"address = ? AND block_hash = ?",
+				balanceDelta.Address,
+				balanceDelta.BlockHash,
+			).Update("balance", *balanceDeltaBalance).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func dropBalanceDeltaBalances(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&balanceDeltaV10{}, "Balance")
+}
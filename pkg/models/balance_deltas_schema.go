@@ -0,0 +1,42 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the tables added by the add_balance_deltas migration
+
+type balanceDeltaV7 struct {
+	Address         string          `gorm:"primaryKey;index:idx_balance_deltas_address,priority:1"`
+	BlockHash       string          `gorm:"primaryKey"`
+	Block           blockV1         `gorm:"foreignKey:BlockHash"`
+	BlockNumber     pgtype.Numeric  `gorm:"index:idx_balance_deltas_address,priority:2;type:numeric"`
+	Delta           pgtype.Numeric  `gorm:"type:numeric"`
+	PreviousBalance *pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (balanceDeltaV7) TableName() string {
+	return "balance_delta"
+}
+
+type orphanedBalanceDeltaV7 struct {
+	Address           string          `gorm:"primaryKey"`
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+	BlockNumber       pgtype.Numeric  `gorm:"type:numeric"`
+	Delta             pgtype.Numeric  `gorm:"type:numeric"`
+	PreviousBalance   *pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (orphanedBalanceDeltaV7) TableName() string {
+	return "orphaned_balance_delta"
+}
+
+func addBalanceDeltas(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&balanceDeltaV7{}, &orphanedBalanceDeltaV7{})
+}
+
+func dropBalanceDeltas(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&orphanedBalanceDeltaV7{}, &balanceDeltaV7{})
+}
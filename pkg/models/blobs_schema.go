@@ -0,0 +1,80 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the columns added by the add_blob_gas migration
+
+type blockV3 struct {
+	BlobGasUsed   *uint64
+	ExcessBlobGas *uint64
+}
+
+func (blockV3) TableName() string {
+	return "blocks"
+}
+
+type orphanedBlockV3 struct {
+	BlobGasUsed   *uint64
+	ExcessBlobGas *uint64
+}
+
+func (orphanedBlockV3) TableName() string {
+	return "orphaned_blocks"
+}
+
+type transactionV3 struct {
+	BlobGasFeeCap *pgtype.Numeric `gorm:"type:numeric"`
+	BlobHashes    string          `gorm:"type:text"`
+}
+
+func (transactionV3) TableName() string {
+	return "transactions"
+}
+
+type orphanedTransactionV3 struct {
+	BlobGasFeeCap *pgtype.Numeric `gorm:"type:numeric"`
+	BlobHashes    string          `gorm:"type:text"`
+}
+
+func (orphanedTransactionV3) TableName() string {
+	return "orphaned_transactions"
+}
+
+var blobGasColumns = []struct {
+	table   interface{}
+	columns []string
+}{
+	{&blockV3{}, []string{"BlobGasUsed", "ExcessBlobGas"}},
+	{&orphanedBlockV3{}, []string{"BlobGasUsed", "ExcessBlobGas"}},
+	{&transactionV3{}, []string{"BlobGasFeeCap", "BlobHashes"}},
+	{&orphanedTransactionV3{}, []string{"BlobGasFeeCap", "BlobHashes"}},
+}
+
+func addBlobGas(tx *gorm.DB) error {
+	for _, tableColumns := range blobGasColumns {
+		for _, column := range tableColumns.columns {
+			err := tx.Migrator().AddColumn(tableColumns.table, column)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func dropBlobGas(tx *gorm.DB) error {
+	for _, tableColumns := range blobGasColumns {
+		for _, column := range tableColumns.columns {
+			err := tx.Migrator().DropColumn(tableColumns.table, column)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
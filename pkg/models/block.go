@@ -24,9 +24,24 @@ type Block struct {
 	Extra       []byte         `json:"extra"`
 	MixDigest   string         `json:"mix_digest"`
 	Nonce       pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
-	BaseFee     pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+	// Only set since EIP-1559 (London)
+	BaseFee *pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+	// Only set since EIP-4844 (Cancun)
+	BlobGasUsed   *uint64 `json:"blob_gas_used"`
+	ExcessBlobGas *uint64 `json:"excess_blob_gas"`
+	// Only set since Shanghai
+	WithdrawalsRoot string `json:"withdrawals_root"`
+	// Whether or not the block can still be reorged, according
+	// to the node's safe and finalized block tags
+	Finality string `json:"finality" gorm:"index;default:unsafe"`
 }
 
+const (
+	FinalityUnsafe    = "unsafe"
+	FinalitySafe      = "safe"
+	FinalityFinalized = "finalized"
+)
+
 func (db *DB) GetHead() (*Block, error) {
 	var head Block
 	result := db.Order("number desc").Limit(1).Find(&head)
@@ -50,3 +65,48 @@ func (db *DB) GetBlockByNumber(blockNumber pgtype.Numeric) (*Block, error) {
 	var block Block
 	return &block, db.Where("number = ?", blockNumber).First(&block).Error
 }
+
+// Fetches the latest block whose finality is one of the given
+// finalities
+func (db *DB) GetHeadByFinality(finalities ...string) (*Block, error) {
+	var head Block
+	result := db.Where("finality IN ?", finalities).Order("number desc").Limit(1).Find(&head)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &head, nil
+}
+
+func (db *DB) GetFinalizedHead() (*Block, error) {
+	return db.GetHeadByFinality(FinalityFinalized)
+}
+
+// Finalized blocks are necessarily safe
+func (db *DB) GetSafeHead() (*Block, error) {
+	return db.GetHeadByFinality(FinalitySafe, FinalityFinalized)
+}
+
+// Marks every canonical block up to (and including) blockNumber as
+// finalized
+func (db *DB) FinalizeBlocksUpTo(blockNumber pgtype.Numeric) error {
+	return db.Model(&Block{}).Where("number <= ? AND finality <> ?", blockNumber, FinalityFinalized).Update("finality", FinalityFinalized).Error
+}
+
+// Marks every unsafe canonical block up to (and including)
+// blockNumber as safe
+func (db *DB) MarkBlocksSafeUpTo(blockNumber pgtype.Numeric) error {
+	return db.Model(&Block{}).Where("number <= ? AND finality = ?", blockNumber, FinalityUnsafe).Update("finality", FinalitySafe).Error
+}
+
+func (db *DB) CreateBlock(block *Block) error {
+	return db.Create(block).Error
+}
+
+func (db *DB) DeleteBlock(blockHash string) error {
+	return db.Where("hash = ?", blockHash).Delete(&Block{}).Error
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+// The checksum_tracked_addresses migration rewrites tracked addresses,
+// and the balances indexed for them, that were stored as given into
+// their checksummed form. Where both forms were stored, the
+// checksummed one is kept
+
+func checksumAddresses(tx *gorm.DB, table, duplicateCondition string) error {
+	var addresses []string
+	err := tx.Table(table).Distinct().Pluck("address", &addresses).Error
+	if err != nil {
+		return err
+	}
+
+	for _, address := range addresses {
+		checksummedAddress := common.HexToAddress(address).Hex()
+		if address == checksummedAddress {
+			continue
+		}
+
+		err = tx.Exec(
+			"DELETE FROM "+table+" WHERE address = ? AND "+duplicateCondition,
+			address,
+			checksummedAddress,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Exec(
+			"UPDATE "+table+" SET address = ? WHERE address = ?",
+			checksummedAddress,
+			address,
+		).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checksumTrackedAddresses(tx *gorm.DB) error {
+	err := checksumAddresses(
+		tx,
+		"tracked_addresses",
+		"EXISTS (SELECT 1 FROM tracked_addresses AS checksummed WHERE checksummed.address = ?)",
+	)
+	if err != nil {
+		return err
+	}
+
+	return checksumAddresses(
+		tx,
+		"balances",
+		"block_hash IN (SELECT block_hash FROM balances AS checksummed WHERE checksummed.address = ?)",
+	)
+}
+
+// The addresses' original forms aren't kept, so there is nothing to
+// revert
+func revertChecksumTrackedAddresses(tx *gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+func connectForMigration(cliCtx *cli.Context) (*DB, error) {
+	db := new(DB)
+	return db, db.Connect(cliCtx.Args().Get(0))
+}
+
+func MigrateUpAction(cliCtx *cli.Context) error {
+	db, err := connectForMigration(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	appliedMigrations, err := db.MigrateUp()
+	for _, migration := range appliedMigrations {
+		fmt.Printf("Applied migration %d (%s)\n", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(appliedMigrations) == 0 {
+		fmt.Printf("Schema is already at version %d\n", LatestSchemaVersion())
+	}
+
+	return nil
+}
+
+func MigrateDownAction(cliCtx *cli.Context) error {
+	steps := 1
+	if cliCtx.Args().Get(1) != "" {
+		parsedSteps, err := strconv.ParseUint(cliCtx.Args().Get(1), 10, 32)
+		if err != nil {
+			return err
+		}
+
+		steps = int(parsedSteps)
+	}
+
+	db, err := connectForMigration(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	revertedMigrations, err := db.MigrateDown(steps)
+	for _, migration := range revertedMigrations {
+		fmt.Printf("Reverted migration %d (%s)\n", migration.Version, migration.Name)
+	}
+
+	return err
+}
+
+func MigrateStatusAction(cliCtx *cli.Context) error {
+	db, err := connectForMigration(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	migrationStatuses, err := db.GetMigrationStatuses()
+	if err != nil {
+		return err
+	}
+
+	for _, migrationStatus := range migrationStatuses {
+		appliedAt := "pending"
+		if !migrationStatus.AppliedAt.IsZero() {
+			appliedAt = "applied at " + migrationStatus.AppliedAt.Format(time.RFC3339)
+		}
+
+		fmt.Printf("%d (%s): %s\n", migrationStatus.Version, migrationStatus.Name, appliedAt)
+	}
+
+	return nil
+}
+
+var MigrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "Manages the schema of the database at the provided connection. The poller and API server refuse to start until every migration has been applied.",
+	Subcommands: []cli.Command{
+		{
+			Name:      "up",
+			Usage:     "Applies every pending migration, in order.",
+			ArgsUsage: "Provide a database connection string (a PostgreSQL connection string, or sqlite://<PATH>).",
+			Action:    MigrateUpAction,
+		},
+		{
+			Name:      "down",
+			Usage:     "Reverts the latest applied migrations, in reverse order, dropping the data they hold.",
+			ArgsUsage: "Provide a database connection string (a PostgreSQL connection string, or sqlite://<PATH>), and, optionally, the number of migrations to revert (1 by default).",
+			Action:    MigrateDownAction,
+		},
+		{
+			Name:      "status",
+			Usage:     "Lists every migration, and when it was applied.",
+			ArgsUsage: "Provide a database connection string (a PostgreSQL connection string, or sqlite://<PATH>).",
+			Action:    MigrateStatusAction,
+		},
+	},
+}
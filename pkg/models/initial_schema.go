@@ -0,0 +1,310 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the models as of the initial schema. Migrations can't
+// use the models themselves, which change along with the schema
+
+type blockV1 struct {
+	Hash        string `gorm:"primaryKey"`
+	Size        uint64
+	ParentHash  string
+	UncleHash   string
+	Coinbase    string
+	Root        string
+	TxHash      string
+	ReceiptHash string
+	Bloom       []byte
+	Difficulty  pgtype.Numeric `gorm:"type:numeric"`
+	Number      pgtype.Numeric `gorm:"index:,sort:desc;type:numeric"`
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   string
+	Nonce       pgtype.Numeric `gorm:"type:numeric"`
+	BaseFee     pgtype.Numeric `gorm:"type:numeric"`
+	Finality    string         `gorm:"index;default:unsafe"`
+}
+
+func (blockV1) TableName() string {
+	return "blocks"
+}
+
+type orphanedBlockV1 struct {
+	Hash        string `gorm:"primaryKey"`
+	Size        uint64
+	ParentHash  string
+	UncleHash   string
+	Coinbase    string
+	Root        string
+	TxHash      string
+	ReceiptHash string
+	Bloom       []byte
+	Difficulty  pgtype.Numeric `gorm:"type:numeric"`
+	Number      pgtype.Numeric `gorm:"type:numeric"`
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   string
+	Nonce       pgtype.Numeric `gorm:"type:numeric"`
+	BaseFee     pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (orphanedBlockV1) TableName() string {
+	return "orphaned_blocks"
+}
+
+type transactionV1 struct {
+	Hash             string `gorm:"primaryKey"`
+	Size             uint64
+	From             string `gorm:"index:idx_transactions_from,priority:1"`
+	Type             byte
+	ChainID          pgtype.Numeric `gorm:"type:numeric"`
+	Data             []byte
+	Gas              uint64
+	GasPrice         pgtype.Numeric `gorm:"type:numeric"`
+	GasTipCap        pgtype.Numeric `gorm:"type:numeric"`
+	GasFeeCap        pgtype.Numeric `gorm:"type:numeric"`
+	Value            pgtype.Numeric `gorm:"type:numeric"`
+	Nonce            pgtype.Numeric `gorm:"type:numeric"`
+	To               string         `gorm:"index:idx_transactions_to,priority:1"`
+	BlockHash        string
+	Block            blockV1        `gorm:"foreignKey:BlockHash"`
+	BlockNumber      pgtype.Numeric `gorm:"index:idx_transactions_from,priority:2;index:idx_transactions_to,priority:2;type:numeric"`
+	TransactionIndex uint           `gorm:"index:idx_transactions_from,priority:3;index:idx_transactions_to,priority:3"`
+}
+
+func (transactionV1) TableName() string {
+	return "transactions"
+}
+
+type orphanedTransactionV1 struct {
+	Hash              string `gorm:"primaryKey"`
+	Size              uint64
+	From              string
+	Type              byte
+	ChainID           pgtype.Numeric `gorm:"type:numeric"`
+	Data              []byte
+	Gas               uint64
+	GasPrice          pgtype.Numeric `gorm:"type:numeric"`
+	GasTipCap         pgtype.Numeric `gorm:"type:numeric"`
+	GasFeeCap         pgtype.Numeric `gorm:"type:numeric"`
+	Value             pgtype.Numeric `gorm:"type:numeric"`
+	Nonce             pgtype.Numeric `gorm:"type:numeric"`
+	To                string
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+	BlockNumber       pgtype.Numeric  `gorm:"type:numeric"`
+	TransactionIndex  uint
+}
+
+func (orphanedTransactionV1) TableName() string {
+	return "orphaned_transactions"
+}
+
+type balanceV1 struct {
+	Address   string         `gorm:"primaryKey"`
+	BlockHash string         `gorm:"primaryKey"`
+	Block     blockV1        `gorm:"foreignKey:BlockHash"`
+	Balance   pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (balanceV1) TableName() string {
+	return "balances"
+}
+
+type backfillProgressV1 struct {
+	FromBlock uint64 `gorm:"primaryKey;autoIncrement:false"`
+	ToBlock   uint64 `gorm:"primaryKey;autoIncrement:false"`
+	NextBlock uint64
+}
+
+func (backfillProgressV1) TableName() string {
+	return "backfill_progresses"
+}
+
+type receiptV1 struct {
+	TransactionHash   string `gorm:"primaryKey"`
+	TransactionIndex  uint
+	Type              byte
+	PostState         []byte
+	Status            uint64
+	CumulativeGasUsed uint64
+	Bloom             []byte
+	GasUsed           uint64
+	EffectiveGasPrice pgtype.Numeric `gorm:"type:numeric"`
+	ContractAddress   string
+	Logs              []logV1 `gorm:"foreignKey:TransactionHash;references:TransactionHash"`
+	BlockHash         string  `gorm:"index"`
+	Block             blockV1 `gorm:"foreignKey:BlockHash"`
+}
+
+func (receiptV1) TableName() string {
+	return "receipts"
+}
+
+type logV1 struct {
+	BlockHash        string `gorm:"primaryKey"`
+	LogIndex         uint   `gorm:"primaryKey;autoIncrement:false;index:idx_logs_position,priority:2"`
+	Address          string `gorm:"index:idx_logs_address,priority:1"`
+	Topic0           string `gorm:"index:idx_logs_topic0,priority:1"`
+	Topic1           string `gorm:"index"`
+	Topic2           string `gorm:"index"`
+	Topic3           string `gorm:"index"`
+	Data             []byte
+	BlockNumber      pgtype.Numeric `gorm:"index:idx_logs_position,priority:1;index:idx_logs_address,priority:2;index:idx_logs_topic0,priority:2;type:numeric"`
+	TransactionHash  string         `gorm:"index"`
+	TransactionIndex uint
+}
+
+func (logV1) TableName() string {
+	return "logs"
+}
+
+type orphanedReceiptV1 struct {
+	TransactionHash   string `gorm:"primaryKey"`
+	TransactionIndex  uint
+	Type              byte
+	PostState         []byte
+	Status            uint64
+	CumulativeGasUsed uint64
+	Bloom             []byte
+	GasUsed           uint64
+	EffectiveGasPrice pgtype.Numeric `gorm:"type:numeric"`
+	ContractAddress   string
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+}
+
+func (orphanedReceiptV1) TableName() string {
+	return "orphaned_receipts"
+}
+
+type orphanedLogV1 struct {
+	OrphanedBlockHash string `gorm:"primaryKey"`
+	LogIndex          uint   `gorm:"primaryKey;autoIncrement:false"`
+	Address           string
+	Topic0            string
+	Topic1            string
+	Topic2            string
+	Topic3            string
+	Data              []byte
+	BlockNumber       pgtype.Numeric `gorm:"type:numeric"`
+	TransactionHash   string
+	TransactionIndex  uint
+}
+
+func (orphanedLogV1) TableName() string {
+	return "orphaned_logs"
+}
+
+type tokenTransferV1 struct {
+	BlockHash       string         `gorm:"primaryKey"`
+	LogIndex        uint           `gorm:"primaryKey;autoIncrement:false"`
+	Token           string         `gorm:"index"`
+	From            string         `gorm:"index:idx_token_transfers_from,priority:1"`
+	To              string         `gorm:"index:idx_token_transfers_to,priority:1"`
+	Value           pgtype.Numeric `gorm:"type:numeric"`
+	BlockNumber     pgtype.Numeric `gorm:"index:idx_token_transfers_from,priority:2;index:idx_token_transfers_to,priority:2;type:numeric"`
+	TransactionHash string         `gorm:"index"`
+}
+
+func (tokenTransferV1) TableName() string {
+	return "token_transfers"
+}
+
+type tokenBalanceDeltaV1 struct {
+	Token       string         `gorm:"primaryKey"`
+	Holder      string         `gorm:"primaryKey"`
+	BlockHash   string         `gorm:"primaryKey;index"`
+	BlockNumber pgtype.Numeric `gorm:"index;type:numeric"`
+	Delta       pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (tokenBalanceDeltaV1) TableName() string {
+	return "token_balance_delta"
+}
+
+type nftTransferV1 struct {
+	BlockHash       string `gorm:"primaryKey"`
+	LogIndex        uint   `gorm:"primaryKey;autoIncrement:false"`
+	BatchIndex      uint   `gorm:"primaryKey;autoIncrement:false"`
+	Standard        string
+	Token           string         `gorm:"index:idx_nft_transfers_token_id,priority:1"`
+	TokenID         pgtype.Numeric `gorm:"index:idx_nft_transfers_token_id,priority:2;type:numeric"`
+	Operator        string
+	From            string         `gorm:"index"`
+	To              string         `gorm:"index"`
+	Value           pgtype.Numeric `gorm:"type:numeric"`
+	BlockNumber     pgtype.Numeric `gorm:"index;type:numeric"`
+	TransactionHash string         `gorm:"index"`
+}
+
+func (nftTransferV1) TableName() string {
+	return "nft_transfers"
+}
+
+type nftBalanceDeltaV1 struct {
+	Token       string         `gorm:"primaryKey;index:idx_nft_balance_deltas_token_id,priority:1"`
+	TokenID     pgtype.Numeric `gorm:"primaryKey;index:idx_nft_balance_deltas_token_id,priority:2;type:numeric"`
+	Holder      string         `gorm:"primaryKey;index:idx_nft_balance_deltas_holder,priority:1"`
+	BlockHash   string         `gorm:"primaryKey;index"`
+	BlockNumber pgtype.Numeric `gorm:"index:idx_nft_balance_deltas_holder,priority:2;type:numeric"`
+	Delta       pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (nftBalanceDeltaV1) TableName() string {
+	return "nft_balance_delta"
+}
+
+// Tables of the initial schema, referenced tables first
+var initialSchemaTables = []interface{}{
+	&blockV1{},
+	&orphanedBlockV1{},
+	&transactionV1{},
+	&orphanedTransactionV1{},
+	&balanceV1{},
+	&backfillProgressV1{},
+	&receiptV1{},
+	&logV1{},
+	&orphanedReceiptV1{},
+	&orphanedLogV1{},
+	&tokenTransferV1{},
+	&tokenBalanceDeltaV1{},
+	&nftTransferV1{},
+	&nftBalanceDeltaV1{},
+}
+
+// Creates the tables the models had before migrations were versioned.
+// Databases that were set up with AutoMigrate already have them, so
+// existing tables are left alone
+func createInitialSchema(tx *gorm.DB) error {
+	for _, table := range initialSchemaTables {
+		if tx.Migrator().HasTable(table) {
+			continue
+		}
+
+		err := tx.Migrator().CreateTable(table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func dropInitialSchema(tx *gorm.DB) error {
+	for i := len(initialSchemaTables) - 1; i >= 0; i-- {
+		err := tx.Migrator().DropTable(initialSchemaTables[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
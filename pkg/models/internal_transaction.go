@@ -0,0 +1,90 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// A call made during a transaction in a canonical block, as traced by
+// the node's call tracer: a message call, a contract creation or a
+// self-destruct. The transaction's own (top-level) call isn't one
+type InternalTransaction struct {
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	Block     Block  `json:"block" gorm:"foreignKey:BlockHash"`
+	// Index of the call among the internal transactions of the
+	// block, in execution order
+	TraceIndex       uint   `json:"trace_index" gorm:"primaryKey;autoIncrement:false;index:idx_internal_transactions_from,priority:3;index:idx_internal_transactions_to,priority:3"`
+	TransactionHash  string `json:"transaction_hash" gorm:"index"`
+	TransactionIndex uint   `json:"transaction_index"`
+	// Position of the call in the transaction's call tree, e.g.
+	// "0.1" is the second call made by the first call the
+	// transaction made
+	TraceAddress string `json:"trace_address"`
+	// CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE, CREATE2 or
+	// SELFDESTRUCT
+	Type    string         `json:"type"`
+	From    string         `json:"from" gorm:"index:idx_internal_transactions_from,priority:1"`
+	To      string         `json:"to" gorm:"index:idx_internal_transactions_to,priority:1"`
+	Value   pgtype.Numeric `json:"value" gorm:"type:numeric"`
+	Gas     uint64         `json:"gas"`
+	GasUsed uint64         `json:"gas_used"`
+	Input   []byte         `json:"input"`
+	Output  []byte         `json:"output"`
+	// Empty unless the call failed, in which case it moved no value
+	Error       string         `json:"error"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index:idx_internal_transactions_from,priority:2;index:idx_internal_transactions_to,priority:2;type:numeric"`
+}
+
+func (db *DB) GetInternalTransactionsForBlockHash(blockHash string) ([]InternalTransaction, error) {
+	var internalTransactions []InternalTransaction
+	return internalTransactions, db.Where("block_hash = ?", blockHash).Order("trace_index").Find(&internalTransactions).Error
+}
+
+func (db *DB) GetInternalTransactionsByTransactionHash(transactionHash string) ([]InternalTransaction, error) {
+	var internalTransactions []InternalTransaction
+	return internalTransactions, db.Where("transaction_hash = ?", transactionHash).Order("trace_index").Find(&internalTransactions).Error
+}
+
+// Fetches the internal transactions matching the filter, ordered by
+// their position in the chain. The index of filter.After is a trace
+// index
+func (db *DB) GetInternalTransactionsByAddress(filter TransactionFilter) ([]InternalTransaction, error) {
+	var internalTransactions []InternalTransaction
+
+	var query *gorm.DB
+	switch filter.Direction {
+	case TransactionDirectionIn:
+		query = db.Where(`"to" = ?`, filter.Address)
+	case TransactionDirectionOut:
+		query = db.Where(`"from" = ?`, filter.Address)
+	default:
+		query = db.Where(`("from" = ? OR "to" = ?)`, filter.Address, filter.Address)
+	}
+
+	if filter.FromBlock != nil {
+		query = query.Where("block_number >= ?", *filter.FromBlock)
+	}
+
+	if filter.ToBlock != nil {
+		query = query.Where("block_number <= ?", *filter.ToBlock)
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			"(block_number > ? OR (block_number = ? AND trace_index > ?))",
+			filter.After.BlockNumber,
+			filter.After.BlockNumber,
+			filter.After.Index,
+		)
+	}
+
+	return internalTransactions, query.Order("block_number, trace_index").Limit(filter.Limit).Find(&internalTransactions).Error
+}
+
+func (db *DB) CreateInternalTransaction(internalTransaction *InternalTransaction) error {
+	return db.Create(internalTransaction).Error
+}
+
+func (db *DB) DeleteInternalTransactionsForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&InternalTransaction{}).Error
+}
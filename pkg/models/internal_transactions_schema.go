@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the tables added by the add_internal_transactions
+// migration
+
+type internalTransactionV6 struct {
+	BlockHash        string  `gorm:"primaryKey"`
+	Block            blockV1 `gorm:"foreignKey:BlockHash"`
+	TraceIndex       uint    `gorm:"primaryKey;autoIncrement:false;index:idx_internal_transactions_from,priority:3;index:idx_internal_transactions_to,priority:3"`
+	TransactionHash  string  `gorm:"index"`
+	TransactionIndex uint
+	TraceAddress     string
+	Type             string
+	From             string         `gorm:"index:idx_internal_transactions_from,priority:1"`
+	To               string         `gorm:"index:idx_internal_transactions_to,priority:1"`
+	Value            pgtype.Numeric `gorm:"type:numeric"`
+	Gas              uint64
+	GasUsed          uint64
+	Input            []byte
+	Output           []byte
+	Error            string
+	BlockNumber      pgtype.Numeric `gorm:"index:idx_internal_transactions_from,priority:2;index:idx_internal_transactions_to,priority:2;type:numeric"`
+}
+
+func (internalTransactionV6) TableName() string {
+	return "internal_transactions"
+}
+
+type orphanedInternalTransactionV6 struct {
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+	TraceIndex        uint            `gorm:"primaryKey;autoIncrement:false"`
+	TransactionHash   string
+	TransactionIndex  uint
+	TraceAddress      string
+	Type              string
+	From              string
+	To                string
+	Value             pgtype.Numeric `gorm:"type:numeric"`
+	Gas               uint64
+	GasUsed           uint64
+	Input             []byte
+	Output            []byte
+	Error             string
+	BlockNumber       pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (orphanedInternalTransactionV6) TableName() string {
+	return "orphaned_internal_transactions"
+}
+
+func addInternalTransactions(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&internalTransactionV6{}, &orphanedInternalTransactionV6{})
+}
+
+func dropInternalTransactions(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&orphanedInternalTransactionV6{}, &internalTransactionV6{})
+}
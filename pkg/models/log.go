@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgtype"
+)
+
+type Log struct {
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	// Index of the log in the block
+	LogIndex uint   `json:"log_index" gorm:"primaryKey;autoIncrement:false;index:idx_logs_position,priority:2"`
+	Address  string `json:"address" gorm:"index:idx_logs_address,priority:1"`
+	// Topics are split into a column per position (empty if the
+	// log has fewer topics), so that each can be filtered on
+	Topic0           string         `json:"topic0" gorm:"index:idx_logs_topic0,priority:1"`
+	Topic1           string         `json:"topic1" gorm:"index"`
+	Topic2           string         `json:"topic2" gorm:"index"`
+	Topic3           string         `json:"topic3" gorm:"index"`
+	Data             []byte         `json:"data"`
+	BlockNumber      pgtype.Numeric `json:"block_number" gorm:"index:idx_logs_position,priority:1;index:idx_logs_address,priority:2;index:idx_logs_topic0,priority:2;type:numeric"`
+	TransactionHash  string         `json:"transaction_hash" gorm:"index"`
+	TransactionIndex uint           `json:"transaction_index"`
+}
+
+// Filters logs the same way eth_getLogs does: by block range, by
+// emitting address (any of Addresses), and by topic at each position
+// (any of Topics[i]). Empty address or topic sets match anything
+type LogFilter struct {
+	FromBlock pgtype.Numeric
+	ToBlock   pgtype.Numeric
+	Addresses []string
+	Topics    [4][]string
+	// If set, only matches logs after the given position in the
+	// chain, used to paginate through results
+	After *Position
+	Limit int
+}
+
+// Returns the log's non-empty topics, in order
+func (log *Log) Topics() []string {
+	topics := []string{log.Topic0, log.Topic1, log.Topic2, log.Topic3}
+	for i, topic := range topics {
+		if topic == "" {
+			return topics[:i]
+		}
+	}
+
+	return topics
+}
+
+// Fetches the logs emitted in the block with the given hash, only
+// keeping those emitted by one of addresses, if any are given
+func (db *DB) GetLogsForBlockHash(blockHash string, addresses []string) ([]Log, error) {
+	var logs []Log
+
+	query := db.Where("block_hash = ?", blockHash)
+	if len(addresses) > 0 {
+		query = query.Where("address IN ?", addresses)
+	}
+
+	return logs, query.Order("log_index").Find(&logs).Error
+}
+
+// Fetches the logs matching the filter, ordered by their position in
+// the chain
+func (db *DB) GetLogs(filter LogFilter) ([]Log, error) {
+	var logs []Log
+
+	query := db.Where("block_number >= ? AND block_number <= ?", filter.FromBlock, filter.ToBlock)
+
+	if len(filter.Addresses) > 0 {
+		query = query.Where("address IN ?", filter.Addresses)
+	}
+
+	for i, topics := range filter.Topics {
+		if len(topics) > 0 {
+			query = query.Where(fmt.Sprintf("topic%d IN ?", i), topics)
+		}
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			"(block_number > ? OR (block_number = ? AND log_index > ?))",
+			filter.After.BlockNumber,
+			filter.After.BlockNumber,
+			filter.After.Index,
+		)
+	}
+
+	return logs, query.Order("block_number, log_index").Limit(filter.Limit).Find(&logs).Error
+}
+
+func (db *DB) CreateLog(log *Log) error {
+	return db.Create(log).Error
+}
+
+func (db *DB) DeleteLogsForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&Log{}).Error
+}
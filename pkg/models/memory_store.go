@@ -0,0 +1,1979 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgtype"
+)
+
+// Connection strings starting with this prefix open a MemoryStore,
+// e.g. "memory://" or "memory://test". Stores opened with the same
+// connection string in a process share their data
+const MemoryStorePrefix = "memory://"
+
+var memoryStores = struct {
+	sync.Mutex
+	byName map[string]*MemoryStore
+}{byName: make(map[string]*MemoryStore)}
+
+// Returns the process-wide in-memory store with the given name,
+// creating it if needed
+func OpenMemoryStore(name string) *MemoryStore {
+	memoryStores.Lock()
+	defer memoryStores.Unlock()
+
+	store, ok := memoryStores.byName[name]
+	if !ok {
+		store = NewMemoryStore()
+		memoryStores.byName[name] = store
+	}
+
+	return store
+}
+
+// Store that keeps everything in memory, for tests and ephemeral runs.
+// It mirrors the semantics of DB: lookups of missing records return
+// ErrRecordNotFound, creating a record with an existing primary key
+// fails, and results come back in the same order. Writes made inside
+// Atomically are undone if it fails, but are visible to other readers
+// before it returns, so there should be a single writer
+type MemoryStore struct {
+	tables *memoryTables
+	// Undo functions for the writes made inside Atomically, nil
+	// outside of it
+	journal *[]func()
+}
+
+type memoryTables struct {
+	sync.RWMutex
+	blocks                       map[string]Block
+	orphanedBlocks               map[string]OrphanedBlock
+	transactions                 map[string]Transaction
+	orphanedTransactions         map[string]OrphanedTransaction
+	withdrawals                  map[string]Withdrawal
+	orphanedWithdrawals          map[string]OrphanedWithdrawal
+	uncles                       map[string]Uncle
+	orphanedUncles               map[string]OrphanedUncle
+	internalTransactions         map[string]InternalTransaction
+	orphanedInternalTransactions map[string]OrphanedInternalTransaction
+	balances                     map[string]Balance
+	balanceDeltas                map[string]BalanceDelta
+	orphanedBalanceDeltas        map[string]OrphanedBalanceDelta
+	receipts                     map[string]Receipt
+	orphanedReceipts             map[string]OrphanedReceipt
+	logs                         map[string]Log
+	orphanedLogs                 map[string]OrphanedLog
+	tokenTransfers               map[string]TokenTransfer
+	tokenBalanceDeltas           map[string]TokenBalanceDelta
+	nftTransfers                 map[string]NFTTransfer
+	nftBalanceDeltas             map[string]NFTBalanceDelta
+	backfillProgresses           map[string]BackfillProgress
+	trackedAddresses             map[string]TrackedAddress
+}
+
+func NewMemoryStore() *MemoryStore {
+	store := &MemoryStore{tables: new(memoryTables)}
+	store.tables.clear()
+	return store
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func (tables *memoryTables) clear() {
+	tables.blocks = make(map[string]Block)
+	tables.orphanedBlocks = make(map[string]OrphanedBlock)
+	tables.transactions = make(map[string]Transaction)
+	tables.orphanedTransactions = make(map[string]OrphanedTransaction)
+	tables.withdrawals = make(map[string]Withdrawal)
+	tables.orphanedWithdrawals = make(map[string]OrphanedWithdrawal)
+	tables.uncles = make(map[string]Uncle)
+	tables.orphanedUncles = make(map[string]OrphanedUncle)
+	tables.internalTransactions = make(map[string]InternalTransaction)
+	tables.orphanedInternalTransactions = make(map[string]OrphanedInternalTransaction)
+	tables.balances = make(map[string]Balance)
+	tables.balanceDeltas = make(map[string]BalanceDelta)
+	tables.orphanedBalanceDeltas = make(map[string]OrphanedBalanceDelta)
+	tables.receipts = make(map[string]Receipt)
+	tables.orphanedReceipts = make(map[string]OrphanedReceipt)
+	tables.logs = make(map[string]Log)
+	tables.orphanedLogs = make(map[string]OrphanedLog)
+	tables.tokenTransfers = make(map[string]TokenTransfer)
+	tables.tokenBalanceDeltas = make(map[string]TokenBalanceDelta)
+	tables.nftTransfers = make(map[string]NFTTransfer)
+	tables.nftBalanceDeltas = make(map[string]NFTBalanceDelta)
+	tables.backfillProgresses = make(map[string]BackfillProgress)
+	tables.trackedAddresses = make(map[string]TrackedAddress)
+}
+
+// Primary keys are made of one or more columns
+func memoryKey(columns ...interface{}) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		if numeric, ok := column.(pgtype.Numeric); ok {
+			column = NumericToBigInt(numeric)
+		}
+
+		parts[i] = fmt.Sprint(column)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func errDuplicateKey(table, key string) error {
+	return fmt.Errorf("duplicate key %s in %s", key, table)
+}
+
+func compareNumerics(a, b pgtype.Numeric) int {
+	return NumericToBigInt(a).Cmp(NumericToBigInt(b))
+}
+
+// Checks if the item at the given position comes after position, which
+// matches anything if nil
+func isAfterPosition(blockNumber pgtype.Numeric, index uint, position *Position) bool {
+	if position == nil {
+		return true
+	}
+
+	comparison := compareNumerics(blockNumber, position.BlockNumber)
+	return comparison > 0 || (comparison == 0 && index > position.Index)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Records how to undo a write made inside Atomically. Must be called
+// with the tables locked
+func (store *MemoryStore) record(undo func()) {
+	if store.journal != nil {
+		*store.journal = append(*store.journal, undo)
+	}
+}
+
+func (store *MemoryStore) Atomically(fn func(txStore Store) error) error {
+	txStore := &MemoryStore{
+		tables:  store.tables,
+		journal: new([]func()),
+	}
+
+	err := fn(txStore)
+	if err != nil {
+		store.tables.Lock()
+		defer store.tables.Unlock()
+
+		for i := len(*txStore.journal) - 1; i >= 0; i-- {
+			(*txStore.journal)[i]()
+		}
+
+		return err
+	}
+
+	// If nested, the enclosing call may still have to undo these
+	// writes
+	store.tables.Lock()
+	store.record(func() {
+		for i := len(*txStore.journal) - 1; i >= 0; i-- {
+			(*txStore.journal)[i]()
+		}
+	})
+	store.tables.Unlock()
+
+	return nil
+}
+
+func (store *MemoryStore) ClearDB() error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	store.tables.clear()
+
+	return nil
+}
+
+func (store *MemoryStore) CreateBlock(block *Block) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	if _, ok := store.tables.blocks[block.Hash]; ok {
+		return errDuplicateKey("blocks", block.Hash)
+	}
+
+	if block.Finality == "" {
+		block.Finality = FinalityUnsafe
+	}
+
+	store.tables.blocks[block.Hash] = *block
+	store.record(func() { delete(store.tables.blocks, block.Hash) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteBlock(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	block, ok := store.tables.blocks[blockHash]
+	if !ok {
+		return nil
+	}
+
+	delete(store.tables.blocks, blockHash)
+	store.record(func() { store.tables.blocks[blockHash] = block })
+
+	return nil
+}
+
+func (store *MemoryStore) GetHead() (*Block, error) {
+	return store.GetHeadByFinality()
+}
+
+// Fetches the latest block whose finality is one of the given
+// finalities, or the latest block if none are given
+func (store *MemoryStore) GetHeadByFinality(finalities ...string) (*Block, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	var head *Block
+	for _, block := range store.tables.blocks {
+		if len(finalities) > 0 && !containsString(finalities, block.Finality) {
+			continue
+		}
+
+		if head == nil || compareNumerics(block.Number, head.Number) > 0 {
+			block := block
+			head = &block
+		}
+	}
+
+	if head == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return head, nil
+}
+
+func (store *MemoryStore) GetFinalizedHead() (*Block, error) {
+	return store.GetHeadByFinality(FinalityFinalized)
+}
+
+// Finalized blocks are necessarily safe
+func (store *MemoryStore) GetSafeHead() (*Block, error) {
+	return store.GetHeadByFinality(FinalitySafe, FinalityFinalized)
+}
+
+func (store *MemoryStore) GetBlockByHash(blockHash string) (*Block, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	block, ok := store.tables.blocks[blockHash]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &block, nil
+}
+
+func (store *MemoryStore) GetBlockByNumber(blockNumber pgtype.Numeric) (*Block, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	for _, block := range store.tables.blocks {
+		if compareNumerics(block.Number, blockNumber) == 0 {
+			return &block, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+// Sets the finality of every canonical block up to (and including)
+// blockNumber whose finality is one of fromFinalities
+func (store *MemoryStore) setFinalityUpTo(blockNumber pgtype.Numeric, finality string, fromFinalities ...string) {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for hash, block := range store.tables.blocks {
+		if compareNumerics(block.Number, blockNumber) > 0 || !containsString(fromFinalities, block.Finality) {
+			continue
+		}
+
+		hash, previousFinality := hash, block.Finality
+		block.Finality = finality
+		store.tables.blocks[hash] = block
+		store.record(func() {
+			block := store.tables.blocks[hash]
+			block.Finality = previousFinality
+			store.tables.blocks[hash] = block
+		})
+	}
+}
+
+func (store *MemoryStore) FinalizeBlocksUpTo(blockNumber pgtype.Numeric) error {
+	store.setFinalityUpTo(blockNumber, FinalityFinalized, FinalityUnsafe, FinalitySafe)
+	return nil
+}
+
+func (store *MemoryStore) MarkBlocksSafeUpTo(blockNumber pgtype.Numeric) error {
+	store.setFinalityUpTo(blockNumber, FinalitySafe, FinalityUnsafe)
+	return nil
+}
+
+func (store *MemoryStore) CreateOrphanedBlock(orphanedBlock *OrphanedBlock) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	if _, ok := store.tables.orphanedBlocks[orphanedBlock.Hash]; ok {
+		return errDuplicateKey("orphaned_blocks", orphanedBlock.Hash)
+	}
+
+	store.tables.orphanedBlocks[orphanedBlock.Hash] = *orphanedBlock
+	store.record(func() { delete(store.tables.orphanedBlocks, orphanedBlock.Hash) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedBlock(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	orphanedBlock, ok := store.tables.orphanedBlocks[orphanedBlockHash]
+	if !ok {
+		return nil
+	}
+
+	delete(store.tables.orphanedBlocks, orphanedBlockHash)
+	store.record(func() { store.tables.orphanedBlocks[orphanedBlockHash] = orphanedBlock })
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedBlockByHash(orphanedBlockHash string) (*OrphanedBlock, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedBlock, ok := store.tables.orphanedBlocks[orphanedBlockHash]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &orphanedBlock, nil
+}
+
+func (store *MemoryStore) GetAllOrphanedBlocks() ([]OrphanedBlock, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedBlocks := []OrphanedBlock{}
+	for _, orphanedBlock := range store.tables.orphanedBlocks {
+		orphanedBlocks = append(orphanedBlocks, orphanedBlock)
+	}
+
+	sort.Slice(orphanedBlocks, func(i, j int) bool {
+		return compareNumerics(orphanedBlocks[i].Number, orphanedBlocks[j].Number) < 0
+	})
+
+	return orphanedBlocks, nil
+}
+
+// Deletes every orphaned block up to (and including) blockNumber,
+// along with its orphaned transactions, receipts, logs, withdrawals,
+// uncles, internal transactions and balance deltas
+func (store *MemoryStore) DeleteOrphanedBlocksUpTo(blockNumber pgtype.Numeric) error {
+	orphanedBlocks, err := store.GetAllOrphanedBlocks()
+	if err != nil {
+		return err
+	}
+
+	for _, orphanedBlock := range orphanedBlocks {
+		if compareNumerics(orphanedBlock.Number, blockNumber) > 0 {
+			break
+		}
+
+		err = store.DeleteOrphanedLogsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedReceiptsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedUnclesForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedInternalTransactionsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedBalanceDeltasForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedWithdrawalsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedTransactionsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = store.DeleteOrphanedBlock(orphanedBlock.Hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) CreateTransaction(transaction *Transaction) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	if _, ok := store.tables.transactions[transaction.Hash]; ok {
+		return errDuplicateKey("transactions", transaction.Hash)
+	}
+
+	store.tables.transactions[transaction.Hash] = *transaction
+	store.record(func() { delete(store.tables.transactions, transaction.Hash) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteTransactionsForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for hash, transaction := range store.tables.transactions {
+		if transaction.BlockHash != blockHash {
+			continue
+		}
+
+		hash, transaction := hash, transaction
+		delete(store.tables.transactions, hash)
+		store.record(func() { store.tables.transactions[hash] = transaction })
+	}
+
+	return nil
+}
+
+func sortTransactions(transactions []Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		comparison := compareNumerics(transactions[i].BlockNumber, transactions[j].BlockNumber)
+		return comparison < 0 || (comparison == 0 && transactions[i].TransactionIndex < transactions[j].TransactionIndex)
+	})
+}
+
+func (store *MemoryStore) GetTransactionsForBlockHash(blockHash string) ([]Transaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	transactions := []Transaction{}
+	for _, transaction := range store.tables.transactions {
+		if transaction.BlockHash == blockHash {
+			transactions = append(transactions, transaction)
+		}
+	}
+
+	sortTransactions(transactions)
+
+	return transactions, nil
+}
+
+func (store *MemoryStore) GetTransactionByHash(transactionHash string, includeBlock bool) (*Transaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	transaction, ok := store.tables.transactions[transactionHash]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	if includeBlock {
+		transaction.Block = store.tables.blocks[transaction.BlockHash]
+	}
+
+	return &transaction, nil
+}
+
+func (store *MemoryStore) GetMostExpensiveTransactionForBlockHash(blockHash string) (*Transaction, error) {
+	transactions, err := store.GetTransactionsForBlockHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return mostExpensiveTransaction(transactions)
+}
+
+func (store *MemoryStore) GetTransactionsByAddress(filter TransactionFilter) ([]Transaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	transactions := []Transaction{}
+	for _, transaction := range store.tables.transactions {
+		switch filter.Direction {
+		case TransactionDirectionIn:
+			if transaction.To != filter.Address {
+				continue
+			}
+		case TransactionDirectionOut:
+			if transaction.From != filter.Address {
+				continue
+			}
+		default:
+			if transaction.From != filter.Address && transaction.To != filter.Address {
+				continue
+			}
+		}
+
+		if filter.FromBlock != nil && compareNumerics(transaction.BlockNumber, *filter.FromBlock) < 0 {
+			continue
+		}
+
+		if filter.ToBlock != nil && compareNumerics(transaction.BlockNumber, *filter.ToBlock) > 0 {
+			continue
+		}
+
+		if !isAfterPosition(transaction.BlockNumber, transaction.TransactionIndex, filter.After) {
+			continue
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	sortTransactions(transactions)
+
+	if filter.Limit > 0 && len(transactions) > filter.Limit {
+		transactions = transactions[:filter.Limit]
+	}
+
+	return transactions, nil
+}
+
+func (store *MemoryStore) CreateOrphanedTransaction(orphanedTransaction *OrphanedTransaction) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedTransaction.Hash, orphanedTransaction.OrphanedBlockHash)
+	if _, ok := store.tables.orphanedTransactions[key]; ok {
+		return errDuplicateKey("orphaned_transactions", key)
+	}
+
+	store.tables.orphanedTransactions[key] = *orphanedTransaction
+	store.record(func() { delete(store.tables.orphanedTransactions, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedTransactionsForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedTransaction := range store.tables.orphanedTransactions {
+		if orphanedTransaction.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedTransaction := key, orphanedTransaction
+		delete(store.tables.orphanedTransactions, key)
+		store.record(func() { store.tables.orphanedTransactions[key] = orphanedTransaction })
+	}
+
+	return nil
+}
+
+func sortOrphanedTransactions(orphanedTransactions []OrphanedTransaction) {
+	sort.Slice(orphanedTransactions, func(i, j int) bool {
+		if orphanedTransactions[i].OrphanedBlockHash != orphanedTransactions[j].OrphanedBlockHash {
+			return orphanedTransactions[i].OrphanedBlockHash < orphanedTransactions[j].OrphanedBlockHash
+		}
+
+		return orphanedTransactions[i].TransactionIndex < orphanedTransactions[j].TransactionIndex
+	})
+}
+
+func (store *MemoryStore) GetOrphanedTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedTransactions := []OrphanedTransaction{}
+	for _, orphanedTransaction := range store.tables.orphanedTransactions {
+		if orphanedTransaction.OrphanedBlockHash == orphanedBlockHash {
+			orphanedTransactions = append(orphanedTransactions, orphanedTransaction)
+		}
+	}
+
+	sortOrphanedTransactions(orphanedTransactions)
+
+	return orphanedTransactions, nil
+}
+
+func (store *MemoryStore) GetOrphanedTransactionsByHash(orphanedTransactionHash string) ([]OrphanedTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedTransactions := []OrphanedTransaction{}
+	for _, orphanedTransaction := range store.tables.orphanedTransactions {
+		if orphanedTransaction.Hash == orphanedTransactionHash {
+			orphanedTransaction.OrphanedBlock = store.tables.orphanedBlocks[orphanedTransaction.OrphanedBlockHash]
+			orphanedTransactions = append(orphanedTransactions, orphanedTransaction)
+		}
+	}
+
+	sortOrphanedTransactions(orphanedTransactions)
+
+	return orphanedTransactions, nil
+}
+
+func (store *MemoryStore) GetOrphanedTransactionByHashAndBlockHash(orphanedTransactionHash, orphanedBlockHash string) (*OrphanedTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedTransaction, ok := store.tables.orphanedTransactions[memoryKey(orphanedTransactionHash, orphanedBlockHash)]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &orphanedTransaction, nil
+}
+
+func (store *MemoryStore) CreateWithdrawal(withdrawal *Withdrawal) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(withdrawal.BlockHash, withdrawal.WithdrawalIndex)
+	if _, ok := store.tables.withdrawals[key]; ok {
+		return errDuplicateKey("withdrawals", key)
+	}
+
+	store.tables.withdrawals[key] = *withdrawal
+	store.record(func() { delete(store.tables.withdrawals, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteWithdrawalsForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, withdrawal := range store.tables.withdrawals {
+		if withdrawal.BlockHash != blockHash {
+			continue
+		}
+
+		key, withdrawal := key, withdrawal
+		delete(store.tables.withdrawals, key)
+		store.record(func() { store.tables.withdrawals[key] = withdrawal })
+	}
+
+	return nil
+}
+
+func sortWithdrawals(withdrawals []Withdrawal) {
+	sort.Slice(withdrawals, func(i, j int) bool {
+		comparison := compareNumerics(withdrawals[i].BlockNumber, withdrawals[j].BlockNumber)
+		return comparison < 0 || (comparison == 0 && withdrawals[i].WithdrawalIndex < withdrawals[j].WithdrawalIndex)
+	})
+}
+
+func (store *MemoryStore) GetWithdrawalsForBlockHash(blockHash string) ([]Withdrawal, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	withdrawals := []Withdrawal{}
+	for _, withdrawal := range store.tables.withdrawals {
+		if withdrawal.BlockHash == blockHash {
+			withdrawals = append(withdrawals, withdrawal)
+		}
+	}
+
+	sortWithdrawals(withdrawals)
+
+	return withdrawals, nil
+}
+
+func (store *MemoryStore) GetWithdrawalsByAddress(filter WithdrawalFilter) ([]Withdrawal, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	withdrawals := []Withdrawal{}
+	for _, withdrawal := range store.tables.withdrawals {
+		if withdrawal.Address != filter.Address {
+			continue
+		}
+
+		if filter.FromBlock != nil && compareNumerics(withdrawal.BlockNumber, *filter.FromBlock) < 0 {
+			continue
+		}
+
+		if filter.ToBlock != nil && compareNumerics(withdrawal.BlockNumber, *filter.ToBlock) > 0 {
+			continue
+		}
+
+		if !isAfterPosition(withdrawal.BlockNumber, uint(withdrawal.WithdrawalIndex), filter.After) {
+			continue
+		}
+
+		withdrawals = append(withdrawals, withdrawal)
+	}
+
+	sortWithdrawals(withdrawals)
+
+	if filter.Limit > 0 && len(withdrawals) > filter.Limit {
+		withdrawals = withdrawals[:filter.Limit]
+	}
+
+	return withdrawals, nil
+}
+
+func (store *MemoryStore) CreateOrphanedWithdrawal(orphanedWithdrawal *OrphanedWithdrawal) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedWithdrawal.OrphanedBlockHash, orphanedWithdrawal.WithdrawalIndex)
+	if _, ok := store.tables.orphanedWithdrawals[key]; ok {
+		return errDuplicateKey("orphaned_withdrawals", key)
+	}
+
+	store.tables.orphanedWithdrawals[key] = *orphanedWithdrawal
+	store.record(func() { delete(store.tables.orphanedWithdrawals, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedWithdrawal := range store.tables.orphanedWithdrawals {
+		if orphanedWithdrawal.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedWithdrawal := key, orphanedWithdrawal
+		delete(store.tables.orphanedWithdrawals, key)
+		store.record(func() { store.tables.orphanedWithdrawals[key] = orphanedWithdrawal })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) ([]OrphanedWithdrawal, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedWithdrawals := []OrphanedWithdrawal{}
+	for _, orphanedWithdrawal := range store.tables.orphanedWithdrawals {
+		if orphanedWithdrawal.OrphanedBlockHash == orphanedBlockHash {
+			orphanedWithdrawals = append(orphanedWithdrawals, orphanedWithdrawal)
+		}
+	}
+
+	sort.Slice(orphanedWithdrawals, func(i, j int) bool {
+		return orphanedWithdrawals[i].WithdrawalIndex < orphanedWithdrawals[j].WithdrawalIndex
+	})
+
+	return orphanedWithdrawals, nil
+}
+
+func (store *MemoryStore) CreateUncle(uncle *Uncle) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(uncle.BlockHash, uncle.UncleIndex)
+	if _, ok := store.tables.uncles[key]; ok {
+		return errDuplicateKey("uncles", key)
+	}
+
+	store.tables.uncles[key] = *uncle
+	store.record(func() { delete(store.tables.uncles, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteUnclesForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, uncle := range store.tables.uncles {
+		if uncle.BlockHash != blockHash {
+			continue
+		}
+
+		key, uncle := key, uncle
+		delete(store.tables.uncles, key)
+		store.record(func() { store.tables.uncles[key] = uncle })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetUnclesForBlockHash(blockHash string) ([]Uncle, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	uncles := []Uncle{}
+	for _, uncle := range store.tables.uncles {
+		if uncle.BlockHash == blockHash {
+			uncles = append(uncles, uncle)
+		}
+	}
+
+	sort.Slice(uncles, func(i, j int) bool {
+		return uncles[i].UncleIndex < uncles[j].UncleIndex
+	})
+
+	return uncles, nil
+}
+
+func (store *MemoryStore) GetUncleByHash(uncleHash string) (*Uncle, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	for _, uncle := range store.tables.uncles {
+		if uncle.Hash == uncleHash {
+			return &uncle, nil
+		}
+	}
+
+	return nil, ErrRecordNotFound
+}
+
+func (store *MemoryStore) CreateOrphanedUncle(orphanedUncle *OrphanedUncle) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedUncle.OrphanedBlockHash, orphanedUncle.UncleIndex)
+	if _, ok := store.tables.orphanedUncles[key]; ok {
+		return errDuplicateKey("orphaned_uncles", key)
+	}
+
+	store.tables.orphanedUncles[key] = *orphanedUncle
+	store.record(func() { delete(store.tables.orphanedUncles, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedUnclesForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedUncle := range store.tables.orphanedUncles {
+		if orphanedUncle.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedUncle := key, orphanedUncle
+		delete(store.tables.orphanedUncles, key)
+		store.record(func() { store.tables.orphanedUncles[key] = orphanedUncle })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedUnclesForBlockHash(orphanedBlockHash string) ([]OrphanedUncle, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedUncles := []OrphanedUncle{}
+	for _, orphanedUncle := range store.tables.orphanedUncles {
+		if orphanedUncle.OrphanedBlockHash == orphanedBlockHash {
+			orphanedUncles = append(orphanedUncles, orphanedUncle)
+		}
+	}
+
+	sort.Slice(orphanedUncles, func(i, j int) bool {
+		return orphanedUncles[i].UncleIndex < orphanedUncles[j].UncleIndex
+	})
+
+	return orphanedUncles, nil
+}
+
+func (store *MemoryStore) CreateInternalTransaction(internalTransaction *InternalTransaction) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(internalTransaction.BlockHash, internalTransaction.TraceIndex)
+	if _, ok := store.tables.internalTransactions[key]; ok {
+		return errDuplicateKey("internal_transactions", key)
+	}
+
+	store.tables.internalTransactions[key] = *internalTransaction
+	store.record(func() { delete(store.tables.internalTransactions, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteInternalTransactionsForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, internalTransaction := range store.tables.internalTransactions {
+		if internalTransaction.BlockHash != blockHash {
+			continue
+		}
+
+		key, internalTransaction := key, internalTransaction
+		delete(store.tables.internalTransactions, key)
+		store.record(func() { store.tables.internalTransactions[key] = internalTransaction })
+	}
+
+	return nil
+}
+
+func sortInternalTransactions(internalTransactions []InternalTransaction) {
+	sort.Slice(internalTransactions, func(i, j int) bool {
+		comparison := compareNumerics(internalTransactions[i].BlockNumber, internalTransactions[j].BlockNumber)
+		return comparison < 0 || (comparison == 0 && internalTransactions[i].TraceIndex < internalTransactions[j].TraceIndex)
+	})
+}
+
+func (store *MemoryStore) GetInternalTransactionsForBlockHash(blockHash string) ([]InternalTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	internalTransactions := []InternalTransaction{}
+	for _, internalTransaction := range store.tables.internalTransactions {
+		if internalTransaction.BlockHash == blockHash {
+			internalTransactions = append(internalTransactions, internalTransaction)
+		}
+	}
+
+	sortInternalTransactions(internalTransactions)
+
+	return internalTransactions, nil
+}
+
+func (store *MemoryStore) GetInternalTransactionsByTransactionHash(transactionHash string) ([]InternalTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	internalTransactions := []InternalTransaction{}
+	for _, internalTransaction := range store.tables.internalTransactions {
+		if internalTransaction.TransactionHash == transactionHash {
+			internalTransactions = append(internalTransactions, internalTransaction)
+		}
+	}
+
+	sortInternalTransactions(internalTransactions)
+
+	return internalTransactions, nil
+}
+
+func (store *MemoryStore) GetInternalTransactionsByAddress(filter TransactionFilter) ([]InternalTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	internalTransactions := []InternalTransaction{}
+	for _, internalTransaction := range store.tables.internalTransactions {
+		switch filter.Direction {
+		case TransactionDirectionIn:
+			if internalTransaction.To != filter.Address {
+				continue
+			}
+		case TransactionDirectionOut:
+			if internalTransaction.From != filter.Address {
+				continue
+			}
+		default:
+			if internalTransaction.From != filter.Address && internalTransaction.To != filter.Address {
+				continue
+			}
+		}
+
+		if filter.FromBlock != nil && compareNumerics(internalTransaction.BlockNumber, *filter.FromBlock) < 0 {
+			continue
+		}
+
+		if filter.ToBlock != nil && compareNumerics(internalTransaction.BlockNumber, *filter.ToBlock) > 0 {
+			continue
+		}
+
+		if !isAfterPosition(internalTransaction.BlockNumber, internalTransaction.TraceIndex, filter.After) {
+			continue
+		}
+
+		internalTransactions = append(internalTransactions, internalTransaction)
+	}
+
+	sortInternalTransactions(internalTransactions)
+
+	if filter.Limit > 0 && len(internalTransactions) > filter.Limit {
+		internalTransactions = internalTransactions[:filter.Limit]
+	}
+
+	return internalTransactions, nil
+}
+
+func (store *MemoryStore) CreateOrphanedInternalTransaction(orphanedInternalTransaction *OrphanedInternalTransaction) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedInternalTransaction.OrphanedBlockHash, orphanedInternalTransaction.TraceIndex)
+	if _, ok := store.tables.orphanedInternalTransactions[key]; ok {
+		return errDuplicateKey("orphaned_internal_transactions", key)
+	}
+
+	store.tables.orphanedInternalTransactions[key] = *orphanedInternalTransaction
+	store.record(func() { delete(store.tables.orphanedInternalTransactions, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedInternalTransaction := range store.tables.orphanedInternalTransactions {
+		if orphanedInternalTransaction.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedInternalTransaction := key, orphanedInternalTransaction
+		delete(store.tables.orphanedInternalTransactions, key)
+		store.record(func() { store.tables.orphanedInternalTransactions[key] = orphanedInternalTransaction })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedInternalTransaction, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedInternalTransactions := []OrphanedInternalTransaction{}
+	for _, orphanedInternalTransaction := range store.tables.orphanedInternalTransactions {
+		if orphanedInternalTransaction.OrphanedBlockHash == orphanedBlockHash {
+			orphanedInternalTransactions = append(orphanedInternalTransactions, orphanedInternalTransaction)
+		}
+	}
+
+	sort.Slice(orphanedInternalTransactions, func(i, j int) bool {
+		return orphanedInternalTransactions[i].TraceIndex < orphanedInternalTransactions[j].TraceIndex
+	})
+
+	return orphanedInternalTransactions, nil
+}
+
+func (store *MemoryStore) CreateBalance(balance *Balance) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(balance.Address, balance.BlockHash)
+	if _, ok := store.tables.balances[key]; ok {
+		return errDuplicateKey("balances", key)
+	}
+
+	store.tables.balances[key] = *balance
+	store.record(func() { delete(store.tables.balances, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteBalancesForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, balance := range store.tables.balances {
+		if balance.BlockHash != blockHash {
+			continue
+		}
+
+		key, balance := key, balance
+		delete(store.tables.balances, key)
+		store.record(func() { store.tables.balances[key] = balance })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetAddressBalanceByBlockHash(address, blockHash string) (*Balance, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	balance, ok := store.tables.balances[memoryKey(address, blockHash)]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &balance, nil
+}
+
+func sortBalances(balances []Balance) {
+	sort.Slice(balances, func(i, j int) bool {
+		return compareNumerics(balances[i].BlockNumber, balances[j].BlockNumber) < 0
+	})
+}
+
+func (store *MemoryStore) GetLatestAddressBalance(address string, blockNumber pgtype.Numeric) (*Balance, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	var latestBalance *Balance
+	for _, balance := range store.tables.balances {
+		if balance.Address != address || compareNumerics(balance.BlockNumber, blockNumber) > 0 {
+			continue
+		}
+
+		if latestBalance == nil || compareNumerics(balance.BlockNumber, latestBalance.BlockNumber) > 0 {
+			balance := balance
+			latestBalance = &balance
+		}
+	}
+
+	if latestBalance == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return latestBalance, nil
+}
+
+func (store *MemoryStore) GetAddressBalances(filter BalanceFilter) ([]Balance, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	balances := []Balance{}
+	for _, balance := range store.tables.balances {
+		if balance.Address != filter.Address {
+			continue
+		}
+
+		if filter.FromBlock != nil && compareNumerics(balance.BlockNumber, *filter.FromBlock) < 0 {
+			continue
+		}
+
+		if filter.ToBlock != nil && compareNumerics(balance.BlockNumber, *filter.ToBlock) > 0 {
+			continue
+		}
+
+		if filter.After != nil && compareNumerics(balance.BlockNumber, filter.After.BlockNumber) <= 0 {
+			continue
+		}
+
+		balances = append(balances, balance)
+	}
+
+	sortBalances(balances)
+
+	if filter.Limit > 0 && len(balances) > filter.Limit {
+		balances = balances[:filter.Limit]
+	}
+
+	return balances, nil
+}
+
+func (store *MemoryStore) CreateBalanceDelta(balanceDelta *BalanceDelta) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(balanceDelta.Address, balanceDelta.BlockHash)
+	if _, ok := store.tables.balanceDeltas[key]; ok {
+		return errDuplicateKey("balance_deltas", key)
+	}
+
+	store.tables.balanceDeltas[key] = *balanceDelta
+	store.record(func() { delete(store.tables.balanceDeltas, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) SaveBalanceDelta(balanceDelta *BalanceDelta) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(balanceDelta.Address, balanceDelta.BlockHash)
+	previousBalanceDelta, existed := store.tables.balanceDeltas[key]
+	store.tables.balanceDeltas[key] = *balanceDelta
+	store.record(func() {
+		if existed {
+			store.tables.balanceDeltas[key] = previousBalanceDelta
+		} else {
+			delete(store.tables.balanceDeltas, key)
+		}
+	})
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteBalanceDeltasForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, balanceDelta := range store.tables.balanceDeltas {
+		if balanceDelta.BlockHash != blockHash {
+			continue
+		}
+
+		key, balanceDelta := key, balanceDelta
+		delete(store.tables.balanceDeltas, key)
+		store.record(func() { store.tables.balanceDeltas[key] = balanceDelta })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetBalanceDeltasForBlockHash(blockHash string) ([]BalanceDelta, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	balanceDeltas := []BalanceDelta{}
+	for _, balanceDelta := range store.tables.balanceDeltas {
+		if balanceDelta.BlockHash == blockHash {
+			balanceDeltas = append(balanceDeltas, balanceDelta)
+		}
+	}
+
+	sort.Slice(balanceDeltas, func(i, j int) bool {
+		return balanceDeltas[i].Address < balanceDeltas[j].Address
+	})
+
+	return balanceDeltas, nil
+}
+
+func (store *MemoryStore) GetLatestAddressBalanceDelta(address string, blockNumber pgtype.Numeric) (*BalanceDelta, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	var latestBalanceDelta *BalanceDelta
+	for _, balanceDelta := range store.tables.balanceDeltas {
+		if balanceDelta.Address != address || compareNumerics(balanceDelta.BlockNumber, blockNumber) > 0 {
+			continue
+		}
+
+		if latestBalanceDelta == nil || compareNumerics(balanceDelta.BlockNumber, latestBalanceDelta.BlockNumber) > 0 {
+			balanceDelta := balanceDelta
+			latestBalanceDelta = &balanceDelta
+		}
+	}
+
+	if latestBalanceDelta == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return latestBalanceDelta, nil
+}
+
+func (store *MemoryStore) GetLaterAddressBalanceDeltas(address string, blockNumber pgtype.Numeric) ([]BalanceDelta, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	balanceDeltas := []BalanceDelta{}
+	for _, balanceDelta := range store.tables.balanceDeltas {
+		if balanceDelta.Address == address && compareNumerics(balanceDelta.BlockNumber, blockNumber) > 0 {
+			balanceDeltas = append(balanceDeltas, balanceDelta)
+		}
+	}
+
+	sort.Slice(balanceDeltas, func(i, j int) bool {
+		return compareNumerics(balanceDeltas[i].BlockNumber, balanceDeltas[j].BlockNumber) < 0
+	})
+
+	return balanceDeltas, nil
+}
+
+func (store *MemoryStore) CreateOrphanedBalanceDelta(orphanedBalanceDelta *OrphanedBalanceDelta) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedBalanceDelta.Address, orphanedBalanceDelta.OrphanedBlockHash)
+	if _, ok := store.tables.orphanedBalanceDeltas[key]; ok {
+		return errDuplicateKey("orphaned_balance_deltas", key)
+	}
+
+	store.tables.orphanedBalanceDeltas[key] = *orphanedBalanceDelta
+	store.record(func() { delete(store.tables.orphanedBalanceDeltas, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedBalanceDelta := range store.tables.orphanedBalanceDeltas {
+		if orphanedBalanceDelta.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedBalanceDelta := key, orphanedBalanceDelta
+		delete(store.tables.orphanedBalanceDeltas, key)
+		store.record(func() { store.tables.orphanedBalanceDeltas[key] = orphanedBalanceDelta })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) ([]OrphanedBalanceDelta, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedBalanceDeltas := []OrphanedBalanceDelta{}
+	for _, orphanedBalanceDelta := range store.tables.orphanedBalanceDeltas {
+		if orphanedBalanceDelta.OrphanedBlockHash == orphanedBlockHash {
+			orphanedBalanceDeltas = append(orphanedBalanceDeltas, orphanedBalanceDelta)
+		}
+	}
+
+	sort.Slice(orphanedBalanceDeltas, func(i, j int) bool {
+		return orphanedBalanceDeltas[i].Address < orphanedBalanceDeltas[j].Address
+	})
+
+	return orphanedBalanceDeltas, nil
+}
+
+func (store *MemoryStore) CreateReceipt(receipt *Receipt) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	if _, ok := store.tables.receipts[receipt.TransactionHash]; ok {
+		return errDuplicateKey("receipts", receipt.TransactionHash)
+	}
+
+	store.tables.receipts[receipt.TransactionHash] = *receipt
+	store.record(func() { delete(store.tables.receipts, receipt.TransactionHash) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteReceiptsForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, receipt := range store.tables.receipts {
+		if receipt.BlockHash != blockHash {
+			continue
+		}
+
+		key, receipt := key, receipt
+		delete(store.tables.receipts, key)
+		store.record(func() { store.tables.receipts[key] = receipt })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetReceiptsForBlockHash(blockHash string) ([]Receipt, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	receipts := []Receipt{}
+	for _, receipt := range store.tables.receipts {
+		if receipt.BlockHash == blockHash {
+			receipts = append(receipts, receipt)
+		}
+	}
+
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].TransactionIndex < receipts[j].TransactionIndex
+	})
+
+	return receipts, nil
+}
+
+func (store *MemoryStore) GetReceiptByTransactionHash(transactionHash string) (*Receipt, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	receipt, ok := store.tables.receipts[transactionHash]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	receipt.Logs = []Log{}
+	for _, log := range store.tables.logs {
+		if log.TransactionHash == transactionHash {
+			receipt.Logs = append(receipt.Logs, log)
+		}
+	}
+
+	sortLogs(receipt.Logs)
+
+	return &receipt, nil
+}
+
+func (store *MemoryStore) CreateOrphanedReceipt(orphanedReceipt *OrphanedReceipt) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedReceipt.TransactionHash, orphanedReceipt.OrphanedBlockHash)
+	if _, ok := store.tables.orphanedReceipts[key]; ok {
+		return errDuplicateKey("orphaned_receipts", key)
+	}
+
+	store.tables.orphanedReceipts[key] = *orphanedReceipt
+	store.record(func() { delete(store.tables.orphanedReceipts, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedReceiptsForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedReceipt := range store.tables.orphanedReceipts {
+		if orphanedReceipt.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedReceipt := key, orphanedReceipt
+		delete(store.tables.orphanedReceipts, key)
+		store.record(func() { store.tables.orphanedReceipts[key] = orphanedReceipt })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedReceiptsForBlockHash(orphanedBlockHash string) ([]OrphanedReceipt, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedReceipts := []OrphanedReceipt{}
+	for _, orphanedReceipt := range store.tables.orphanedReceipts {
+		if orphanedReceipt.OrphanedBlockHash == orphanedBlockHash {
+			orphanedReceipts = append(orphanedReceipts, orphanedReceipt)
+		}
+	}
+
+	sort.Slice(orphanedReceipts, func(i, j int) bool {
+		return orphanedReceipts[i].TransactionIndex < orphanedReceipts[j].TransactionIndex
+	})
+
+	return orphanedReceipts, nil
+}
+
+func (store *MemoryStore) CreateLog(log *Log) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(log.BlockHash, log.LogIndex)
+	if _, ok := store.tables.logs[key]; ok {
+		return errDuplicateKey("logs", key)
+	}
+
+	store.tables.logs[key] = *log
+	store.record(func() { delete(store.tables.logs, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteLogsForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, log := range store.tables.logs {
+		if log.BlockHash != blockHash {
+			continue
+		}
+
+		key, log := key, log
+		delete(store.tables.logs, key)
+		store.record(func() { store.tables.logs[key] = log })
+	}
+
+	return nil
+}
+
+func sortLogs(logs []Log) {
+	sort.Slice(logs, func(i, j int) bool {
+		comparison := compareNumerics(logs[i].BlockNumber, logs[j].BlockNumber)
+		return comparison < 0 || (comparison == 0 && logs[i].LogIndex < logs[j].LogIndex)
+	})
+}
+
+func (store *MemoryStore) GetLogsForBlockHash(blockHash string, addresses []string) ([]Log, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	logs := []Log{}
+	for _, log := range store.tables.logs {
+		if log.BlockHash != blockHash {
+			continue
+		}
+
+		if len(addresses) > 0 && !containsString(addresses, log.Address) {
+			continue
+		}
+
+		logs = append(logs, log)
+	}
+
+	sortLogs(logs)
+
+	return logs, nil
+}
+
+func (store *MemoryStore) GetLogs(filter LogFilter) ([]Log, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	logs := []Log{}
+	for _, log := range store.tables.logs {
+		if compareNumerics(log.BlockNumber, filter.FromBlock) < 0 || compareNumerics(log.BlockNumber, filter.ToBlock) > 0 {
+			continue
+		}
+
+		if len(filter.Addresses) > 0 && !containsString(filter.Addresses, log.Address) {
+			continue
+		}
+
+		topics := [4]string{log.Topic0, log.Topic1, log.Topic2, log.Topic3}
+		matchesTopics := true
+		for i := range filter.Topics {
+			if len(filter.Topics[i]) > 0 && !containsString(filter.Topics[i], topics[i]) {
+				matchesTopics = false
+			}
+		}
+
+		if !matchesTopics || !isAfterPosition(log.BlockNumber, log.LogIndex, filter.After) {
+			continue
+		}
+
+		logs = append(logs, log)
+	}
+
+	sortLogs(logs)
+
+	if filter.Limit > 0 && len(logs) > filter.Limit {
+		logs = logs[:filter.Limit]
+	}
+
+	return logs, nil
+}
+
+func (store *MemoryStore) CreateOrphanedLog(orphanedLog *OrphanedLog) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(orphanedLog.OrphanedBlockHash, orphanedLog.LogIndex)
+	if _, ok := store.tables.orphanedLogs[key]; ok {
+		return errDuplicateKey("orphaned_logs", key)
+	}
+
+	store.tables.orphanedLogs[key] = *orphanedLog
+	store.record(func() { delete(store.tables.orphanedLogs, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteOrphanedLogsForBlockHash(orphanedBlockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, orphanedLog := range store.tables.orphanedLogs {
+		if orphanedLog.OrphanedBlockHash != orphanedBlockHash {
+			continue
+		}
+
+		key, orphanedLog := key, orphanedLog
+		delete(store.tables.orphanedLogs, key)
+		store.record(func() { store.tables.orphanedLogs[key] = orphanedLog })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetOrphanedLogsForBlockHash(orphanedBlockHash string) ([]OrphanedLog, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	orphanedLogs := []OrphanedLog{}
+	for _, orphanedLog := range store.tables.orphanedLogs {
+		if orphanedLog.OrphanedBlockHash == orphanedBlockHash {
+			orphanedLogs = append(orphanedLogs, orphanedLog)
+		}
+	}
+
+	sort.Slice(orphanedLogs, func(i, j int) bool {
+		return orphanedLogs[i].LogIndex < orphanedLogs[j].LogIndex
+	})
+
+	return orphanedLogs, nil
+}
+
+func (store *MemoryStore) CreateTokenTransfer(tokenTransfer *TokenTransfer) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(tokenTransfer.BlockHash, tokenTransfer.LogIndex)
+	if _, ok := store.tables.tokenTransfers[key]; ok {
+		return errDuplicateKey("token_transfers", key)
+	}
+
+	store.tables.tokenTransfers[key] = *tokenTransfer
+	store.record(func() { delete(store.tables.tokenTransfers, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteTokenTransfersForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, tokenTransfer := range store.tables.tokenTransfers {
+		if tokenTransfer.BlockHash != blockHash {
+			continue
+		}
+
+		key, tokenTransfer := key, tokenTransfer
+		delete(store.tables.tokenTransfers, key)
+		store.record(func() { store.tables.tokenTransfers[key] = tokenTransfer })
+	}
+
+	return nil
+}
+
+func sortTokenTransfers(tokenTransfers []TokenTransfer) {
+	sort.Slice(tokenTransfers, func(i, j int) bool {
+		comparison := compareNumerics(tokenTransfers[i].BlockNumber, tokenTransfers[j].BlockNumber)
+		return comparison < 0 || (comparison == 0 && tokenTransfers[i].LogIndex < tokenTransfers[j].LogIndex)
+	})
+}
+
+func (store *MemoryStore) GetTokenTransfersForBlockHash(blockHash string) ([]TokenTransfer, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	tokenTransfers := []TokenTransfer{}
+	for _, tokenTransfer := range store.tables.tokenTransfers {
+		if tokenTransfer.BlockHash == blockHash {
+			tokenTransfers = append(tokenTransfers, tokenTransfer)
+		}
+	}
+
+	sortTokenTransfers(tokenTransfers)
+
+	return tokenTransfers, nil
+}
+
+func (store *MemoryStore) GetTokenTransfers(filter TokenTransferFilter) ([]TokenTransfer, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	tokenTransfers := []TokenTransfer{}
+	for _, tokenTransfer := range store.tables.tokenTransfers {
+		if tokenTransfer.From != filter.Address && tokenTransfer.To != filter.Address {
+			continue
+		}
+
+		if filter.Token != "" && tokenTransfer.Token != filter.Token {
+			continue
+		}
+
+		if !isAfterPosition(tokenTransfer.BlockNumber, tokenTransfer.LogIndex, filter.After) {
+			continue
+		}
+
+		tokenTransfers = append(tokenTransfers, tokenTransfer)
+	}
+
+	sortTokenTransfers(tokenTransfers)
+
+	if filter.Limit > 0 && len(tokenTransfers) > filter.Limit {
+		tokenTransfers = tokenTransfers[:filter.Limit]
+	}
+
+	return tokenTransfers, nil
+}
+
+func (store *MemoryStore) CreateTokenBalanceDelta(tokenBalanceDelta *TokenBalanceDelta) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(tokenBalanceDelta.Token, tokenBalanceDelta.Holder, tokenBalanceDelta.BlockHash)
+	if _, ok := store.tables.tokenBalanceDeltas[key]; ok {
+		return errDuplicateKey("token_balance_deltas", key)
+	}
+
+	store.tables.tokenBalanceDeltas[key] = *tokenBalanceDelta
+	store.record(func() { delete(store.tables.tokenBalanceDeltas, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteTokenBalanceDeltasForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, tokenBalanceDelta := range store.tables.tokenBalanceDeltas {
+		if tokenBalanceDelta.BlockHash != blockHash {
+			continue
+		}
+
+		key, tokenBalanceDelta := key, tokenBalanceDelta
+		delete(store.tables.tokenBalanceDeltas, key)
+		store.record(func() { store.tables.tokenBalanceDeltas[key] = tokenBalanceDelta })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetTokenBalance(token, holder string, blockNumber pgtype.Numeric) (*big.Int, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	balance := new(big.Int)
+	for _, tokenBalanceDelta := range store.tables.tokenBalanceDeltas {
+		if tokenBalanceDelta.Token != token || tokenBalanceDelta.Holder != holder || compareNumerics(tokenBalanceDelta.BlockNumber, blockNumber) > 0 {
+			continue
+		}
+
+		balance.Add(balance, NumericToBigInt(tokenBalanceDelta.Delta))
+	}
+
+	return balance, nil
+}
+
+func (store *MemoryStore) CreateNFTTransfer(nftTransfer *NFTTransfer) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(nftTransfer.BlockHash, nftTransfer.LogIndex, nftTransfer.BatchIndex)
+	if _, ok := store.tables.nftTransfers[key]; ok {
+		return errDuplicateKey("nft_transfers", key)
+	}
+
+	store.tables.nftTransfers[key] = *nftTransfer
+	store.record(func() { delete(store.tables.nftTransfers, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteNFTTransfersForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, nftTransfer := range store.tables.nftTransfers {
+		if nftTransfer.BlockHash != blockHash {
+			continue
+		}
+
+		key, nftTransfer := key, nftTransfer
+		delete(store.tables.nftTransfers, key)
+		store.record(func() { store.tables.nftTransfers[key] = nftTransfer })
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) GetNFTTransfersForBlockHash(blockHash string) ([]NFTTransfer, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	nftTransfers := []NFTTransfer{}
+	for _, nftTransfer := range store.tables.nftTransfers {
+		if nftTransfer.BlockHash == blockHash {
+			nftTransfers = append(nftTransfers, nftTransfer)
+		}
+	}
+
+	sort.Slice(nftTransfers, func(i, j int) bool {
+		if nftTransfers[i].LogIndex != nftTransfers[j].LogIndex {
+			return nftTransfers[i].LogIndex < nftTransfers[j].LogIndex
+		}
+
+		return nftTransfers[i].BatchIndex < nftTransfers[j].BatchIndex
+	})
+
+	return nftTransfers, nil
+}
+
+func (store *MemoryStore) CreateNFTBalanceDelta(nftBalanceDelta *NFTBalanceDelta) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(nftBalanceDelta.Token, nftBalanceDelta.TokenID, nftBalanceDelta.Holder, nftBalanceDelta.BlockHash)
+	if _, ok := store.tables.nftBalanceDeltas[key]; ok {
+		return errDuplicateKey("nft_balance_deltas", key)
+	}
+
+	store.tables.nftBalanceDeltas[key] = *nftBalanceDelta
+	store.record(func() { delete(store.tables.nftBalanceDeltas, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) DeleteNFTBalanceDeltasForBlockHash(blockHash string) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	for key, nftBalanceDelta := range store.tables.nftBalanceDeltas {
+		if nftBalanceDelta.BlockHash != blockHash {
+			continue
+		}
+
+		key, nftBalanceDelta := key, nftBalanceDelta
+		delete(store.tables.nftBalanceDeltas, key)
+		store.record(func() { store.tables.nftBalanceDeltas[key] = nftBalanceDelta })
+	}
+
+	return nil
+}
+
+// Fetches the NFT balance deltas matching keep, in chain order
+func (store *MemoryStore) getNFTBalanceDeltas(keep func(nftBalanceDelta NFTBalanceDelta) bool) []NFTBalanceDelta {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	nftBalanceDeltas := []NFTBalanceDelta{}
+	for _, nftBalanceDelta := range store.tables.nftBalanceDeltas {
+		if keep(nftBalanceDelta) {
+			nftBalanceDeltas = append(nftBalanceDeltas, nftBalanceDelta)
+		}
+	}
+
+	sort.Slice(nftBalanceDeltas, func(i, j int) bool {
+		comparison := compareNumerics(nftBalanceDeltas[i].BlockNumber, nftBalanceDeltas[j].BlockNumber)
+		if comparison != 0 {
+			return comparison < 0
+		}
+
+		return memoryKey(nftBalanceDeltas[i].Token, nftBalanceDeltas[i].TokenID, nftBalanceDeltas[i].Holder) < memoryKey(nftBalanceDeltas[j].Token, nftBalanceDeltas[j].TokenID, nftBalanceDeltas[j].Holder)
+	})
+
+	return nftBalanceDeltas
+}
+
+func (store *MemoryStore) GetNFTHoldingsForToken(token string, tokenID pgtype.Numeric, blockNumber pgtype.Numeric) ([]NFTHolding, error) {
+	return sumNFTBalanceDeltas(store.getNFTBalanceDeltas(func(nftBalanceDelta NFTBalanceDelta) bool {
+		return nftBalanceDelta.Token == token &&
+			compareNumerics(nftBalanceDelta.TokenID, tokenID) == 0 &&
+			compareNumerics(nftBalanceDelta.BlockNumber, blockNumber) <= 0
+	}))
+}
+
+func (store *MemoryStore) GetNFTHoldingsForHolder(holder string, blockNumber pgtype.Numeric) ([]NFTHolding, error) {
+	return sumNFTBalanceDeltas(store.getNFTBalanceDeltas(func(nftBalanceDelta NFTBalanceDelta) bool {
+		return nftBalanceDelta.Holder == holder &&
+			compareNumerics(nftBalanceDelta.BlockNumber, blockNumber) <= 0
+	}))
+}
+
+func (store *MemoryStore) GetBackfillProgress(fromBlock, toBlock uint64) (*BackfillProgress, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	backfillProgress, ok := store.tables.backfillProgresses[memoryKey(fromBlock, toBlock)]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &backfillProgress, nil
+}
+
+func (store *MemoryStore) SaveBackfillProgress(backfillProgress *BackfillProgress) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := memoryKey(backfillProgress.FromBlock, backfillProgress.ToBlock)
+	previousBackfillProgress, existed := store.tables.backfillProgresses[key]
+	store.tables.backfillProgresses[key] = *backfillProgress
+	store.record(func() {
+		if existed {
+			store.tables.backfillProgresses[key] = previousBackfillProgress
+		} else {
+			delete(store.tables.backfillProgresses, key)
+		}
+	})
+
+	return nil
+}
+
+func (store *MemoryStore) CreateTrackedAddress(trackedAddress *TrackedAddress) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := trackedAddress.Address
+	if _, ok := store.tables.trackedAddresses[key]; ok {
+		return errDuplicateKey("tracked_addresses", key)
+	}
+
+	store.tables.trackedAddresses[key] = *trackedAddress
+	store.record(func() { delete(store.tables.trackedAddresses, key) })
+
+	return nil
+}
+
+func (store *MemoryStore) GetTrackedAddress(address string) (*TrackedAddress, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	trackedAddress, ok := store.tables.trackedAddresses[address]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	return &trackedAddress, nil
+}
+
+func (store *MemoryStore) GetTrackedAddresses() ([]TrackedAddress, error) {
+	store.tables.RLock()
+	defer store.tables.RUnlock()
+
+	trackedAddresses := []TrackedAddress{}
+	for _, trackedAddress := range store.tables.trackedAddresses {
+		if trackedAddress.RemovedAt == nil {
+			trackedAddresses = append(trackedAddresses, trackedAddress)
+		}
+	}
+
+	sort.Slice(trackedAddresses, func(i, j int) bool {
+		return trackedAddresses[i].Address < trackedAddresses[j].Address
+	})
+
+	return trackedAddresses, nil
+}
+
+func (store *MemoryStore) SaveTrackedAddress(trackedAddress *TrackedAddress) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := trackedAddress.Address
+	previousTrackedAddress, existed := store.tables.trackedAddresses[key]
+	store.tables.trackedAddresses[key] = *trackedAddress
+	store.record(func() {
+		if existed {
+			store.tables.trackedAddresses[key] = previousTrackedAddress
+		} else {
+			delete(store.tables.trackedAddresses, key)
+		}
+	})
+
+	return nil
+}
+
+func (store *MemoryStore) SaveTrackedAddressBackfill(trackedAddress *TrackedAddress) error {
+	store.tables.Lock()
+	defer store.tables.Unlock()
+
+	key := trackedAddress.Address
+	previousTrackedAddress, ok := store.tables.trackedAddresses[key]
+	if !ok {
+		return nil
+	}
+
+	updatedTrackedAddress := previousTrackedAddress
+	updatedTrackedAddress.BackfillPending = trackedAddress.BackfillPending
+	updatedTrackedAddress.BackfillFromBlock = trackedAddress.BackfillFromBlock
+	updatedTrackedAddress.BackfillNextBlock = trackedAddress.BackfillNextBlock
+	store.tables.trackedAddresses[key] = updatedTrackedAddress
+	store.record(func() { store.tables.trackedAddresses[key] = previousTrackedAddress })
+
+	return nil
+}
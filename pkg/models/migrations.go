@@ -0,0 +1,311 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Versioned change to the schema. Migrations are applied in order of
+// version, each in its own database transaction, and once released
+// must never change: later schema changes go in new migrations
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Every migration, in order of version
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_initial_schema",
+		Up:      createInitialSchema,
+		Down:    dropInitialSchema,
+	},
+	{
+		Version: 2,
+		Name:    "add_signatures_and_access_lists",
+		Up:      addSignaturesAndAccessLists,
+		Down:    dropSignaturesAndAccessLists,
+	},
+	{
+		Version: 3,
+		Name:    "add_blob_gas",
+		Up:      addBlobGas,
+		Down:    dropBlobGas,
+	},
+	{
+		Version: 4,
+		Name:    "add_withdrawals",
+		Up:      addWithdrawals,
+		Down:    dropWithdrawals,
+	},
+	{
+		Version: 5,
+		Name:    "add_uncles",
+		Up:      addUncles,
+		Down:    dropUncles,
+	},
+	{
+		Version: 6,
+		Name:    "add_internal_transactions",
+		Up:      addInternalTransactions,
+		Down:    dropInternalTransactions,
+	},
+	{
+		Version: 7,
+		Name:    "add_balance_deltas",
+		Up:      addBalanceDeltas,
+		Down:    dropBalanceDeltas,
+	},
+	{
+		Version: 8,
+		Name:    "add_tracked_addresses",
+		Up:      addTrackedAddresses,
+		Down:    dropTrackedAddresses,
+	},
+	{
+		Version: 9,
+		Name:    "add_balance_block_numbers",
+		Up:      addBalanceBlockNumbers,
+		Down:    dropBalanceBlockNumbers,
+	},
+	{
+		Version: 10,
+		Name:    "add_balance_delta_balances",
+		Up:      addBalanceDeltaBalances,
+		Down:    dropBalanceDeltaBalances,
+	},
+	{
+		Version: 11,
+		Name:    "checksum_tracked_addresses",
+		Up:      checksumTrackedAddresses,
+		Down:    revertChecksumTrackedAddresses,
+	},
+	{
+		Version: 12,
+		Name:    "add_tracked_address_removals",
+		Up:      addTrackedAddressRemovals,
+		Down:    dropTrackedAddressRemovals,
+	},
+	{
+		Version: 13,
+		Name:    "add_tracked_address_backfill_progress",
+		Up:      addTrackedAddressBackfillProgress,
+		Down:    dropTrackedAddressBackfillProgress,
+	},
+}
+
+// Schema version that this build expects
+func LatestSchemaVersion() uint {
+	return Migrations[len(Migrations)-1].Version
+}
+
+// Record of an applied migration, in the schema_migrations table
+type SchemaMigration struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+// State of a migration in the database
+type MigrationStatus struct {
+	Migration
+	// Zero if the migration hasn't been applied
+	AppliedAt time.Time
+}
+
+var ErrUnexpectedSchemaVersion = errors.New("Unexpected schema version")
+
+// Key of the PostgreSQL advisory lock held while migrating, so that
+// concurrent migrations wait for each other
+const migrationLockKey = 7400312
+
+// The table is created with plain SQL (valid for both PostgreSQL and
+// SQLite) so that creating it can't race
+func (db *DB) createSchemaMigrationsTable() error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+}
+
+func (db *DB) getSchemaMigrations() ([]SchemaMigration, error) {
+	var schemaMigrations []SchemaMigration
+	return schemaMigrations, db.Order("version").Find(&schemaMigrations).Error
+}
+
+// Fetches the version of the latest applied migration, 0 if none
+func (db *DB) GetSchemaVersion() (uint, error) {
+	if !db.Migrator().HasTable("schema_migrations") {
+		return 0, nil
+	}
+
+	schemaMigrations, err := db.getSchemaMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(schemaMigrations) == 0 {
+		return 0, nil
+	}
+
+	return schemaMigrations[len(schemaMigrations)-1].Version, nil
+}
+
+// Fails with ErrUnexpectedSchemaVersion unless the database's schema
+// is the one this build expects
+func (db *DB) CheckSchemaVersion() error {
+	schemaVersion, err := db.GetSchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if schemaVersion < LatestSchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d, expected %d, run `migrate up` first", ErrUnexpectedSchemaVersion, schemaVersion, LatestSchemaVersion())
+	}
+
+	if schemaVersion > LatestSchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d, newer than the expected %d", ErrUnexpectedSchemaVersion, schemaVersion, LatestSchemaVersion())
+	}
+
+	return nil
+}
+
+// Fetches every known migration along with when it was applied
+func (db *DB) GetMigrationStatuses() ([]MigrationStatus, error) {
+	err := db.createSchemaMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+
+	schemaMigrations, err := db.getSchemaMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[uint]time.Time, len(schemaMigrations))
+	for _, schemaMigration := range schemaMigrations {
+		appliedAt[schemaMigration.Version] = schemaMigration.AppliedAt
+	}
+
+	migrationStatuses := make([]MigrationStatus, len(Migrations))
+	for i, migration := range Migrations {
+		migrationStatuses[i] = MigrationStatus{
+			Migration: migration,
+			AppliedAt: appliedAt[migration.Version],
+		}
+	}
+
+	return migrationStatuses, nil
+}
+
+// Runs fn in a transaction that holds the migration lock, if the
+// database supports it
+func (db *DB) migrateAtomically(fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if !db.isSQLite() {
+			err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return fn(tx)
+	})
+}
+
+func isMigrationApplied(tx *gorm.DB, version uint) (bool, error) {
+	var count int64
+	err := tx.Model(&SchemaMigration{}).Where("version = ?", version).Count(&count).Error
+	return count > 0, err
+}
+
+// Applies every pending migration, in order, and returns the ones that
+// were applied
+func (db *DB) MigrateUp() ([]Migration, error) {
+	err := db.createSchemaMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+
+	appliedMigrations := []Migration{}
+	for _, migration := range Migrations {
+		applied := false
+		err = db.migrateAtomically(func(tx *gorm.DB) error {
+			// Another process may have applied it while we
+			// waited for the lock
+			isApplied, err := isMigrationApplied(tx, migration.Version)
+			if err != nil || isApplied {
+				return err
+			}
+
+			err = migration.Up(tx)
+			if err != nil {
+				return err
+			}
+
+			applied = true
+
+			return tx.Create(&SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().UTC(),
+			}).Error
+		})
+		if err != nil {
+			return appliedMigrations, fmt.Errorf("Migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		if applied {
+			appliedMigrations = append(appliedMigrations, migration)
+		}
+	}
+
+	return appliedMigrations, nil
+}
+
+// Reverts the latest steps applied migrations, in reverse order, and
+// returns the ones that were reverted
+func (db *DB) MigrateDown(steps int) ([]Migration, error) {
+	err := db.createSchemaMigrationsTable()
+	if err != nil {
+		return nil, err
+	}
+
+	revertedMigrations := []Migration{}
+	for i := len(Migrations) - 1; i >= 0 && len(revertedMigrations) < steps; i-- {
+		migration := Migrations[i]
+
+		reverted := false
+		err = db.migrateAtomically(func(tx *gorm.DB) error {
+			isApplied, err := isMigrationApplied(tx, migration.Version)
+			if err != nil || !isApplied {
+				return err
+			}
+
+			err = migration.Down(tx)
+			if err != nil {
+				return err
+			}
+
+			reverted = true
+
+			return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return revertedMigrations, fmt.Errorf("Reverting migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+
+		if reverted {
+			revertedMigrations = append(revertedMigrations, migration)
+		}
+	}
+
+	return revertedMigrations, nil
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"strings"
+
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -9,36 +11,106 @@ type DB struct {
 	*gorm.DB
 }
 
+// Connects to the database, which is a SQLite database if
+// connectionString starts with SQLitePrefix and a PostgreSQL database
+// otherwise, and fails with ErrUnexpectedSchemaVersion unless its
+// schema is up to date
 func (db *DB) Initialize(connectionString string) error {
-	var err error
-	db.DB, err = gorm.Open(postgres.Open(connectionString), &gorm.Config{})
+	err := db.Connect(connectionString)
 	if err != nil {
 		return err
 	}
 
-	err = db.InitializeModels()
-	if err != nil {
-		return err
+	return db.CheckSchemaVersion()
+}
+
+// Connects to the database without checking its schema, used to
+// migrate it
+func (db *DB) Connect(connectionString string) error {
+	var err error
+
+	dialector := postgres.Open(connectionString)
+	if strings.HasPrefix(connectionString, SQLitePrefix) {
+		dialector, err = openSQLite(strings.TrimPrefix(connectionString, SQLitePrefix))
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	db.DB, err = gorm.Open(dialector, &gorm.Config{})
+	return err
 }
 
-func (db *DB) InitializeModels() error {
-	return db.AutoMigrate(
-		&Block{},
-		&OrphanedBlock{},
-		&Transaction{},
-		&OrphanedTransaction{},
-		&Balance{},
-	)
+// Runs fn against a database transaction, which is committed if fn
+// returns nil and rolled back otherwise. Nested calls use savepoints
+func (db *DB) Atomically(fn func(txStore Store) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return fn(&DB{tx})
+	})
 }
 
 func (db *DB) ClearDB() error {
 	tempDB := db.Session(&gorm.Session{AllowGlobalUpdate: true})
 
-	// Delete transactions
-	err := tempDB.Unscoped().Delete(&Transaction{}).Error
+	// Delete token transfers and balance deltas
+	err := tempDB.Unscoped().Delete(&TokenTransfer{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tempDB.Unscoped().Delete(&TokenBalanceDelta{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete NFT transfers and balance deltas
+	err = tempDB.Unscoped().Delete(&NFTTransfer{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tempDB.Unscoped().Delete(&NFTBalanceDelta{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete logs
+	err = tempDB.Unscoped().Delete(&Log{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete receipts
+	err = tempDB.Unscoped().Delete(&Receipt{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete access lists and transactions
+	err = tempDB.Unscoped().Delete(&AccessTuple{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tempDB.Unscoped().Delete(&Transaction{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete withdrawals
+	err = tempDB.Unscoped().Delete(&Withdrawal{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete uncles
+	err = tempDB.Unscoped().Delete(&Uncle{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete internal transactions
+	err = tempDB.Unscoped().Delete(&InternalTransaction{}).Error
 	if err != nil {
 		return err
 	}
@@ -49,13 +121,60 @@ func (db *DB) ClearDB() error {
 		return err
 	}
 
+	// Delete balance deltas
+	err = tempDB.Unscoped().Delete(&BalanceDelta{}).Error
+	if err != nil {
+		return err
+	}
+
 	// Delete blocks
 	err = tempDB.Unscoped().Delete(&Block{}).Error
 	if err != nil {
 		return err
 	}
 
-	// Delete orphaned transactions
+	// Delete orphaned logs
+	err = tempDB.Unscoped().Delete(&OrphanedLog{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned receipts
+	err = tempDB.Unscoped().Delete(&OrphanedReceipt{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned withdrawals
+	err = tempDB.Unscoped().Delete(&OrphanedWithdrawal{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned uncles
+	err = tempDB.Unscoped().Delete(&OrphanedUncle{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned internal transactions
+	err = tempDB.Unscoped().Delete(&OrphanedInternalTransaction{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned balance deltas
+	err = tempDB.Unscoped().Delete(&OrphanedBalanceDelta{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned access lists and transactions
+	err = tempDB.Unscoped().Delete(&OrphanedAccessTuple{}).Error
+	if err != nil {
+		return err
+	}
+
 	err = tempDB.Unscoped().Delete(&OrphanedTransaction{}).Error
 	if err != nil {
 		return err
@@ -67,5 +186,17 @@ func (db *DB) ClearDB() error {
 		return err
 	}
 
+	// Delete backfill progress
+	err = tempDB.Unscoped().Delete(&BackfillProgress{}).Error
+	if err != nil {
+		return err
+	}
+
+	// Delete tracked addresses
+	err = tempDB.Unscoped().Delete(&TrackedAddress{}).Error
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgtype"
+)
+
+// The net change in the number of a given NFT held by a holder over the
+// transfers in a canonical block. Like TokenBalanceDelta, deleting a
+// block's deltas when it is orphaned reverts its transfers
+type NFTBalanceDelta struct {
+	Token       string         `json:"token" gorm:"primaryKey;index:idx_nft_balance_deltas_token_id,priority:1"`
+	TokenID     pgtype.Numeric `json:"token_id" gorm:"primaryKey;index:idx_nft_balance_deltas_token_id,priority:2;type:numeric"`
+	Holder      string         `json:"holder" gorm:"primaryKey;index:idx_nft_balance_deltas_holder,priority:1"`
+	BlockHash   string         `json:"block_hash" gorm:"primaryKey;index"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index:idx_nft_balance_deltas_holder,priority:2;type:numeric"`
+	Delta       pgtype.Numeric `json:"delta" gorm:"type:numeric"`
+}
+
+// A holder's balance of a given NFT (always 1 for ERC-721 tokens)
+type NFTHolding struct {
+	Token   string
+	TokenID pgtype.Numeric
+	Holder  string
+	Balance pgtype.Numeric
+}
+
+// Fetches the holders of the given NFT at the canonical block with
+// blockNumber. ERC-721 tokens have a single holder
+func (db *DB) GetNFTHoldingsForToken(token string, tokenID pgtype.Numeric, blockNumber pgtype.Numeric) ([]NFTHolding, error) {
+	var nftBalanceDeltas []NFTBalanceDelta
+	err := db.Where(
+		"token = ? AND token_id = ? AND block_number <= ?",
+		token,
+		tokenID,
+		blockNumber,
+	).Find(&nftBalanceDeltas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return sumNFTBalanceDeltas(nftBalanceDeltas)
+}
+
+// Fetches the NFTs held by the holder at the canonical block with
+// blockNumber
+func (db *DB) GetNFTHoldingsForHolder(holder string, blockNumber pgtype.Numeric) ([]NFTHolding, error) {
+	var nftBalanceDeltas []NFTBalanceDelta
+	err := db.Where(
+		"holder = ? AND block_number <= ?",
+		holder,
+		blockNumber,
+	).Find(&nftBalanceDeltas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return sumNFTBalanceDeltas(nftBalanceDeltas)
+}
+
+type nftHolder struct {
+	token   string
+	tokenID string
+	holder  string
+}
+
+// Sums the deltas per (token, token ID, holder), keeping the positive
+// balances in the order they were first seen
+func sumNFTBalanceDeltas(nftBalanceDeltas []NFTBalanceDelta) ([]NFTHolding, error) {
+	keys := []nftHolder{}
+	balances := make(map[nftHolder]*big.Int)
+	tokenIDs := make(map[nftHolder]pgtype.Numeric)
+	for _, nftBalanceDelta := range nftBalanceDeltas {
+		key := nftHolder{
+			nftBalanceDelta.Token,
+			NumericToBigInt(nftBalanceDelta.TokenID).String(),
+			nftBalanceDelta.Holder,
+		}
+
+		if balances[key] == nil {
+			keys = append(keys, key)
+			balances[key] = new(big.Int)
+			tokenIDs[key] = nftBalanceDelta.TokenID
+		}
+
+		balances[key].Add(balances[key], NumericToBigInt(nftBalanceDelta.Delta))
+	}
+
+	nftHoldings := []NFTHolding{}
+	for _, key := range keys {
+		if balances[key].Sign() <= 0 {
+			continue
+		}
+
+		balance := new(pgtype.Numeric)
+		err := balance.Set(balances[key].String())
+		if err != nil {
+			return nil, err
+		}
+
+		nftHoldings = append(nftHoldings, NFTHolding{
+			Token:   key.token,
+			TokenID: tokenIDs[key],
+			Holder:  key.holder,
+			Balance: *balance,
+		})
+	}
+
+	return nftHoldings, nil
+}
+
+func (db *DB) CreateNFTBalanceDelta(nftBalanceDelta *NFTBalanceDelta) error {
+	return db.Create(nftBalanceDelta).Error
+}
+
+func (db *DB) DeleteNFTBalanceDeltasForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&NFTBalanceDelta{}).Error
+}
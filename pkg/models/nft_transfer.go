@@ -0,0 +1,43 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+const (
+	NFTStandardERC721  = "erc721"
+	NFTStandardERC1155 = "erc1155"
+)
+
+// An ERC-721 Transfer, or ERC-1155 TransferSingle/TransferBatch event
+// emitted in a canonical block. A TransferBatch event is split into a
+// transfer per token ID
+type NFTTransfer struct {
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	// Index of the transfer's log in the block
+	LogIndex uint `json:"log_index" gorm:"primaryKey;autoIncrement:false"`
+	// Index of the transfer in its TransferBatch event, 0 otherwise
+	BatchIndex uint           `json:"batch_index" gorm:"primaryKey;autoIncrement:false"`
+	Standard   string         `json:"standard"`
+	Token      string         `json:"token" gorm:"index:idx_nft_transfers_token_id,priority:1"`
+	TokenID    pgtype.Numeric `json:"token_id" gorm:"index:idx_nft_transfers_token_id,priority:2;type:numeric"`
+	// Address that sent the transfer on behalf of From (ERC-1155
+	// only)
+	Operator        string         `json:"operator"`
+	From            string         `json:"from" gorm:"index"`
+	To              string         `json:"to" gorm:"index"`
+	Value           pgtype.Numeric `json:"value" gorm:"type:numeric"`
+	BlockNumber     pgtype.Numeric `json:"block_number" gorm:"index;type:numeric"`
+	TransactionHash string         `json:"transaction_hash" gorm:"index"`
+}
+
+func (db *DB) GetNFTTransfersForBlockHash(blockHash string) ([]NFTTransfer, error) {
+	var nftTransfers []NFTTransfer
+	return nftTransfers, db.Where("block_hash = ?", blockHash).Order("log_index, batch_index").Find(&nftTransfers).Error
+}
+
+func (db *DB) CreateNFTTransfer(nftTransfer *NFTTransfer) error {
+	return db.Create(nftTransfer).Error
+}
+
+func (db *DB) DeleteNFTTransfersForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&NFTTransfer{}).Error
+}
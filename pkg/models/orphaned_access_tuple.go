@@ -0,0 +1,10 @@
+package models
+
+type OrphanedAccessTuple struct {
+	TransactionHash   string `json:"transaction_hash" gorm:"primaryKey"`
+	OrphanedBlockHash string `json:"orphaned_block_hash" gorm:"primaryKey"`
+	// Index of the tuple in the access list
+	TupleIndex  uint        `json:"tuple_index" gorm:"primaryKey;autoIncrement:false"`
+	Address     string      `json:"address"`
+	StorageKeys StorageKeys `json:"storage_keys" gorm:"type:text"`
+}
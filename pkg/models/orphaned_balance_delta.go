@@ -0,0 +1,27 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedBalanceDelta struct {
+	Address           string         `json:"address" gorm:"primaryKey"`
+	OrphanedBlockHash string         `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock  `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	BlockNumber       pgtype.Numeric `json:"block_number" gorm:"type:numeric"`
+	Delta             pgtype.Numeric `json:"delta" gorm:"type:numeric"`
+	// Balance before the block, nil if the block's transactions
+	// didn't touch the address (i.e. it only received withdrawals)
+	PreviousBalance *pgtype.Numeric `json:"previous_balance" gorm:"type:numeric"`
+}
+
+func (db *DB) GetOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) ([]OrphanedBalanceDelta, error) {
+	var orphanedBalanceDeltas []OrphanedBalanceDelta
+	return orphanedBalanceDeltas, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Order("address").Find(&orphanedBalanceDeltas).Error
+}
+
+func (db *DB) CreateOrphanedBalanceDelta(orphanedBalanceDelta *OrphanedBalanceDelta) error {
+	return db.Create(orphanedBalanceDelta).Error
+}
+
+func (db *DB) DeleteOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedBalanceDelta{}).Error
+}
@@ -21,7 +21,13 @@ type OrphanedBlock struct {
 	Extra       []byte         `json:"extra"`
 	MixDigest   string         `json:"mix_digest"`
 	Nonce       pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
-	BaseFee     pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+	// Only set since EIP-1559 (London)
+	BaseFee *pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+	// Only set since EIP-4844 (Cancun)
+	BlobGasUsed   *uint64 `json:"blob_gas_used"`
+	ExcessBlobGas *uint64 `json:"excess_blob_gas"`
+	// Only set since Shanghai
+	WithdrawalsRoot string `json:"withdrawals_root"`
 }
 
 func (db *DB) GetOrphanedBlockByHash(orphanedBlockHash string) (*OrphanedBlock, error) {
@@ -33,3 +39,62 @@ func (db *DB) GetAllOrphanedBlocks() ([]OrphanedBlock, error) {
 	var orphanedBlocks []OrphanedBlock
 	return orphanedBlocks, db.Find(&orphanedBlocks).Error
 }
+
+// Deletes every orphaned block up to (and including) blockNumber,
+// along with its orphaned transactions, receipts, logs, withdrawals,
+// uncles, internal transactions and balance deltas. Used to stop
+// keeping orphans behind the finalized head, as they can never be
+// canonicalized
+func (db *DB) DeleteOrphanedBlocksUpTo(blockNumber pgtype.Numeric) error {
+	orphanedBlockHashes := db.Model(&OrphanedBlock{}).Select("hash").Where("number <= ?", blockNumber)
+
+	err := db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedLog{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedReceipt{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedUncle{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedInternalTransaction{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedBalanceDelta{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedWithdrawal{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedAccessTuple{}).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Where("orphaned_block_hash IN (?)", orphanedBlockHashes).Delete(&OrphanedTransaction{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("number <= ?", blockNumber).Delete(&OrphanedBlock{}).Error
+}
+
+func (db *DB) CreateOrphanedBlock(orphanedBlock *OrphanedBlock) error {
+	return db.Create(orphanedBlock).Error
+}
+
+func (db *DB) DeleteOrphanedBlock(orphanedBlockHash string) error {
+	return db.Where("hash = ?", orphanedBlockHash).Delete(&OrphanedBlock{}).Error
+}
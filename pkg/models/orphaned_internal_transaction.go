@@ -0,0 +1,43 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedInternalTransaction struct {
+	OrphanedBlockHash string        `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	// Index of the call among the internal transactions of the
+	// block, in execution order
+	TraceIndex       uint   `json:"trace_index" gorm:"primaryKey;autoIncrement:false"`
+	TransactionHash  string `json:"transaction_hash"`
+	TransactionIndex uint   `json:"transaction_index"`
+	// Position of the call in the transaction's call tree, e.g.
+	// "0.1" is the second call made by the first call the
+	// transaction made
+	TraceAddress string `json:"trace_address"`
+	// CALL, CALLCODE, DELEGATECALL, STATICCALL, CREATE, CREATE2 or
+	// SELFDESTRUCT
+	Type    string         `json:"type"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Value   pgtype.Numeric `json:"value" gorm:"type:numeric"`
+	Gas     uint64         `json:"gas"`
+	GasUsed uint64         `json:"gas_used"`
+	Input   []byte         `json:"input"`
+	Output  []byte         `json:"output"`
+	// Empty unless the call failed, in which case it moved no value
+	Error       string         `json:"error"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"type:numeric"`
+}
+
+func (db *DB) GetOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedInternalTransaction, error) {
+	var orphanedInternalTransactions []OrphanedInternalTransaction
+	return orphanedInternalTransactions, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Order("trace_index").Find(&orphanedInternalTransactions).Error
+}
+
+func (db *DB) CreateOrphanedInternalTransaction(orphanedInternalTransaction *OrphanedInternalTransaction) error {
+	return db.Create(orphanedInternalTransaction).Error
+}
+
+func (db *DB) DeleteOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedInternalTransaction{}).Error
+}
@@ -0,0 +1,31 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedLog struct {
+	OrphanedBlockHash string `json:"orphaned_block_hash" gorm:"primaryKey"`
+	// Index of the log in the block
+	LogIndex         uint           `json:"log_index" gorm:"primaryKey;autoIncrement:false"`
+	Address          string         `json:"address"`
+	Topic0           string         `json:"topic0"`
+	Topic1           string         `json:"topic1"`
+	Topic2           string         `json:"topic2"`
+	Topic3           string         `json:"topic3"`
+	Data             []byte         `json:"data"`
+	BlockNumber      pgtype.Numeric `json:"block_number" gorm:"type:numeric"`
+	TransactionHash  string         `json:"transaction_hash"`
+	TransactionIndex uint           `json:"transaction_index"`
+}
+
+func (db *DB) GetOrphanedLogsForBlockHash(orphanedBlockHash string) ([]OrphanedLog, error) {
+	var orphanedLogs []OrphanedLog
+	return orphanedLogs, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Order("log_index").Find(&orphanedLogs).Error
+}
+
+func (db *DB) CreateOrphanedLog(orphanedLog *OrphanedLog) error {
+	return db.Create(orphanedLog).Error
+}
+
+func (db *DB) DeleteOrphanedLogsForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedLog{}).Error
+}
@@ -0,0 +1,32 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedReceipt struct {
+	TransactionHash   string         `json:"transaction_hash" gorm:"primaryKey"`
+	TransactionIndex  uint           `json:"transaction_index"`
+	Type              byte           `json:"type"`
+	PostState         []byte         `json:"post_state"`
+	Status            uint64         `json:"status"`
+	CumulativeGasUsed uint64         `json:"cumulative_gas_used"`
+	Bloom             []byte         `json:"bloom"`
+	GasUsed           uint64         `json:"gas_used"`
+	EffectiveGasPrice pgtype.Numeric `json:"effective_gas_price" gorm:"type:numeric"`
+	// Empty unless the transaction created a contract
+	ContractAddress   string        `json:"contract_address"`
+	OrphanedBlockHash string        `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+}
+
+func (db *DB) GetOrphanedReceiptsForBlockHash(orphanedBlockHash string) ([]OrphanedReceipt, error) {
+	var orphanedReceipts []OrphanedReceipt
+	return orphanedReceipts, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Find(&orphanedReceipts).Error
+}
+
+func (db *DB) CreateOrphanedReceipt(orphanedReceipt *OrphanedReceipt) error {
+	return db.Create(orphanedReceipt).Error
+}
+
+func (db *DB) DeleteOrphanedReceiptsForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedReceipt{}).Error
+}
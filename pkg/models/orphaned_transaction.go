@@ -8,31 +8,113 @@ type OrphanedTransaction struct {
 	From    string         `json:"from"`
 	Type    byte           `json:"type"`
 	ChainID pgtype.Numeric `json:"chain_id" gorm:"type:numeric"`
-	// TODO: Model for access list tuples?
-	Data      []byte         `json:"data"`
-	Gas       uint64         `json:"gas"`
-	GasPrice  pgtype.Numeric `json:"gas_price" gorm:"type:numeric"`
-	GasTipCap pgtype.Numeric `json:"gas_tip_cap" gorm:"type:numeric"`
-	GasFeeCap pgtype.Numeric `json:"gas_fee_cap" gorm:"type:numeric"`
-	Value     pgtype.Numeric `json:"value" gorm:"type:numeric"`
-	Nonce     pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
-	To        string         `json:"to"`
-	// TODO: Figure out how to handle signatures
-	OrphanedBlockHash string        `json:"orphaned_block_hash" gorm:"primaryKey"`
-	OrphanedBlock     OrphanedBlock `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	// Empty for legacy transactions. Saved and loaded by hand, as
+	// SQLite can't preload associations with composite keys
+	AccessList []OrphanedAccessTuple `json:"access_list" gorm:"-"`
+	Data       []byte                `json:"data"`
+	Gas        uint64                `json:"gas"`
+	GasPrice   pgtype.Numeric        `json:"gas_price" gorm:"type:numeric"`
+	GasTipCap  pgtype.Numeric        `json:"gas_tip_cap" gorm:"type:numeric"`
+	GasFeeCap  pgtype.Numeric        `json:"gas_fee_cap" gorm:"type:numeric"`
+	// Only set for blob transactions
+	BlobGasFeeCap *pgtype.Numeric `json:"blob_gas_fee_cap" gorm:"type:numeric"`
+	BlobHashes    Hashes          `json:"blob_hashes" gorm:"type:text"`
+	Value         pgtype.Numeric  `json:"value" gorm:"type:numeric"`
+	Nonce         pgtype.Numeric  `json:"nonce" gorm:"type:numeric"`
+	To            string          `json:"to"`
+	// Signature values
+	V pgtype.Numeric `json:"v" gorm:"type:numeric"`
+	R pgtype.Numeric `json:"r" gorm:"type:numeric"`
+	S pgtype.Numeric `json:"s" gorm:"type:numeric"`
+	// Binary encoding of the transaction, as sent to the network.
+	// Empty unless the poller was told to store it
+	Raw               []byte         `json:"raw"`
+	OrphanedBlockHash string         `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock  `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	BlockNumber       pgtype.Numeric `json:"block_number" gorm:"type:numeric"`
+	TransactionIndex  uint           `json:"transaction_index"`
+}
+
+// Loads the access lists of the given orphaned transactions, in order
+func (db *DB) loadOrphanedAccessLists(orphanedTransactions []OrphanedTransaction) error {
+	if len(orphanedTransactions) == 0 {
+		return nil
+	}
+
+	orphanedTransactionHashes := make([]string, len(orphanedTransactions))
+	for i, orphanedTransaction := range orphanedTransactions {
+		orphanedTransactionHashes[i] = orphanedTransaction.Hash
+	}
+
+	var orphanedAccessTuples []OrphanedAccessTuple
+	err := db.Where("transaction_hash IN ?", orphanedTransactionHashes).Order("tuple_index").Find(&orphanedAccessTuples).Error
+	if err != nil {
+		return err
+	}
+
+	accessLists := make(map[string][]OrphanedAccessTuple)
+	for _, orphanedAccessTuple := range orphanedAccessTuples {
+		key := orphanedAccessTuple.TransactionHash + "/" + orphanedAccessTuple.OrphanedBlockHash
+		accessLists[key] = append(accessLists[key], orphanedAccessTuple)
+	}
+
+	for i, orphanedTransaction := range orphanedTransactions {
+		orphanedTransactions[i].AccessList = accessLists[orphanedTransaction.Hash+"/"+orphanedTransaction.OrphanedBlockHash]
+		if orphanedTransactions[i].AccessList == nil {
+			orphanedTransactions[i].AccessList = []OrphanedAccessTuple{}
+		}
+	}
+
+	return nil
 }
 
 func (db *DB) GetOrphanedTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedTransaction, error) {
 	var orphanedTransactions []OrphanedTransaction
-	return orphanedTransactions, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Find(&orphanedTransactions).Error
+	err := db.Where("orphaned_block_hash = ?", orphanedBlockHash).Find(&orphanedTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return orphanedTransactions, db.loadOrphanedAccessLists(orphanedTransactions)
 }
 
 func (db *DB) GetOrphanedTransactionsByHash(orphanedTransactionHash string) ([]OrphanedTransaction, error) {
 	var orphanedTransactions []OrphanedTransaction
-	return orphanedTransactions, db.Preload("OrphanedBlock").Where("hash = ?", orphanedTransactionHash).Find(&orphanedTransactions).Error
+	err := db.Preload("OrphanedBlock").Where("hash = ?", orphanedTransactionHash).Find(&orphanedTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return orphanedTransactions, db.loadOrphanedAccessLists(orphanedTransactions)
 }
 
 func (db *DB) GetOrphanedTransactionByHashAndBlockHash(orphanedTransactionHash, orphanedBlockHash string) (*OrphanedTransaction, error) {
 	var orphanedTransaction OrphanedTransaction
-	return &orphanedTransaction, db.Where("hash = ? AND orphaned_block_hash = ?", orphanedTransactionHash, orphanedBlockHash).First(&orphanedTransaction).Error
+	err := db.Where("hash = ? AND orphaned_block_hash = ?", orphanedTransactionHash, orphanedBlockHash).First(&orphanedTransaction).Error
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedTransactions := []OrphanedTransaction{orphanedTransaction}
+	return &orphanedTransactions[0], db.loadOrphanedAccessLists(orphanedTransactions)
+}
+
+// Creates the orphaned transaction along with its access list
+func (db *DB) CreateOrphanedTransaction(orphanedTransaction *OrphanedTransaction) error {
+	err := db.Create(orphanedTransaction).Error
+	if err != nil || len(orphanedTransaction.AccessList) == 0 {
+		return err
+	}
+
+	return db.Create(&orphanedTransaction.AccessList).Error
+}
+
+// Deletes the orphaned transactions along with their access lists
+func (db *DB) DeleteOrphanedTransactionsForBlockHash(orphanedBlockHash string) error {
+	err := db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedAccessTuple{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedTransaction{}).Error
 }
@@ -0,0 +1,43 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedUncle struct {
+	// Hash of the including orphaned block
+	OrphanedBlockHash string        `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	// Index of the uncle in the including block
+	UncleIndex uint   `json:"uncle_index" gorm:"primaryKey;autoIncrement:false"`
+	Hash       string `json:"hash"`
+	// Header fields
+	ParentHash  string         `json:"parent_hash"`
+	UncleHash   string         `json:"uncle_hash"`
+	Coinbase    string         `json:"coinbase"`
+	Root        string         `json:"root"`
+	TxHash      string         `json:"tx_hash"`
+	ReceiptHash string         `json:"receipt_hash"`
+	Bloom       []byte         `json:"bloom"`
+	Difficulty  pgtype.Numeric `json:"difficulty" gorm:"type:numeric"`
+	Number      pgtype.Numeric `json:"number" gorm:"type:numeric"`
+	GasLimit    uint64         `json:"gas_limit"`
+	GasUsed     uint64         `json:"gas_used"`
+	Time        uint64         `json:"time"`
+	Extra       []byte         `json:"extra"`
+	MixDigest   string         `json:"mix_digest"`
+	Nonce       pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
+	// Only set since EIP-1559 (London)
+	BaseFee *pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+}
+
+func (db *DB) GetOrphanedUnclesForBlockHash(orphanedBlockHash string) ([]OrphanedUncle, error) {
+	var orphanedUncles []OrphanedUncle
+	return orphanedUncles, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Order("uncle_index").Find(&orphanedUncles).Error
+}
+
+func (db *DB) CreateOrphanedUncle(orphanedUncle *OrphanedUncle) error {
+	return db.Create(orphanedUncle).Error
+}
+
+func (db *DB) DeleteOrphanedUnclesForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedUncle{}).Error
+}
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+type OrphanedWithdrawal struct {
+	OrphanedBlockHash string        `json:"orphaned_block_hash" gorm:"primaryKey"`
+	OrphanedBlock     OrphanedBlock `json:"orphaned_block" gorm:"foreignKey:OrphanedBlockHash"`
+	// Index of the withdrawal among all withdrawals, as assigned by
+	// the consensus layer
+	WithdrawalIndex uint64 `json:"withdrawal_index" gorm:"primaryKey;autoIncrement:false"`
+	ValidatorIndex  uint64 `json:"validator_index"`
+	Address         string `json:"address"`
+	// In Gwei
+	Amount      uint64         `json:"amount"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"type:numeric"`
+}
+
+func (db *DB) GetOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) ([]OrphanedWithdrawal, error) {
+	var orphanedWithdrawals []OrphanedWithdrawal
+	return orphanedWithdrawals, db.Where("orphaned_block_hash = ?", orphanedBlockHash).Order("withdrawal_index").Find(&orphanedWithdrawals).Error
+}
+
+func (db *DB) CreateOrphanedWithdrawal(orphanedWithdrawal *OrphanedWithdrawal) error {
+	return db.Create(orphanedWithdrawal).Error
+}
+
+func (db *DB) DeleteOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) error {
+	return db.Where("orphaned_block_hash = ?", orphanedBlockHash).Delete(&OrphanedWithdrawal{}).Error
+}
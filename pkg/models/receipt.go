@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+type Receipt struct {
+	TransactionHash   string         `json:"transaction_hash" gorm:"primaryKey"`
+	TransactionIndex  uint           `json:"transaction_index"`
+	Type              byte           `json:"type"`
+	PostState         []byte         `json:"post_state"`
+	Status            uint64         `json:"status"`
+	CumulativeGasUsed uint64         `json:"cumulative_gas_used"`
+	Bloom             []byte         `json:"bloom"`
+	GasUsed           uint64         `json:"gas_used"`
+	EffectiveGasPrice pgtype.Numeric `json:"effective_gas_price" gorm:"type:numeric"`
+	// Empty unless the transaction created a contract
+	ContractAddress string `json:"contract_address"`
+	Logs            []Log  `json:"logs" gorm:"foreignKey:TransactionHash;references:TransactionHash"`
+	BlockHash       string `json:"block_hash" gorm:"index"`
+	Block           Block  `json:"block" gorm:"foreignKey:BlockHash"`
+}
+
+func (db *DB) GetReceiptsForBlockHash(blockHash string) ([]Receipt, error) {
+	var receipts []Receipt
+	return receipts, db.Where("block_hash = ?", blockHash).Find(&receipts).Error
+}
+
+func (db *DB) GetReceiptByTransactionHash(transactionHash string) (*Receipt, error) {
+	var receipt Receipt
+	return &receipt, db.Preload("Logs", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("log_index")
+	}).Where("transaction_hash = ?", transactionHash).First(&receipt).Error
+}
+
+func (db *DB) CreateReceipt(receipt *Receipt) error {
+	return db.Create(receipt).Error
+}
+
+func (db *DB) DeleteReceiptsForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&Receipt{}).Error
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// Connection strings starting with this prefix open the SQLite
+// database at the path that follows, e.g. "sqlite://getherscan.db".
+// SQLite connection parameters can be appended to the path, e.g.
+// "sqlite://getherscan.db?_busy_timeout=5000"
+const SQLitePrefix = "sqlite://"
+
+// SQLite has no arbitrary precision numbers: its numeric columns
+// store anything that doesn't fit in 64 bits as a float. Numeric
+// columns are stored as text instead, with non-negative values
+// zero-padded to a fixed width, so that comparing and ordering them
+// as text is the same as comparing and ordering them as numbers.
+// This width fits any uint256
+const sqliteNumericWidth = 78
+
+// Replaces pgtype.Numeric arguments with their SQLite encoding
+func encodeSQLiteArgs(args []interface{}) []interface{} {
+	encodedArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		encodedArgs[i] = arg
+
+		// Nullable numeric columns are pointers
+		if numericPointer, ok := arg.(*pgtype.Numeric); ok && numericPointer != nil {
+			arg = *numericPointer
+		}
+
+		numeric, ok := arg.(pgtype.Numeric)
+		if !ok || numeric.Status != pgtype.Present {
+			continue
+		}
+
+		value := NumericToBigInt(numeric)
+		if value.Sign() < 0 {
+			// Only balance deltas can be negative, and they
+			// are never compared in queries
+			encodedArgs[i] = value.String()
+		} else {
+			encodedArgs[i] = fmt.Sprintf("%0*s", sqliteNumericWidth, value.String())
+		}
+	}
+
+	return encodedArgs
+}
+
+// Connection pool that encodes numeric arguments before they reach
+// SQLite. Values read back are decoded by pgtype.Numeric's Scan as is
+type sqliteConnPool struct {
+	*sql.DB
+}
+
+func (connPool *sqliteConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return connPool.DB.ExecContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+func (connPool *sqliteConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return connPool.DB.QueryContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+func (connPool *sqliteConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return connPool.DB.QueryRowContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+func (connPool *sqliteConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	tx, err := connPool.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteTx{tx}, nil
+}
+
+func (connPool *sqliteConnPool) GetDBConn() (*sql.DB, error) {
+	return connPool.DB, nil
+}
+
+type sqliteTx struct {
+	*sql.Tx
+}
+
+func (tx *sqliteTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+func (tx *sqliteTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+func (tx *sqliteTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, query, encodeSQLiteArgs(args)...)
+}
+
+// SQLite dialector that creates numeric columns as text
+type sqliteDialector struct {
+	sqlite.Dialector
+}
+
+func (dialector sqliteDialector) DataTypeOf(field *schema.Field) string {
+	if field.DataType == "numeric" {
+		return "text"
+	}
+
+	return dialector.Dialector.DataTypeOf(field)
+}
+
+// Same as the SQLite migrator, but with the dialector above so that
+// it picks up the numeric column type
+func (dialector sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqlite.Migrator{Migrator: migrator.Migrator{Config: migrator.Config{
+		DB:                          db,
+		Dialector:                   dialector,
+		CreateIndexAfterCreateTable: true,
+	}}}
+}
+
+func openSQLite(path string) (gorm.Dialector, error) {
+	db, err := sql.Open(sqlite.DriverName, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite only allows one writer at a time, so we don't let
+	// concurrent writes within the process fail with "database
+	// is locked"
+	db.SetMaxOpenConns(1)
+
+	return sqliteDialector{sqlite.Dialector{
+		DriverName: sqlite.DriverName,
+		DSN:        path,
+		Conn:       &sqliteConnPool{db},
+	}}, nil
+}
+
+func (db *DB) isSQLite() bool {
+	return db.Dialector.Name() == "sqlite"
+}
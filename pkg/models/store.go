@@ -0,0 +1,166 @@
+package models
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Returned by the Store's single record lookups when there is no
+// matching record
+var ErrRecordNotFound = gorm.ErrRecordNotFound
+
+// Every read and write the poller and API server make against the
+// index. DB (PostgreSQL, or SQLite for connection strings starting
+// with SQLitePrefix, through gorm) is the default implementation,
+// MemoryStore keeps everything in memory
+type Store interface {
+	// Runs fn against a transactional view of the store, whose
+	// writes are committed if fn returns nil and discarded
+	// otherwise. Nested calls behave the same way
+	Atomically(fn func(txStore Store) error) error
+
+	// Deletes every record, used in testing
+	ClearDB() error
+
+	CreateBlock(block *Block) error
+	DeleteBlock(blockHash string) error
+	// Fetches the canonical block with the highest number
+	GetHead() (*Block, error)
+	GetFinalizedHead() (*Block, error)
+	GetSafeHead() (*Block, error)
+	GetBlockByHash(blockHash string) (*Block, error)
+	GetBlockByNumber(blockNumber pgtype.Numeric) (*Block, error)
+	FinalizeBlocksUpTo(blockNumber pgtype.Numeric) error
+	MarkBlocksSafeUpTo(blockNumber pgtype.Numeric) error
+
+	CreateOrphanedBlock(orphanedBlock *OrphanedBlock) error
+	DeleteOrphanedBlock(orphanedBlockHash string) error
+	GetOrphanedBlockByHash(orphanedBlockHash string) (*OrphanedBlock, error)
+	GetAllOrphanedBlocks() ([]OrphanedBlock, error)
+	DeleteOrphanedBlocksUpTo(blockNumber pgtype.Numeric) error
+
+	CreateTransaction(transaction *Transaction) error
+	DeleteTransactionsForBlockHash(blockHash string) error
+	GetTransactionsForBlockHash(blockHash string) ([]Transaction, error)
+	GetTransactionByHash(transactionHash string, includeBlock bool) (*Transaction, error)
+	GetMostExpensiveTransactionForBlockHash(blockHash string) (*Transaction, error)
+	GetTransactionsByAddress(filter TransactionFilter) ([]Transaction, error)
+
+	CreateOrphanedTransaction(orphanedTransaction *OrphanedTransaction) error
+	DeleteOrphanedTransactionsForBlockHash(orphanedBlockHash string) error
+	GetOrphanedTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedTransaction, error)
+	GetOrphanedTransactionsByHash(orphanedTransactionHash string) ([]OrphanedTransaction, error)
+	GetOrphanedTransactionByHashAndBlockHash(orphanedTransactionHash, orphanedBlockHash string) (*OrphanedTransaction, error)
+
+	CreateWithdrawal(withdrawal *Withdrawal) error
+	DeleteWithdrawalsForBlockHash(blockHash string) error
+	GetWithdrawalsForBlockHash(blockHash string) ([]Withdrawal, error)
+	GetWithdrawalsByAddress(filter WithdrawalFilter) ([]Withdrawal, error)
+
+	CreateOrphanedWithdrawal(orphanedWithdrawal *OrphanedWithdrawal) error
+	DeleteOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) error
+	GetOrphanedWithdrawalsForBlockHash(orphanedBlockHash string) ([]OrphanedWithdrawal, error)
+
+	CreateUncle(uncle *Uncle) error
+	DeleteUnclesForBlockHash(blockHash string) error
+	GetUnclesForBlockHash(blockHash string) ([]Uncle, error)
+	GetUncleByHash(uncleHash string) (*Uncle, error)
+
+	CreateOrphanedUncle(orphanedUncle *OrphanedUncle) error
+	DeleteOrphanedUnclesForBlockHash(orphanedBlockHash string) error
+	GetOrphanedUnclesForBlockHash(orphanedBlockHash string) ([]OrphanedUncle, error)
+
+	CreateInternalTransaction(internalTransaction *InternalTransaction) error
+	DeleteInternalTransactionsForBlockHash(blockHash string) error
+	GetInternalTransactionsForBlockHash(blockHash string) ([]InternalTransaction, error)
+	GetInternalTransactionsByTransactionHash(transactionHash string) ([]InternalTransaction, error)
+	GetInternalTransactionsByAddress(filter TransactionFilter) ([]InternalTransaction, error)
+
+	CreateOrphanedInternalTransaction(orphanedInternalTransaction *OrphanedInternalTransaction) error
+	DeleteOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) error
+	GetOrphanedInternalTransactionsForBlockHash(orphanedBlockHash string) ([]OrphanedInternalTransaction, error)
+
+	CreateBalance(balance *Balance) error
+	DeleteBalancesForBlockHash(blockHash string) error
+	GetAddressBalanceByBlockHash(address, blockHash string) (*Balance, error)
+	GetLatestAddressBalance(address string, blockNumber pgtype.Numeric) (*Balance, error)
+	GetAddressBalances(filter BalanceFilter) ([]Balance, error)
+
+	CreateBalanceDelta(balanceDelta *BalanceDelta) error
+	SaveBalanceDelta(balanceDelta *BalanceDelta) error
+	DeleteBalanceDeltasForBlockHash(blockHash string) error
+	GetBalanceDeltasForBlockHash(blockHash string) ([]BalanceDelta, error)
+	GetLatestAddressBalanceDelta(address string, blockNumber pgtype.Numeric) (*BalanceDelta, error)
+	GetLaterAddressBalanceDeltas(address string, blockNumber pgtype.Numeric) ([]BalanceDelta, error)
+
+	CreateOrphanedBalanceDelta(orphanedBalanceDelta *OrphanedBalanceDelta) error
+	DeleteOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) error
+	GetOrphanedBalanceDeltasForBlockHash(orphanedBlockHash string) ([]OrphanedBalanceDelta, error)
+
+	CreateReceipt(receipt *Receipt) error
+	DeleteReceiptsForBlockHash(blockHash string) error
+	GetReceiptsForBlockHash(blockHash string) ([]Receipt, error)
+	GetReceiptByTransactionHash(transactionHash string) (*Receipt, error)
+
+	CreateOrphanedReceipt(orphanedReceipt *OrphanedReceipt) error
+	DeleteOrphanedReceiptsForBlockHash(orphanedBlockHash string) error
+	GetOrphanedReceiptsForBlockHash(orphanedBlockHash string) ([]OrphanedReceipt, error)
+
+	CreateLog(log *Log) error
+	DeleteLogsForBlockHash(blockHash string) error
+	GetLogsForBlockHash(blockHash string, addresses []string) ([]Log, error)
+	GetLogs(filter LogFilter) ([]Log, error)
+
+	CreateOrphanedLog(orphanedLog *OrphanedLog) error
+	DeleteOrphanedLogsForBlockHash(orphanedBlockHash string) error
+	GetOrphanedLogsForBlockHash(orphanedBlockHash string) ([]OrphanedLog, error)
+
+	CreateTokenTransfer(tokenTransfer *TokenTransfer) error
+	DeleteTokenTransfersForBlockHash(blockHash string) error
+	GetTokenTransfersForBlockHash(blockHash string) ([]TokenTransfer, error)
+	GetTokenTransfers(filter TokenTransferFilter) ([]TokenTransfer, error)
+
+	CreateTokenBalanceDelta(tokenBalanceDelta *TokenBalanceDelta) error
+	DeleteTokenBalanceDeltasForBlockHash(blockHash string) error
+	GetTokenBalance(token, holder string, blockNumber pgtype.Numeric) (*big.Int, error)
+
+	CreateNFTTransfer(nftTransfer *NFTTransfer) error
+	DeleteNFTTransfersForBlockHash(blockHash string) error
+	GetNFTTransfersForBlockHash(blockHash string) ([]NFTTransfer, error)
+
+	CreateNFTBalanceDelta(nftBalanceDelta *NFTBalanceDelta) error
+	DeleteNFTBalanceDeltasForBlockHash(blockHash string) error
+	GetNFTHoldingsForToken(token string, tokenID pgtype.Numeric, blockNumber pgtype.Numeric) ([]NFTHolding, error)
+	GetNFTHoldingsForHolder(holder string, blockNumber pgtype.Numeric) ([]NFTHolding, error)
+
+	GetBackfillProgress(fromBlock, toBlock uint64) (*BackfillProgress, error)
+	SaveBackfillProgress(backfillProgress *BackfillProgress) error
+
+	CreateTrackedAddress(trackedAddress *TrackedAddress) error
+	GetTrackedAddress(address string) (*TrackedAddress, error)
+	GetTrackedAddresses() ([]TrackedAddress, error)
+	SaveTrackedAddress(trackedAddress *TrackedAddress) error
+	SaveTrackedAddressBackfill(trackedAddress *TrackedAddress) error
+}
+
+// Opens the store for the given connection string, which is either a
+// PostgreSQL connection string, or starts with SQLitePrefix (e.g.
+// "sqlite://getherscan.db") or MemoryStorePrefix
+func OpenStore(connectionString string) (Store, error) {
+	if strings.HasPrefix(connectionString, MemoryStorePrefix) {
+		return OpenMemoryStore(strings.TrimPrefix(connectionString, MemoryStorePrefix)), nil
+	}
+
+	db := new(DB)
+	err := db.Initialize(connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"math/big"
+
+	"github.com/jackc/pgtype"
+)
+
+// The net change in a holder's balance of a token over the transfers
+// in a canonical block. A holder's balance at a block is the sum of
+// its deltas up to that block, so deleting a block's deltas when it is
+// orphaned reverts its transfers
+type TokenBalanceDelta struct {
+	Token       string         `json:"token" gorm:"primaryKey"`
+	Holder      string         `json:"holder" gorm:"primaryKey"`
+	BlockHash   string         `json:"block_hash" gorm:"primaryKey;index"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index;type:numeric"`
+	Delta       pgtype.Numeric `json:"delta" gorm:"type:numeric"`
+}
+
+// Sums the holder's deltas for the token over the canonical blocks up
+// to (and including) blockNumber. Only reflects the transfers that
+// have been indexed, so it matches the token's balanceOf only if every
+// block since the token's deployment has been indexed
+func (db *DB) GetTokenBalance(token, holder string, blockNumber pgtype.Numeric) (*big.Int, error) {
+	var tokenBalanceDeltas []TokenBalanceDelta
+	err := db.Where(
+		"token = ? AND holder = ? AND block_number <= ?",
+		token,
+		holder,
+		blockNumber,
+	).Find(&tokenBalanceDeltas).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balance := new(big.Int)
+	for _, tokenBalanceDelta := range tokenBalanceDeltas {
+		balance.Add(balance, NumericToBigInt(tokenBalanceDelta.Delta))
+	}
+
+	return balance, nil
+}
+
+func (db *DB) CreateTokenBalanceDelta(tokenBalanceDelta *TokenBalanceDelta) error {
+	return db.Create(tokenBalanceDelta).Error
+}
+
+func (db *DB) DeleteTokenBalanceDeltasForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&TokenBalanceDelta{}).Error
+}
@@ -0,0 +1,63 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+// An ERC-20 Transfer event emitted in a canonical block
+type TokenTransfer struct {
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	// Index of the transfer's log in the block
+	LogIndex        uint           `json:"log_index" gorm:"primaryKey;autoIncrement:false"`
+	Token           string         `json:"token" gorm:"index"`
+	From            string         `json:"from" gorm:"index:idx_token_transfers_from,priority:1"`
+	To              string         `json:"to" gorm:"index:idx_token_transfers_to,priority:1"`
+	Value           pgtype.Numeric `json:"value" gorm:"type:numeric"`
+	BlockNumber     pgtype.Numeric `json:"block_number" gorm:"index:idx_token_transfers_from,priority:2;index:idx_token_transfers_to,priority:2;type:numeric"`
+	TransactionHash string         `json:"transaction_hash" gorm:"index"`
+}
+
+type TokenTransferFilter struct {
+	// Matches transfers from or to Address
+	Address string
+	// Only matches transfers of Token, if set
+	Token string
+	// If set, only matches transfers after the given position in
+	// the chain, used to paginate through results
+	After *Position
+	Limit int
+}
+
+func (db *DB) GetTokenTransfersForBlockHash(blockHash string) ([]TokenTransfer, error) {
+	var tokenTransfers []TokenTransfer
+	return tokenTransfers, db.Where("block_hash = ?", blockHash).Order("log_index").Find(&tokenTransfers).Error
+}
+
+// Fetches the transfers matching the filter, ordered by their position
+// in the chain
+func (db *DB) GetTokenTransfers(filter TokenTransferFilter) ([]TokenTransfer, error) {
+	var tokenTransfers []TokenTransfer
+
+	query := db.Where(`("from" = ? OR "to" = ?)`, filter.Address, filter.Address)
+
+	if filter.Token != "" {
+		query = query.Where("token = ?", filter.Token)
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			"(block_number > ? OR (block_number = ? AND log_index > ?))",
+			filter.After.BlockNumber,
+			filter.After.BlockNumber,
+			filter.After.Index,
+		)
+	}
+
+	return tokenTransfers, query.Order("block_number, log_index").Limit(filter.Limit).Find(&tokenTransfers).Error
+}
+
+func (db *DB) CreateTokenTransfer(tokenTransfer *TokenTransfer) error {
+	return db.Create(tokenTransfer).Error
+}
+
+func (db *DB) DeleteTokenTransfersForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&TokenTransfer{}).Error
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Address whose balance the poller fetches at every block it indexes
+// as canonical
+type TrackedAddress struct {
+	Address string `json:"address" gorm:"primaryKey"`
+	// Whether the address' balances at the canonical blocks indexed
+	// before it was tracked still have to be fetched
+	BackfillPending bool `json:"backfill_pending"`
+	// Lowest block number the backfill goes down to
+	BackfillFromBlock uint64 `json:"backfill_from_block"`
+	// Number of the next block to backfill, walking down from the
+	// head at the time the backfill started. Nil until it starts
+	BackfillNextBlock *uint64 `json:"backfill_next_block"`
+	// When the address was removed, nil while it's tracked. Removed
+	// addresses are kept so that the tracked addresses file doesn't
+	// track them again
+	RemovedAt *time.Time `json:"removed_at"`
+}
+
+// Fetches the address whether it's tracked or was removed
+func (db *DB) GetTrackedAddress(address string) (*TrackedAddress, error) {
+	var trackedAddress TrackedAddress
+	return &trackedAddress, db.Where("address = ?", address).First(&trackedAddress).Error
+}
+
+// Fetches the addresses that are tracked, leaving out removed ones
+func (db *DB) GetTrackedAddresses() ([]TrackedAddress, error) {
+	var trackedAddresses []TrackedAddress
+	return trackedAddresses, db.Where("removed_at IS NULL").Order("address").Find(&trackedAddresses).Error
+}
+
+func (db *DB) CreateTrackedAddress(trackedAddress *TrackedAddress) error {
+	return db.Create(trackedAddress).Error
+}
+
+func (db *DB) SaveTrackedAddress(trackedAddress *TrackedAddress) error {
+	return db.Save(trackedAddress).Error
+}
+
+// Saves the progress of the address's backfill, leaving whether it
+// was removed as is
+func (db *DB) SaveTrackedAddressBackfill(trackedAddress *TrackedAddress) error {
+	return db.Model(trackedAddress).Select(
+		"BackfillPending",
+		"BackfillFromBlock",
+		"BackfillNextBlock",
+	).Updates(trackedAddress).Error
+}
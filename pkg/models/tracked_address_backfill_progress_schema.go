@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot of the columns added by the
+// add_tracked_address_backfill_progress migration
+
+type trackedAddressV13 struct {
+	Address           string `gorm:"primaryKey"`
+	BackfillPending   bool
+	RemovedAt         *time.Time
+	BackfillFromBlock uint64 `gorm:"not null;default:0"`
+	BackfillNextBlock *uint64
+}
+
+func (trackedAddressV13) TableName() string {
+	return "tracked_addresses"
+}
+
+// Pending backfills start over from the head, as nothing was saved
+// of their progress
+func addTrackedAddressBackfillProgress(tx *gorm.DB) error {
+	err := tx.Migrator().AddColumn(&trackedAddressV13{}, "BackfillFromBlock")
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().AddColumn(&trackedAddressV13{}, "BackfillNextBlock")
+}
+
+func dropTrackedAddressBackfillProgress(tx *gorm.DB) error {
+	err := tx.Migrator().DropColumn(&trackedAddressV13{}, "BackfillNextBlock")
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().DropColumn(&trackedAddressV13{}, "BackfillFromBlock")
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Snapshot of the column added by the add_tracked_address_removals
+// migration
+
+type trackedAddressV12 struct {
+	Address         string `gorm:"primaryKey"`
+	BackfillPending bool
+	RemovedAt       *time.Time
+}
+
+func (trackedAddressV12) TableName() string {
+	return "tracked_addresses"
+}
+
+// Addresses removed before the column was added were deleted, so
+// there are no removals to backfill
+func addTrackedAddressRemovals(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&trackedAddressV12{}, "RemovedAt")
+}
+
+// Removed addresses are deleted, as they were before the column
+// was added
+func dropTrackedAddressRemovals(tx *gorm.DB) error {
+	err := tx.Where("removed_at IS NOT NULL").Delete(&trackedAddressV12{}).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Migrator().DropColumn(&trackedAddressV12{}, "RemovedAt")
+}
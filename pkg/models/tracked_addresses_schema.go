@@ -0,0 +1,22 @@
+package models
+
+import "gorm.io/gorm"
+
+// Snapshot of the table added by the add_tracked_addresses migration
+
+type trackedAddressV8 struct {
+	Address         string `gorm:"primaryKey"`
+	BackfillPending bool
+}
+
+func (trackedAddressV8) TableName() string {
+	return "tracked_addresses"
+}
+
+func addTrackedAddresses(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&trackedAddressV8{})
+}
+
+func dropTrackedAddresses(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&trackedAddressV8{})
+}
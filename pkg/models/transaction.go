@@ -1,6 +1,8 @@
 package models
 
 import (
+	"math/big"
+
 	"github.com/jackc/pgtype"
 	"gorm.io/gorm"
 )
@@ -8,41 +10,91 @@ import (
 type Transaction struct {
 	Hash    string         `json:"hash" gorm:"primaryKey"`
 	Size    uint64         `json:"size"`
-	From    string         `json:"from"`
+	From    string         `json:"from" gorm:"index:idx_transactions_from,priority:1"`
 	Type    byte           `json:"type"`
 	ChainID pgtype.Numeric `json:"chain_id" gorm:"type:numeric"`
-	// TODO: Model for access list tuples?
-	Data      []byte         `json:"data"`
-	Gas       uint64         `json:"gas"`
-	GasPrice  pgtype.Numeric `json:"gas_price" gorm:"type:numeric"`
-	GasTipCap pgtype.Numeric `json:"gas_tip_cap" gorm:"type:numeric"`
-	GasFeeCap pgtype.Numeric `json:"gas_fee_cap" gorm:"type:numeric"`
-	Value     pgtype.Numeric `json:"value" gorm:"type:numeric"`
-	Nonce     pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
-	To        string         `json:"to"`
-	// TODO: Figure out how to handle signatures
-	BlockHash string `json:"block_hash"`
-	Block     Block  `json:"block" gorm:"foreignKey:BlockHash"`
+	// Empty for legacy transactions
+	AccessList []AccessTuple  `json:"access_list" gorm:"foreignKey:TransactionHash;references:Hash"`
+	Data       []byte         `json:"data"`
+	Gas        uint64         `json:"gas"`
+	GasPrice   pgtype.Numeric `json:"gas_price" gorm:"type:numeric"`
+	GasTipCap  pgtype.Numeric `json:"gas_tip_cap" gorm:"type:numeric"`
+	GasFeeCap  pgtype.Numeric `json:"gas_fee_cap" gorm:"type:numeric"`
+	// Only set for blob transactions
+	BlobGasFeeCap *pgtype.Numeric `json:"blob_gas_fee_cap" gorm:"type:numeric"`
+	BlobHashes    Hashes          `json:"blob_hashes" gorm:"type:text"`
+	Value         pgtype.Numeric  `json:"value" gorm:"type:numeric"`
+	Nonce         pgtype.Numeric  `json:"nonce" gorm:"type:numeric"`
+	To            string          `json:"to" gorm:"index:idx_transactions_to,priority:1"`
+	// Signature values
+	V pgtype.Numeric `json:"v" gorm:"type:numeric"`
+	R pgtype.Numeric `json:"r" gorm:"type:numeric"`
+	S pgtype.Numeric `json:"s" gorm:"type:numeric"`
+	// Binary encoding of the transaction, as sent to the network.
+	// Empty unless the poller was told to store it
+	Raw              []byte         `json:"raw"`
+	BlockHash        string         `json:"block_hash"`
+	Block            Block          `json:"block" gorm:"foreignKey:BlockHash"`
+	BlockNumber      pgtype.Numeric `json:"block_number" gorm:"index:idx_transactions_from,priority:2;index:idx_transactions_to,priority:2;type:numeric"`
+	TransactionIndex uint           `json:"transaction_index" gorm:"index:idx_transactions_from,priority:3;index:idx_transactions_to,priority:3"`
+}
+
+const (
+	TransactionDirectionIn   = "in"
+	TransactionDirectionOut  = "out"
+	TransactionDirectionBoth = "both"
+)
+
+type TransactionFilter struct {
+	Address string
+	// Whether to match transactions to Address (in), from Address
+	// (out) or both
+	Direction string
+	// Optional block range bounds (inclusive)
+	FromBlock *pgtype.Numeric
+	ToBlock   *pgtype.Numeric
+	// If set, only matches transactions after the given position
+	// in the chain, used to paginate through results
+	After *Position
+	Limit int
+}
+
+// Loads transactions along with their access lists, in order
+func (db *DB) withAccessLists() *gorm.DB {
+	return db.Preload("AccessList", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("tuple_index")
+	})
 }
 
 func (db *DB) GetTransactionsForBlockHash(blockHash string) ([]Transaction, error) {
 	var transactions []Transaction
-	return transactions, db.Where("block_hash = ?", blockHash).Find(&transactions).Error
+	return transactions, db.withAccessLists().Where("block_hash = ?", blockHash).Find(&transactions).Error
 }
 
 func (db *DB) GetTransactionByHash(transactionHash string, includeBlock bool) (*Transaction, error) {
 	var transaction Transaction
 
 	if includeBlock {
-		return &transaction, db.Preload("Block").Where("hash = ?", transactionHash).First(&transaction).Error
+		return &transaction, db.withAccessLists().Preload("Block").Where("hash = ?", transactionHash).First(&transaction).Error
 	}
 
-	return &transaction, db.Where("hash = ?", transactionHash).First(&transaction).Error
+	return &transaction, db.withAccessLists().Where("hash = ?", transactionHash).First(&transaction).Error
 }
 
 func (db *DB) GetMostExpensiveTransactionForBlockHash(blockHash string) (*Transaction, error) {
+	// SQLite would multiply gas prices as floats, so we compare
+	// costs here instead
+	if db.isSQLite() {
+		transactions, err := db.GetTransactionsForBlockHash(blockHash)
+		if err != nil {
+			return nil, err
+		}
+
+		return mostExpensiveTransaction(transactions)
+	}
+
 	var transaction Transaction
-	result := db.Where("block_hash = ?", blockHash).Order("gas*gas_price desc").Limit(1).Find(&transaction)
+	result := db.withAccessLists().Where("block_hash = ?", blockHash).Order("gas*gas_price desc").Limit(1).Find(&transaction)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -53,3 +105,73 @@ func (db *DB) GetMostExpensiveTransactionForBlockHash(blockHash string) (*Transa
 
 	return &transaction, nil
 }
+
+// Picks the transaction with the highest gas * gas price, the first
+// one on ties
+func mostExpensiveTransaction(transactions []Transaction) (*Transaction, error) {
+	var mostExpensiveTransaction *Transaction
+	var highestCost *big.Int
+	for i, transaction := range transactions {
+		cost := new(big.Int).Mul(new(big.Int).SetUint64(transaction.Gas), NumericToBigInt(transaction.GasPrice))
+		if highestCost == nil || cost.Cmp(highestCost) > 0 {
+			mostExpensiveTransaction = &transactions[i]
+			highestCost = cost
+		}
+	}
+
+	if mostExpensiveTransaction == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return mostExpensiveTransaction, nil
+}
+
+// Fetches the canonical transactions matching the filter, ordered by
+// their position in the chain
+func (db *DB) GetTransactionsByAddress(filter TransactionFilter) ([]Transaction, error) {
+	var transactions []Transaction
+
+	var query *gorm.DB
+	switch filter.Direction {
+	case TransactionDirectionIn:
+		query = db.withAccessLists().Where(`"to" = ?`, filter.Address)
+	case TransactionDirectionOut:
+		query = db.withAccessLists().Where(`"from" = ?`, filter.Address)
+	default:
+		query = db.withAccessLists().Where(`("from" = ? OR "to" = ?)`, filter.Address, filter.Address)
+	}
+
+	if filter.FromBlock != nil {
+		query = query.Where("block_number >= ?", *filter.FromBlock)
+	}
+
+	if filter.ToBlock != nil {
+		query = query.Where("block_number <= ?", *filter.ToBlock)
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			"(block_number > ? OR (block_number = ? AND transaction_index > ?))",
+			filter.After.BlockNumber,
+			filter.After.BlockNumber,
+			filter.After.Index,
+		)
+	}
+
+	return transactions, query.Order("block_number, transaction_index").Limit(filter.Limit).Find(&transactions).Error
+}
+
+// Creates the transaction along with its access list
+func (db *DB) CreateTransaction(transaction *Transaction) error {
+	return db.Create(transaction).Error
+}
+
+// Deletes the transactions along with their access lists
+func (db *DB) DeleteTransactionsForBlockHash(blockHash string) error {
+	err := db.Where("block_hash = ?", blockHash).Delete(&AccessTuple{}).Error
+	if err != nil {
+		return err
+	}
+
+	return db.Where("block_hash = ?", blockHash).Delete(&Transaction{}).Error
+}
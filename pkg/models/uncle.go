@@ -0,0 +1,51 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+// Header of an uncle (ommer) block, as included in a canonical block
+type Uncle struct {
+	// Hash of the including block
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	Block     Block  `json:"block" gorm:"foreignKey:BlockHash"`
+	// Index of the uncle in the including block
+	UncleIndex uint   `json:"uncle_index" gorm:"primaryKey;autoIncrement:false"`
+	Hash       string `json:"hash" gorm:"index"`
+	// Header fields
+	ParentHash  string         `json:"parent_hash"`
+	UncleHash   string         `json:"uncle_hash"`
+	Coinbase    string         `json:"coinbase"`
+	Root        string         `json:"root"`
+	TxHash      string         `json:"tx_hash"`
+	ReceiptHash string         `json:"receipt_hash"`
+	Bloom       []byte         `json:"bloom"`
+	Difficulty  pgtype.Numeric `json:"difficulty" gorm:"type:numeric"`
+	Number      pgtype.Numeric `json:"number" gorm:"type:numeric"`
+	GasLimit    uint64         `json:"gas_limit"`
+	GasUsed     uint64         `json:"gas_used"`
+	Time        uint64         `json:"time"`
+	Extra       []byte         `json:"extra"`
+	MixDigest   string         `json:"mix_digest"`
+	Nonce       pgtype.Numeric `json:"nonce" gorm:"type:numeric"`
+	// Only set since EIP-1559 (London)
+	BaseFee *pgtype.Numeric `json:"base_fee" gorm:"type:numeric"`
+}
+
+func (db *DB) GetUnclesForBlockHash(blockHash string) ([]Uncle, error) {
+	var uncles []Uncle
+	return uncles, db.Where("block_hash = ?", blockHash).Order("uncle_index").Find(&uncles).Error
+}
+
+// Fetches the uncle with the given hash, as included in a canonical
+// block
+func (db *DB) GetUncleByHash(uncleHash string) (*Uncle, error) {
+	var uncle Uncle
+	return &uncle, db.Where("hash = ?", uncleHash).First(&uncle).Error
+}
+
+func (db *DB) CreateUncle(uncle *Uncle) error {
+	return db.Create(uncle).Error
+}
+
+func (db *DB) DeleteUnclesForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&Uncle{}).Error
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the tables added by the add_uncles migration
+
+type uncleV5 struct {
+	BlockHash   string  `gorm:"primaryKey"`
+	Block       blockV1 `gorm:"foreignKey:BlockHash"`
+	UncleIndex  uint    `gorm:"primaryKey;autoIncrement:false"`
+	Hash        string  `gorm:"index"`
+	ParentHash  string
+	UncleHash   string
+	Coinbase    string
+	Root        string
+	TxHash      string
+	ReceiptHash string
+	Bloom       []byte
+	Difficulty  pgtype.Numeric `gorm:"type:numeric"`
+	Number      pgtype.Numeric `gorm:"type:numeric"`
+	GasLimit    uint64
+	GasUsed     uint64
+	Time        uint64
+	Extra       []byte
+	MixDigest   string
+	Nonce       pgtype.Numeric  `gorm:"type:numeric"`
+	BaseFee     *pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (uncleV5) TableName() string {
+	return "uncles"
+}
+
+type orphanedUncleV5 struct {
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+	UncleIndex        uint            `gorm:"primaryKey;autoIncrement:false"`
+	Hash              string
+	ParentHash        string
+	UncleHash         string
+	Coinbase          string
+	Root              string
+	TxHash            string
+	ReceiptHash       string
+	Bloom             []byte
+	Difficulty        pgtype.Numeric `gorm:"type:numeric"`
+	Number            pgtype.Numeric `gorm:"type:numeric"`
+	GasLimit          uint64
+	GasUsed           uint64
+	Time              uint64
+	Extra             []byte
+	MixDigest         string
+	Nonce             pgtype.Numeric  `gorm:"type:numeric"`
+	BaseFee           *pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (orphanedUncleV5) TableName() string {
+	return "orphaned_uncles"
+}
+
+func addUncles(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&uncleV5{}, &orphanedUncleV5{})
+}
+
+func dropUncles(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&orphanedUncleV5{}, &uncleV5{})
+}
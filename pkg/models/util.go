@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// Converts a numeric read back from the DB, which may be stored with
+// an exponent (e.g. 12345e3, or 123450e-1), to a big.Int. Any
+// fractional part is truncated, and null numerics are 0
+func NumericToBigInt(numeric pgtype.Numeric) *big.Int {
+	if numeric.Int == nil {
+		return new(big.Int)
+	}
+
+	if numeric.Exp < 0 {
+		// big.Int.Exp returns 1 for negative exponents, so
+		// divide by the positive power instead
+		return new(big.Int).Quo(
+			numeric.Int,
+			new(big.Int).Exp(big.NewInt(10), big.NewInt(-int64(numeric.Exp)), nil),
+		)
+	}
+
+	return new(big.Int).Mul(
+		numeric.Int,
+		new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(numeric.Exp)), nil),
+	)
+}
+
+// Position of an item (e.g. a log or transaction) in the chain, used
+// to paginate through results ordered by it
+type Position struct {
+	BlockNumber pgtype.Numeric
+	// Index of the item in its block
+	Index uint
+}
+
+// Hex hashes (e.g. blob versioned hashes), stored as a single
+// comma-separated column since they are only ever read along with the
+// record holding them
+type Hashes []string
+
+func (hashes Hashes) Value() (driver.Value, error) {
+	return strings.Join(hashes, ","), nil
+}
+
+func (hashes *Hashes) Scan(value interface{}) error {
+	var joinedHashes string
+	switch value := value.(type) {
+	case string:
+		joinedHashes = value
+	case []byte:
+		joinedHashes = string(value)
+	case nil:
+	default:
+		return fmt.Errorf("Can't scan %T into hashes", value)
+	}
+
+	*hashes = Hashes{}
+	if joinedHashes != "" {
+		*hashes = strings.Split(joinedHashes, ",")
+	}
+
+	return nil
+}
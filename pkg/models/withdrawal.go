@@ -0,0 +1,71 @@
+package models
+
+import "github.com/jackc/pgtype"
+
+// A validator withdrawal from the beacon chain, processed in a
+// canonical block (since Shanghai). Withdrawals credit their address
+// without any transaction
+type Withdrawal struct {
+	BlockHash string `json:"block_hash" gorm:"primaryKey"`
+	Block     Block  `json:"block" gorm:"foreignKey:BlockHash"`
+	// Index of the withdrawal among all withdrawals, as assigned by
+	// the consensus layer
+	WithdrawalIndex uint64 `json:"withdrawal_index" gorm:"primaryKey;autoIncrement:false"`
+	ValidatorIndex  uint64 `json:"validator_index"`
+	Address         string `json:"address" gorm:"index:idx_withdrawals_address,priority:1"`
+	// In Gwei
+	Amount      uint64         `json:"amount"`
+	BlockNumber pgtype.Numeric `json:"block_number" gorm:"index:idx_withdrawals_address,priority:2;type:numeric"`
+}
+
+type WithdrawalFilter struct {
+	Address string
+	// Optional block range bounds (inclusive)
+	FromBlock *pgtype.Numeric
+	ToBlock   *pgtype.Numeric
+	// If set, only matches withdrawals after the given position in
+	// the chain (the index being the withdrawal index), used to
+	// paginate through results
+	After *Position
+	Limit int
+}
+
+func (db *DB) GetWithdrawalsForBlockHash(blockHash string) ([]Withdrawal, error) {
+	var withdrawals []Withdrawal
+	return withdrawals, db.Where("block_hash = ?", blockHash).Order("withdrawal_index").Find(&withdrawals).Error
+}
+
+// Fetches the withdrawals matching the filter, ordered by their
+// position in the chain
+func (db *DB) GetWithdrawalsByAddress(filter WithdrawalFilter) ([]Withdrawal, error) {
+	var withdrawals []Withdrawal
+
+	query := db.Where("address = ?", filter.Address)
+
+	if filter.FromBlock != nil {
+		query = query.Where("block_number >= ?", *filter.FromBlock)
+	}
+
+	if filter.ToBlock != nil {
+		query = query.Where("block_number <= ?", *filter.ToBlock)
+	}
+
+	if filter.After != nil {
+		query = query.Where(
+			"(block_number > ? OR (block_number = ? AND withdrawal_index > ?))",
+			filter.After.BlockNumber,
+			filter.After.BlockNumber,
+			filter.After.Index,
+		)
+	}
+
+	return withdrawals, query.Order("block_number, withdrawal_index").Limit(filter.Limit).Find(&withdrawals).Error
+}
+
+func (db *DB) CreateWithdrawal(withdrawal *Withdrawal) error {
+	return db.Create(withdrawal).Error
+}
+
+func (db *DB) DeleteWithdrawalsForBlockHash(blockHash string) error {
+	return db.Where("block_hash = ?", blockHash).Delete(&Withdrawal{}).Error
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"github.com/jackc/pgtype"
+	"gorm.io/gorm"
+)
+
+// Snapshots of the columns and tables added by the add_withdrawals
+// migration
+
+type blockV4 struct {
+	WithdrawalsRoot string
+}
+
+func (blockV4) TableName() string {
+	return "blocks"
+}
+
+type orphanedBlockV4 struct {
+	WithdrawalsRoot string
+}
+
+func (orphanedBlockV4) TableName() string {
+	return "orphaned_blocks"
+}
+
+type withdrawalV4 struct {
+	BlockHash       string  `gorm:"primaryKey"`
+	Block           blockV1 `gorm:"foreignKey:BlockHash"`
+	WithdrawalIndex uint64  `gorm:"primaryKey;autoIncrement:false"`
+	ValidatorIndex  uint64
+	Address         string `gorm:"index:idx_withdrawals_address,priority:1"`
+	Amount          uint64
+	BlockNumber     pgtype.Numeric `gorm:"index:idx_withdrawals_address,priority:2;type:numeric"`
+}
+
+func (withdrawalV4) TableName() string {
+	return "withdrawals"
+}
+
+type orphanedWithdrawalV4 struct {
+	OrphanedBlockHash string          `gorm:"primaryKey"`
+	OrphanedBlock     orphanedBlockV1 `gorm:"foreignKey:OrphanedBlockHash"`
+	WithdrawalIndex   uint64          `gorm:"primaryKey;autoIncrement:false"`
+	ValidatorIndex    uint64
+	Address           string
+	Amount            uint64
+	BlockNumber       pgtype.Numeric `gorm:"type:numeric"`
+}
+
+func (orphanedWithdrawalV4) TableName() string {
+	return "orphaned_withdrawals"
+}
+
+func addWithdrawals(tx *gorm.DB) error {
+	for _, table := range []interface{}{&blockV4{}, &orphanedBlockV4{}} {
+		err := tx.Migrator().AddColumn(table, "WithdrawalsRoot")
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Migrator().CreateTable(&withdrawalV4{}, &orphanedWithdrawalV4{})
+}
+
+func dropWithdrawals(tx *gorm.DB) error {
+	err := tx.Migrator().DropTable(&orphanedWithdrawalV4{}, &withdrawalV4{})
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []interface{}{&blockV4{}, &orphanedBlockV4{}} {
+		err = tx.Migrator().DropColumn(table, "WithdrawalsRoot")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
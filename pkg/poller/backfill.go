@@ -0,0 +1,85 @@
+package poller
+
+import (
+	"errors"
+	"getherscan/pkg/models"
+	"log"
+
+	"github.com/jackc/pgtype"
+)
+
+// Indexes the node's canonical blocks from fromBlock up to (and
+// including) toBlock, saving progress after each block so that an
+// interrupted backfill over the same range picks up where it left
+// off. Blocks are fetched concurrently, but indexed in order, so
+// progress never skips over a block
+func (poller *Poller) Backfill(fromBlock, toBlock uint64) error {
+	backfillProgress, err := poller.Store.GetBackfillProgress(fromBlock, toBlock)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		backfillProgress = &models.BackfillProgress{
+			FromBlock: fromBlock,
+			ToBlock:   toBlock,
+			NextBlock: fromBlock,
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if backfillProgress.NextBlock > fromBlock {
+		log.Printf("Resuming backfill at block %d\n", backfillProgress.NextBlock)
+	}
+
+	err = poller.FetchBlockRange(
+		backfillProgress.NextBlock,
+		toBlock,
+		func(fetchedBlock *FetchedBlock) error {
+			err := poller.BackfillBlock(fetchedBlock)
+			if err != nil {
+				return err
+			}
+
+			backfillProgress.NextBlock = fetchedBlock.Block.NumberU64() + 1
+			return poller.Store.SaveBackfillProgress(backfillProgress)
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Backfilled blocks %d to %d\n", fromBlock, toBlock)
+
+	return nil
+}
+
+// Indexes a historical block as canonical, unless it (or another
+// canonical block with the same number) has already been indexed, e.g.
+// by the poller or a previous backfill
+func (poller *Poller) BackfillBlock(fetchedBlock *FetchedBlock) error {
+	block := fetchedBlock.Block
+
+	isIndexed, err := poller.CheckIfIndexed(block.Hash().Hex())
+	if err != nil {
+		return err
+	}
+
+	if isIndexed {
+		return nil
+	}
+
+	blockNumber := new(pgtype.Numeric)
+	err = blockNumber.Set(block.Number().String())
+	if err != nil {
+		return err
+	}
+
+	_, err = poller.Store.GetBlockByNumber(*blockNumber)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		return err
+	}
+
+	return poller.IndexNewBlock(fetchedBlock)
+}
@@ -1,9 +1,11 @@
 package poller
 
 import (
+	"errors"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 
 	"github.com/urfave/cli"
@@ -29,6 +31,27 @@ func PollAction(cliCtx *cli.Context) error {
 		return err
 	}
 
+	if cliCtx.String("fork-choice") != "" {
+		poller.ForkChoice, err = GetForkChoice(cliCtx.String("fork-choice"))
+		if err != nil {
+			return err
+		}
+	}
+
+	poller.Concurrency = cliCtx.Int("concurrency")
+	poller.PrefetchLimit = cliCtx.Int("prefetch-limit")
+	poller.IndexReceipts = !cliCtx.Bool("skip-receipts")
+	poller.StoreRawTransactions = cliCtx.Bool("store-raw-transactions")
+	poller.TraceInternalTransactions = cliCtx.Bool("trace-internal-transactions")
+	poller.TraceBalanceDeltas = cliCtx.Bool("trace-balance-deltas")
+
+	if cliCtx.IsSet("finality-poll-interval") {
+		poller.FinalityPollInterval = cliCtx.Duration("finality-poll-interval")
+	}
+
+	poller.TrackedAddressesPollInterval = cliCtx.Duration("tracked-addresses-poll-interval")
+	poller.TrackedAddressesBackfillDepth = cliCtx.Uint64("tracked-addresses-backfill-depth")
+
 	go poller.Poll()
 
 	log.Println("Listening for new blocks...")
@@ -47,4 +70,131 @@ var PollCommand = cli.Command{
 	Usage:     "Listens for new blocks on the provided websocket RPC endpoint and indexes them to the provided PostgreSQL connection. Optionally accepts a JSON array of hex addresses for which to index balances.",
 	ArgsUsage: "Provide a websocket RPC endpoint, a PostgreSQL connection string, and, optionally, a path to a JSON file containing an array of hex addresses to track.",
 	Action:    PollAction,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "fork-choice",
+			Usage: "Fork choice rule used to resolve reorgs, either \"total-difficulty\" (proof-of-work) or \"longest-chain\" (proof-of-stake). Defaults to the rule for the endpoint's chain ID.",
+		},
+		cli.DurationFlag{
+			Name:  "finality-poll-interval",
+			Usage: "How often to fetch the node's safe and finalized heads, 0 disables finality tracking. Defaults to 30s on proof-of-stake chains, and 0 otherwise.",
+		},
+		cli.DurationFlag{
+			Name:  "tracked-addresses-poll-interval",
+			Value: DefaultTrackedAddressesPollInterval,
+			Usage: "How often to pick up tracked addresses added or removed through the API server, and backfill the balances of those added with a backfill. 0 disables it.",
+		},
+		cli.Uint64Flag{
+			Name:  "tracked-addresses-backfill-depth",
+			Value: DefaultTrackedAddressesBackfillDepth,
+			Usage: "How many blocks below the head to backfill the balances of tracked addresses added with a backfill.",
+		},
+		cli.IntFlag{
+			Name:  "concurrency",
+			Value: DefaultConcurrency,
+			Usage: "Number of blocks to fetch concurrently when catching up on missed blocks.",
+		},
+		cli.IntFlag{
+			Name:  "prefetch-limit",
+			Value: DefaultPrefetchLimit,
+			Usage: "Maximum number of fetched blocks waiting to be indexed when catching up on missed blocks.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-receipts",
+			Usage: "Don't index transaction receipts and logs.",
+		},
+		cli.BoolFlag{
+			Name:  "store-raw-transactions",
+			Usage: "Store the binary encoding of every transaction, so that it can be re-broadcast.",
+		},
+		cli.BoolFlag{
+			Name:  "trace-internal-transactions",
+			Usage: "Index the internal transactions of every block by tracing it with debug_traceBlockByHash, which the endpoint must support.",
+		},
+		cli.BoolFlag{
+			Name:  "trace-balance-deltas",
+			Usage: "Index the balance changes of every address touched by every block by tracing it with debug_traceBlockByHash, which the endpoint must support, so that the balance of any address can be queried without tracking it.",
+		},
+	},
+}
+
+func BackfillAction(cliCtx *cli.Context) error {
+	var err error
+
+	wsRPCEndpoint := cliCtx.Args().Get(0)
+	dbConnectionString := cliCtx.Args().Get(1)
+
+	fromBlock, err := strconv.ParseUint(cliCtx.Args().Get(2), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	toBlock, err := strconv.ParseUint(cliCtx.Args().Get(3), 10, 64)
+	if err != nil {
+		return err
+	}
+
+	if fromBlock > toBlock {
+		return errors.New("Block range to backfill is empty")
+	}
+
+	trackedAddresses := []string{}
+	if cliCtx.Args().Get(4) != "" {
+		trackedAddresses, err = GetTrackedAddressesFromFile(cliCtx.Args().Get(4))
+		if err != nil {
+			return err
+		}
+	}
+
+	poller := new(Poller)
+	err = poller.Initialize(wsRPCEndpoint, dbConnectionString, trackedAddresses)
+	if err != nil {
+		return err
+	}
+
+	poller.Concurrency = cliCtx.Int("concurrency")
+	poller.PrefetchLimit = cliCtx.Int("prefetch-limit")
+	poller.IndexReceipts = !cliCtx.Bool("skip-receipts")
+	poller.StoreRawTransactions = cliCtx.Bool("store-raw-transactions")
+	poller.TraceInternalTransactions = cliCtx.Bool("trace-internal-transactions")
+	poller.TraceBalanceDeltas = cliCtx.Bool("trace-balance-deltas")
+
+	log.Printf("Backfilling blocks %d to %d...\n", fromBlock, toBlock)
+
+	return poller.Backfill(fromBlock, toBlock)
+}
+
+var BackfillCommand = cli.Command{
+	Name:      "backfill",
+	Usage:     "Indexes the canonical blocks in the provided range (inclusive) using the provided websocket RPC endpoint and PostgreSQL connection. Progress is saved as blocks are indexed, so rerunning an interrupted backfill with the same range resumes it. Optionally accepts a JSON array of hex addresses for which to index balances.",
+	ArgsUsage: "Provide a websocket RPC endpoint, a PostgreSQL connection string, the first and last block numbers to index, and, optionally, a path to a JSON file containing an array of hex addresses to track.",
+	Action:    BackfillAction,
+	Flags: []cli.Flag{
+		cli.IntFlag{
+			Name:  "concurrency",
+			Value: DefaultConcurrency,
+			Usage: "Number of blocks to fetch concurrently.",
+		},
+		cli.IntFlag{
+			Name:  "prefetch-limit",
+			Value: DefaultPrefetchLimit,
+			Usage: "Maximum number of fetched blocks waiting to be indexed.",
+		},
+		cli.BoolFlag{
+			Name:  "skip-receipts",
+			Usage: "Don't index transaction receipts and logs.",
+		},
+		cli.BoolFlag{
+			Name:  "store-raw-transactions",
+			Usage: "Store the binary encoding of every transaction, so that it can be re-broadcast.",
+		},
+		cli.BoolFlag{
+			Name:  "trace-internal-transactions",
+			Usage: "Index the internal transactions of every block by tracing it with debug_traceBlockByHash, which the endpoint must support.",
+		},
+		cli.BoolFlag{
+			Name:  "trace-balance-deltas",
+			Usage: "Index the balance changes of every address touched by every block by tracing it with debug_traceBlockByHash, which the endpoint must support, so that the balance of any address can be queried without tracking it.",
+		},
+	},
 }
@@ -0,0 +1,138 @@
+package poller
+
+import (
+	"errors"
+	"getherscan/pkg/models"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/jackc/pgtype"
+)
+
+const DefaultFinalityPollInterval = 30 * time.Second
+
+// Fetches the header for the given block tag (e.g. "safe" or
+// "finalized"). These tags aren't supported by our version of
+// ethclient, so we make the call directly
+func (poller *Poller) FetchHeaderByTag(tag string) (*types.Header, error) {
+	var header *types.Header
+	err := poller.RPCClient.CallContext(poller.Context, &header, "eth_getBlockByNumber", tag, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if header == nil {
+		return nil, ethereum.NotFound
+	}
+
+	return header, nil
+}
+
+// Fetches the node's safe and finalized heads, marks the indexed
+// canonical blocks up to them accordingly, and prunes orphaned blocks
+// behind the finalized head
+func (poller *Poller) UpdateFinality() error {
+	finalizedHeader, err := poller.FetchHeaderByTag("finalized")
+	if err != nil {
+		return err
+	}
+
+	safeHeader, err := poller.FetchHeaderByTag("safe")
+	if err != nil {
+		return err
+	}
+
+	err = poller.Atomically(func(txPoller *Poller) error {
+		// Only trust the tags if they point to blocks we
+		// consider canonical, otherwise we're either behind the
+		// node or on another fork, and will catch up on a later
+		// update. The safe head is ahead of the finalized head,
+		// so it can be indexed while the finalized head isn't
+		// (e.g. right after starting). Finality is updated in a
+		// single transaction, so that it's never seen half-updated
+
+		finalizedBlockNumber, err := txPoller.GetIndexedBlockNumber(finalizedHeader)
+		if err != nil {
+			return err
+		}
+
+		if finalizedBlockNumber != nil {
+			err = txPoller.Store.FinalizeBlocksUpTo(*finalizedBlockNumber)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.DeleteOrphanedBlocksUpTo(*finalizedBlockNumber)
+			if err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Finalized block %s not indexed yet\n", finalizedHeader.Hash().Hex())
+		}
+
+		safeBlockNumber, err := txPoller.GetIndexedBlockNumber(safeHeader)
+		if err != nil {
+			return err
+		}
+
+		if safeBlockNumber != nil {
+			err = txPoller.Store.MarkBlocksSafeUpTo(*safeBlockNumber)
+			if err != nil {
+				return err
+			}
+		} else {
+			log.Printf("Safe block %s not indexed yet\n", safeHeader.Hash().Hex())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf(
+		"Updated finality (safe: %s, finalized: %s)\n",
+		safeHeader.Number.String(),
+		finalizedHeader.Number.String(),
+	)
+
+	return nil
+}
+
+// Returns the number of the given header's block if it's indexed as
+// canonical, or nil otherwise
+func (poller *Poller) GetIndexedBlockNumber(header *types.Header) (*pgtype.Numeric, error) {
+	_, err := poller.Store.GetBlockByHash(header.Hash().Hex())
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumber := new(pgtype.Numeric)
+	err = blockNumber.Set(header.Number.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return blockNumber, nil
+}
+
+// Checks if the given block is at or behind the latest finalized
+// block, in which case it can never become canonical
+func (poller *Poller) IsBehindFinalizedHead(block *types.Block) (bool, error) {
+	finalizedHead, err := poller.Store.GetFinalizedHead()
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return block.Number().Cmp(models.NumericToBigInt(finalizedHead.Number)) <= 0, nil
+}
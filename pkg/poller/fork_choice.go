@@ -0,0 +1,91 @@
+package poller
+
+import (
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+)
+
+// Decides whether a new block that does not extend the local head
+// (and whose fork has been indexed back to canonicalAncestorHash)
+// should replace the local head
+type ForkChoice interface {
+	ShouldReorg(poller *Poller, head *models.Block, orphanedBlock *models.OrphanedBlock, canonicalAncestorHash string) (bool, error)
+}
+
+// Fork choice for proof-of-work chains: the fork with the highest
+// total difficulty wins
+type TotalDifficultyForkChoice struct{}
+
+func (forkChoice TotalDifficultyForkChoice) ShouldReorg(poller *Poller, head *models.Block, orphanedBlock *models.OrphanedBlock, canonicalAncestorHash string) (bool, error) {
+	currentTotalDifficulty, err := poller.GetTotalCanonicalDifficultySince(canonicalAncestorHash, head)
+	if err != nil {
+		return false, err
+	}
+
+	newTotalDifficulty, err := poller.GetTotalOrphanedDifficultySince(canonicalAncestorHash, orphanedBlock)
+	if err != nil {
+		return false, err
+	}
+
+	if newTotalDifficulty.Cmp(currentTotalDifficulty) > 0 {
+		// (Effective) total difficulty of new block is higher
+		// than that of local head
+		return true, nil
+	}
+
+	// If (effective) total difficulties of both new block and
+	// local head are equal, but new block has a lower block
+	// number, it will necessarily have a higher total difficulty
+	// once it reaches the same block number
+	return newTotalDifficulty.Cmp(currentTotalDifficulty) == 0 && models.NumericToBigInt(orphanedBlock.Number).Cmp(models.NumericToBigInt(head.Number)) < 0, nil
+}
+
+// Fork choice for proof-of-stake chains, where every block has a
+// difficulty of 0. The node runs the actual (LMD-GHOST) fork choice,
+// so we follow the heads it announces: the longer fork wins, and on a
+// tie the most recently received block wins
+type LongestChainForkChoice struct{}
+
+func (forkChoice LongestChainForkChoice) ShouldReorg(poller *Poller, head *models.Block, orphanedBlock *models.OrphanedBlock, canonicalAncestorHash string) (bool, error) {
+	// Both forks descend from the same canonical ancestor, so
+	// comparing block numbers is equivalent to comparing fork
+	// lengths
+	return models.NumericToBigInt(orphanedBlock.Number).Cmp(models.NumericToBigInt(head.Number)) >= 0, nil
+}
+
+const (
+	TotalDifficultyForkChoiceName = "total-difficulty"
+	LongestChainForkChoiceName    = "longest-chain"
+)
+
+func GetForkChoice(name string) (ForkChoice, error) {
+	switch name {
+	case TotalDifficultyForkChoiceName:
+		return TotalDifficultyForkChoice{}, nil
+	case LongestChainForkChoiceName:
+		return LongestChainForkChoice{}, nil
+	default:
+		return nil, fmt.Errorf("Unknown fork choice %q", name)
+	}
+}
+
+// Chain IDs of networks that have transitioned to proof-of-stake
+var proofOfStakeChainIDs = map[uint64]bool{
+	1:        true, // Mainnet
+	5:        true, // Goerli
+	17000:    true, // Holesky
+	11155111: true, // Sepolia
+}
+
+func IsProofOfStakeChainID(chainID *big.Int) bool {
+	return chainID.IsUint64() && proofOfStakeChainIDs[chainID.Uint64()]
+}
+
+func GetDefaultForkChoiceForChainID(chainID *big.Int) ForkChoice {
+	if IsProofOfStakeChainID(chainID) {
+		return LongestChainForkChoice{}
+	}
+
+	return TotalDifficultyForkChoice{}
+}
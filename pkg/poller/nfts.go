@@ -0,0 +1,207 @@
+package poller
+
+import (
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jackc/pgtype"
+)
+
+var (
+	TransferSingleEventTopic = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)")).Hex()
+	TransferBatchEventTopic  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])")).Hex()
+)
+
+var uint256ArrayType, _ = abi.NewType("uint256[]", "", nil)
+
+// Non-indexed arguments of TransferBatch events
+var transferBatchArguments = abi.Arguments{
+	{Name: "ids", Type: uint256ArrayType},
+	{Name: "values", Type: uint256ArrayType},
+}
+
+// Decodes an ERC-721 Transfer, or ERC-1155 TransferSingle/TransferBatch
+// event into a transfer per token ID, returns nil if the log isn't one
+func MakeNFTTransferModels(logModel *models.Log) ([]*models.NFTTransfer, error) {
+	switch {
+	case logModel.Topic0 == TransferEventTopic && logModel.Topic3 != "" && len(logModel.Data) == 0:
+		nftTransferModel, err := makeNFTTransferModel(
+			logModel,
+			0,
+			models.NFTStandardERC721,
+			"",
+			common.HexToHash(logModel.Topic3).Big(),
+			big.NewInt(1),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*models.NFTTransfer{nftTransferModel}, nil
+	case logModel.Topic0 == TransferSingleEventTopic && logModel.Topic3 != "" && len(logModel.Data) == 64:
+		nftTransferModel, err := makeNFTTransferModel(
+			logModel,
+			0,
+			models.NFTStandardERC1155,
+			common.HexToAddress(logModel.Topic1).Hex(),
+			new(big.Int).SetBytes(logModel.Data[:32]),
+			new(big.Int).SetBytes(logModel.Data[32:]),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*models.NFTTransfer{nftTransferModel}, nil
+	case logModel.Topic0 == TransferBatchEventTopic && logModel.Topic3 != "":
+		unpacked, err := transferBatchArguments.Unpack(logModel.Data)
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode TransferBatch log %d in block %s: %w", logModel.LogIndex, logModel.BlockHash, err)
+		}
+
+		tokenIDs := unpacked[0].([]*big.Int)
+		values := unpacked[1].([]*big.Int)
+		if len(tokenIDs) != len(values) {
+			return nil, fmt.Errorf("TransferBatch log %d in block %s has mismatched ids and values", logModel.LogIndex, logModel.BlockHash)
+		}
+
+		nftTransferModels := make([]*models.NFTTransfer, len(tokenIDs))
+		for i := range tokenIDs {
+			nftTransferModels[i], err = makeNFTTransferModel(
+				logModel,
+				uint(i),
+				models.NFTStandardERC1155,
+				common.HexToAddress(logModel.Topic1).Hex(),
+				tokenIDs[i],
+				values[i],
+			)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return nftTransferModels, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Holds for all three events: ERC-721 Transfer has (from, to, token
+// ID) as topics 1-3, the ERC-1155 events have (operator, from, to)
+func makeNFTTransferModel(logModel *models.Log, batchIndex uint, standard, operator string, tokenID, value *big.Int) (*models.NFTTransfer, error) {
+	from, to := logModel.Topic1, logModel.Topic2
+	if standard == models.NFTStandardERC1155 {
+		from, to = logModel.Topic2, logModel.Topic3
+	}
+
+	nftTransferTokenID := new(pgtype.Numeric)
+	err := nftTransferTokenID.Set(tokenID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	nftTransferValue := new(pgtype.Numeric)
+	err = nftTransferValue.Set(value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.NFTTransfer{
+		BlockHash:       logModel.BlockHash,
+		LogIndex:        logModel.LogIndex,
+		BatchIndex:      batchIndex,
+		Standard:        standard,
+		Token:           logModel.Address,
+		TokenID:         *nftTransferTokenID,
+		Operator:        operator,
+		From:            common.HexToAddress(from).Hex(),
+		To:              common.HexToAddress(to).Hex(),
+		Value:           *nftTransferValue,
+		BlockNumber:     logModel.BlockNumber,
+		TransactionHash: logModel.TransactionHash,
+	}, nil
+}
+
+type nftHolder struct {
+	token   string
+	tokenID string
+	holder  string
+}
+
+// Indexes the NFT transfers among the logs of a canonical block, along
+// with the ownership changes they add up to
+func (poller *Poller) IndexNFTTransfers(logModels []*models.Log) error {
+	if len(logModels) == 0 {
+		return nil
+	}
+
+	deltas := make(map[nftHolder]*big.Int)
+	tokenIDs := make(map[nftHolder]pgtype.Numeric)
+	addDelta := func(nftTransferModel *models.NFTTransfer, holder string, delta *big.Int) {
+		// Mints and burns only change the other party's
+		// balance
+		if holder == zeroAddress {
+			return
+		}
+
+		key := nftHolder{
+			nftTransferModel.Token,
+			models.NumericToBigInt(nftTransferModel.TokenID).String(),
+			holder,
+		}
+		if deltas[key] == nil {
+			deltas[key] = new(big.Int)
+			tokenIDs[key] = nftTransferModel.TokenID
+		}
+
+		deltas[key].Add(deltas[key], delta)
+	}
+
+	for _, logModel := range logModels {
+		nftTransferModels, err := MakeNFTTransferModels(logModel)
+		if err != nil {
+			return err
+		}
+
+		for _, nftTransferModel := range nftTransferModels {
+			err = poller.Store.CreateNFTTransfer(nftTransferModel)
+			if err != nil {
+				return err
+			}
+
+			value := models.NumericToBigInt(nftTransferModel.Value)
+			addDelta(nftTransferModel, nftTransferModel.From, new(big.Int).Neg(value))
+			addDelta(nftTransferModel, nftTransferModel.To, value)
+		}
+	}
+
+	for key, delta := range deltas {
+		// e.g. transfers to self
+		if delta.Sign() == 0 {
+			continue
+		}
+
+		nftBalanceDelta := new(pgtype.Numeric)
+		err := nftBalanceDelta.Set(delta.String())
+		if err != nil {
+			return err
+		}
+
+		err = poller.Store.CreateNFTBalanceDelta(&models.NFTBalanceDelta{
+			Token:       key.token,
+			TokenID:     tokenIDs[key],
+			Holder:      key.holder,
+			BlockHash:   logModels[0].BlockHash,
+			BlockNumber: logModels[0].BlockNumber,
+			Delta:       *nftBalanceDelta,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
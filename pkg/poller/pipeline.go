@@ -0,0 +1,348 @@
+package poller
+
+import (
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	DefaultConcurrency   = 8
+	DefaultPrefetchLimit = 32
+)
+
+// A block along with the data fetched for it from the node, so that
+// indexing it needs as few RPC calls as possible
+type FetchedBlock struct {
+	Block *types.Block
+	// Balances of the tracked addresses at the block, nil if they
+	// haven't been fetched yet
+	Balances map[string]*big.Int
+	// Receipts of the block's transactions, in order, nil if they
+	// haven't been fetched yet
+	Receipts []*types.Receipt
+	// Call traces of the block's transactions, in order, nil if
+	// they haven't been fetched yet
+	Traces []*TransactionTrace
+	// State diffs of the block's transactions, in order, nil if
+	// they haven't been fetched yet
+	StateDiffs []*TransactionStateDiff
+}
+
+// An orphaned block about to be canonicalized, along with the data
+// fetched for it from the node
+type FetchedOrphanedBlock struct {
+	OrphanedBlock *models.OrphanedBlock
+	Balances      map[string]*big.Int
+	// Receipts of the block's transactions, in order. nil if the
+	// block's receipts were indexed before it was orphaned, in
+	// which case they only need to be moved
+	Receipts []*types.Receipt
+	// Call traces of the block's transactions, in order. nil if the
+	// block's internal transactions were indexed before it was
+	// orphaned
+	Traces []*TransactionTrace
+	// State diffs of the block's transactions, in order. nil if the
+	// block's balance deltas were indexed before it was orphaned
+	StateDiffs []*TransactionStateDiff
+}
+
+func (poller *Poller) FetchBlock(blockNumber *big.Int) (*FetchedBlock, error) {
+	block, err := poller.EthClient.BlockByNumber(poller.Context, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedBlock := &FetchedBlock{Block: block}
+	err = poller.CompleteFetchedBlock(fetchedBlock)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchedBlock, nil
+}
+
+// Fetches whatever data needed to index the block as canonical hasn't
+// been fetched yet
+func (poller *Poller) CompleteFetchedBlock(fetchedBlock *FetchedBlock) error {
+	var err error
+
+	if fetchedBlock.Balances == nil {
+		fetchedBlock.Balances, err = poller.FetchAddressBalances(fetchedBlock.Block.Number())
+		if err != nil {
+			return err
+		}
+	}
+
+	transactionHashes := make([]common.Hash, len(fetchedBlock.Block.Transactions()))
+	for i, transaction := range fetchedBlock.Block.Transactions() {
+		transactionHashes[i] = transaction.Hash()
+	}
+
+	if fetchedBlock.Receipts == nil && poller.IndexReceipts {
+		fetchedBlock.Receipts, err = poller.FetchReceipts(fetchedBlock.Block.Hash(), transactionHashes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fetchedBlock.Traces == nil && poller.TraceInternalTransactions {
+		fetchedBlock.Traces, err = poller.FetchTraces(fetchedBlock.Block.Hash(), transactionHashes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fetchedBlock.StateDiffs == nil && poller.TraceBalanceDeltas {
+		fetchedBlock.StateDiffs, err = poller.FetchStateDiffs(fetchedBlock.Block.Hash(), transactionHashes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fetches the data needed to canonicalize the orphaned block
+func (poller *Poller) FetchOrphanedBlock(orphanedBlock *models.OrphanedBlock) (*FetchedOrphanedBlock, error) {
+	balances, err := poller.FetchAddressBalances(models.NumericToBigInt(orphanedBlock.Number))
+	if err != nil {
+		return nil, err
+	}
+
+	fetchedOrphanedBlock := &FetchedOrphanedBlock{
+		OrphanedBlock: orphanedBlock,
+		Balances:      balances,
+	}
+
+	// Blocks that were indexed directly as orphans (as opposed
+	// to having been orphaned) don't have receipts, internal
+	// transactions or balance deltas yet
+
+	if poller.IndexReceipts {
+		orphanedReceipts, err := poller.Store.GetOrphanedReceiptsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(orphanedReceipts) == 0 {
+			transactionHashes, err := poller.getOrphanedTransactionHashes(orphanedBlock.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			fetchedOrphanedBlock.Receipts, err = poller.FetchReceipts(common.HexToHash(orphanedBlock.Hash), transactionHashes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if poller.TraceInternalTransactions {
+		orphanedInternalTransactions, err := poller.Store.GetOrphanedInternalTransactionsForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		// Blocks without any internal transactions are traced
+		// again, which is harmless
+		if len(orphanedInternalTransactions) == 0 {
+			transactionHashes, err := poller.getOrphanedTransactionHashes(orphanedBlock.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			fetchedOrphanedBlock.Traces, err = poller.FetchTraces(common.HexToHash(orphanedBlock.Hash), transactionHashes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if poller.TraceBalanceDeltas {
+		orphanedBalanceDeltas, err := poller.Store.GetOrphanedBalanceDeltasForBlockHash(orphanedBlock.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		// Blocks without any balance changes are traced again,
+		// which is harmless
+		if len(orphanedBalanceDeltas) == 0 {
+			transactionHashes, err := poller.getOrphanedTransactionHashes(orphanedBlock.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			fetchedOrphanedBlock.StateDiffs, err = poller.FetchStateDiffs(common.HexToHash(orphanedBlock.Hash), transactionHashes)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return fetchedOrphanedBlock, nil
+}
+
+// Returns the hashes of the orphaned block's transactions, in order
+func (poller *Poller) getOrphanedTransactionHashes(orphanedBlockHash string) ([]common.Hash, error) {
+	orphanedTransactions, err := poller.Store.GetOrphanedTransactionsForBlockHash(orphanedBlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(orphanedTransactions, func(i, j int) bool {
+		return orphanedTransactions[i].TransactionIndex < orphanedTransactions[j].TransactionIndex
+	})
+
+	transactionHashes := make([]common.Hash, len(orphanedTransactions))
+	for i, orphanedTransaction := range orphanedTransactions {
+		transactionHashes[i] = common.HexToHash(orphanedTransaction.Hash)
+	}
+
+	return transactionHashes, nil
+}
+
+// Fetches the receipts of the given transactions in a single batch
+// call. The node only serves receipts for its canonical chain, so this
+// fails if the block with blockHash isn't canonical
+func (poller *Poller) FetchReceipts(blockHash common.Hash, transactionHashes []common.Hash) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(transactionHashes))
+	if len(transactionHashes) == 0 {
+		return receipts, nil
+	}
+
+	batch := make([]rpc.BatchElem, len(transactionHashes))
+	for i, transactionHash := range transactionHashes {
+		batch[i] = rpc.BatchElem{
+			Method: "eth_getTransactionReceipt",
+			Args:   []interface{}{transactionHash},
+			Result: &receipts[i],
+		}
+	}
+
+	err := poller.RPCClient.BatchCallContext(poller.Context, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, batchElem := range batch {
+		if batchElem.Error != nil {
+			return nil, batchElem.Error
+		}
+
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("Receipt for transaction %s not found", transactionHashes[i].Hex())
+		}
+
+		if receipts[i].BlockHash != blockHash {
+			return nil, fmt.Errorf("Receipt for transaction %s is not from block %s", transactionHashes[i].Hex(), blockHash.Hex())
+		}
+	}
+
+	return receipts, nil
+}
+
+func (poller *Poller) FetchAddressBalances(blockNumber *big.Int) (map[string]*big.Int, error) {
+	balances := make(map[string]*big.Int, len(poller.TrackedAddresses))
+	for _, address := range poller.TrackedAddresses {
+		balance, err := poller.EthClient.BalanceAt(
+			poller.Context,
+			common.HexToAddress(address),
+			blockNumber,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		balances[address] = balance
+	}
+
+	return balances, nil
+}
+
+type fetchJob struct {
+	blockNumber uint64
+	result      chan<- fetchResult
+}
+
+type fetchResult struct {
+	fetchedBlock *FetchedBlock
+	err          error
+}
+
+// Fetches the node's canonical blocks from fromBlock up to (and
+// including) toBlock using poller.Concurrency workers, and calls
+// handleBlock on each of them from the calling goroutine, one at a
+// time and in chain order. Workers stop fetching ahead once
+// poller.PrefetchLimit blocks are waiting to be handled. Stops at the
+// first error, either from fetching or from handleBlock
+func (poller *Poller) FetchBlockRange(fromBlock, toBlock uint64, handleBlock func(*FetchedBlock) error) error {
+	concurrency := poller.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	prefetchLimit := poller.PrefetchLimit
+	if prefetchLimit < concurrency {
+		prefetchLimit = concurrency
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	jobs := make(chan fetchJob)
+	// Results are queued in chain order, the size of the queue
+	// bounds how far ahead of handleBlock workers can get
+	results := make(chan chan fetchResult, prefetchLimit)
+
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for job := range jobs {
+				fetchedBlock, err := poller.FetchBlock(new(big.Int).SetUint64(job.blockNumber))
+				job.result <- fetchResult{fetchedBlock, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(results)
+		defer close(jobs)
+
+		for blockNumber := fromBlock; blockNumber <= toBlock; blockNumber++ {
+			// Buffered so that workers never block on a
+			// result that won't be read
+			result := make(chan fetchResult, 1)
+
+			select {
+			case results <- result:
+			case <-done:
+				return
+			}
+
+			select {
+			case jobs <- fetchJob{blockNumber, result}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for result := range results {
+		fetchResult := <-result
+		if fetchResult.err != nil {
+			return fetchResult.err
+		}
+
+		err := handleBlock(fetchResult.fetchedBlock)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
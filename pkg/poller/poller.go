@@ -3,38 +3,103 @@ package poller
 import (
 	"context"
 	"errors"
+	"fmt"
 	"getherscan/pkg/models"
 	"log"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"gorm.io/gorm"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jackc/pgtype"
 )
 
 type Poller struct {
-	DB               *models.DB
+	Store            models.Store
+	RPCClient        *rpc.Client
 	EthClient        *ethclient.Client
 	Context          context.Context
 	TrackedAddresses []string
+	ForkChoice       ForkChoice
+	// How often to fetch the node's safe and finalized heads, 0
+	// disables finality tracking
+	FinalityPollInterval time.Duration
+	// How often to reload the tracked addresses from the store, 0
+	// disables picking up changes while polling
+	TrackedAddressesPollInterval time.Duration
+	// How many blocks below the head the balances of tracked
+	// addresses added with a backfill are backfilled
+	TrackedAddressesBackfillDepth uint64
+	// Number of workers fetching blocks concurrently when catching
+	// up on missed blocks or backfilling
+	Concurrency int
+	// Maximum number of fetched blocks waiting to be indexed
+	PrefetchLimit int
+	// Whether or not to fetch and index transaction receipts and
+	// logs for canonical blocks
+	IndexReceipts bool
+	// Whether or not to store the binary encoding of transactions
+	StoreRawTransactions bool
+	// Whether or not to trace canonical blocks to index their
+	// internal transactions, which needs a node serving the debug
+	// namespace
+	TraceInternalTransactions bool
+	// Whether or not to trace canonical blocks to index the balance
+	// changes of every address they touch, which needs a node
+	// serving the debug namespace
+	TraceBalanceDeltas bool
 }
 
 func (poller *Poller) Initialize(wsRPCEndpoint, dbConnectionString string, trackedAddresses []string) error {
-	poller.DB = new(models.DB)
-	err := poller.DB.Initialize(dbConnectionString)
+	var err error
+	poller.Store, err = models.OpenStore(dbConnectionString)
 	if err != nil {
 		return err
 	}
 
-	poller.EthClient, err = ethclient.Dial(wsRPCEndpoint)
+	poller.RPCClient, err = rpc.Dial(wsRPCEndpoint)
 	if err != nil {
 		return err
 	}
 
+	poller.EthClient = ethclient.NewClient(poller.RPCClient)
+
 	poller.Context = context.Background()
 
-	poller.TrackedAddresses = trackedAddresses
+	// Addresses from the file are tracked alongside those added
+	// through the API server
+	err = poller.SeedTrackedAddresses(trackedAddresses)
+	if err != nil {
+		return err
+	}
+
+	_, err = poller.LoadTrackedAddresses()
+	if err != nil {
+		return err
+	}
+
+	poller.TrackedAddressesPollInterval = DefaultTrackedAddressesPollInterval
+	poller.TrackedAddressesBackfillDepth = DefaultTrackedAddressesBackfillDepth
+
+	poller.Concurrency = DefaultConcurrency
+	poller.PrefetchLimit = DefaultPrefetchLimit
+
+	poller.IndexReceipts = true
+
+	chainID, err := poller.EthClient.ChainID(poller.Context)
+	if err != nil {
+		return err
+	}
+
+	poller.ForkChoice = GetDefaultForkChoiceForChainID(chainID)
+
+	// Safe and finalized block tags only exist on proof-of-stake
+	// chains
+	if IsProofOfStakeChainID(chainID) {
+		poller.FinalityPollInterval = DefaultFinalityPollInterval
+	}
 
 	return nil
 }
@@ -46,10 +111,64 @@ func (poller *Poller) Poll() error {
 		return err
 	}
 
+	// A nil channel blocks forever, disabling finality updates
+	var finalityChannel <-chan time.Time
+	if poller.FinalityPollInterval > 0 {
+		finalityTicker := time.NewTicker(poller.FinalityPollInterval)
+		defer finalityTicker.Stop()
+		finalityChannel = finalityTicker.C
+	}
+
+	var trackedAddressesChannel <-chan time.Time
+	if poller.TrackedAddressesPollInterval > 0 {
+		trackedAddressesTicker := time.NewTicker(poller.TrackedAddressesPollInterval)
+		defer trackedAddressesTicker.Stop()
+		trackedAddressesChannel = trackedAddressesTicker.C
+	}
+
+	// Tracked addresses' balances are backfilled a block at a
+	// time in between new heads, so that backfills don't hold up
+	// indexing, and don't write to the store concurrently with it.
+	// A nil channel blocks forever, pausing the backfill
+	var backfillChannel <-chan time.Time
+
 	for {
 		select {
 		case err := <-subscription.Err():
 			return err
+		case <-finalityChannel:
+			// Failing to update finality shouldn't stop
+			// us from indexing new blocks
+			err := poller.UpdateFinality()
+			if err != nil {
+				log.Printf("Could not update finality: %s\n", err)
+			}
+		case <-trackedAddressesChannel:
+			// Same for tracked addresses, whose update
+			// is retried on the next tick
+			trackedAddresses, err := poller.LoadTrackedAddresses()
+			if err != nil {
+				log.Printf("Could not update tracked addresses: %s\n", err)
+				break
+			}
+
+			if backfillChannel == nil && IsBackfillPending(trackedAddresses) {
+				backfillChannel = time.After(0)
+			}
+		case <-backfillChannel:
+			// Retried on the next tick as well, from
+			// where the backfill left off
+			backfillChannel = nil
+
+			pending, err := poller.BackfillTrackedAddressesStep()
+			if err != nil {
+				log.Printf("Could not backfill tracked addresses: %s\n", err)
+				break
+			}
+
+			if pending {
+				backfillChannel = time.After(0)
+			}
 		case header := <-headerChannel:
 			// Fetch full new block
 			block, err := poller.EthClient.BlockByHash(poller.Context, header.Hash())
@@ -66,6 +185,12 @@ func (poller *Poller) Poll() error {
 }
 
 func (poller *Poller) Index(block *types.Block) error {
+	return poller.IndexFetchedBlock(&FetchedBlock{Block: block})
+}
+
+func (poller *Poller) IndexFetchedBlock(fetchedBlock *FetchedBlock) error {
+	block := fetchedBlock.Block
+
 	// Check if we've already indexed this block (could be
 	// possible due to IndexMissedBlocks())
 	isIndexed, err := poller.CheckIfIndexed(block.Hash().Hex())
@@ -78,10 +203,10 @@ func (poller *Poller) Index(block *types.Block) error {
 	}
 
 	// Fetch latest indexed block
-	head, err := poller.DB.GetHead()
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	head, err := poller.Store.GetHead()
+	if errors.Is(err, models.ErrRecordNotFound) {
 		// No blocks have been indexed yet
-		err = poller.IndexNewBlock(block)
+		err = poller.IndexNewBlock(fetchedBlock)
 		if err != nil {
 			return err
 		}
@@ -98,14 +223,35 @@ func (poller *Poller) Index(block *types.Block) error {
 	// preceding block(s)
 	newBlockParentHash := block.ParentHash().Hex()
 	if head.Hash != newBlockParentHash {
-		_, err = poller.DB.GetOrphanedBlockByHash(newBlockParentHash)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// We haven't indexed the new block's parent
-			err = poller.IndexMissedBlocks(newBlockParentHash)
-		} else if err != nil {
+		// Blocks at or behind the finalized head can never
+		// become canonical, don't bother keeping them
+		isBehindFinalizedHead, err := poller.IsBehindFinalizedHead(block)
+		if err != nil {
+			return err
+		}
+
+		if isBehindFinalizedHead {
+			log.Printf("Skipped block %s behind finalized head\n", block.Hash().Hex())
+			return nil
+		}
+
+		isParentIndexed, err := poller.CheckIfIndexed(newBlockParentHash)
+		if err != nil {
 			return err
 		}
 
+		if !isParentIndexed {
+			// We haven't indexed the new block's parent,
+			// catch up and start over, as the local head
+			// has likely changed
+			err = poller.IndexMissedBlocks(block, head)
+			if err != nil {
+				return err
+			}
+
+			return poller.IndexFetchedBlock(fetchedBlock)
+		}
+
 		orphanedBlockModel, err := MakeOrphanedBlockModel(block)
 		if err != nil {
 			return err
@@ -117,33 +263,13 @@ func (poller *Poller) Index(block *types.Block) error {
 			return err
 		}
 
-		currentTotalDifficulty, err := poller.GetTotalCanonicalDifficultySince(canonicalAncestorHash, head)
-		if err != nil {
-			return err
-		}
-
-		newTotalDifficulty, err := poller.GetTotalOrphanedDifficultySince(canonicalAncestorHash, orphanedBlockModel)
+		shouldReorg, err := poller.ForkChoice.ShouldReorg(poller, head, orphanedBlockModel, canonicalAncestorHash)
 		if err != nil {
 			return err
 		}
 
-		if newTotalDifficulty.Cmp(currentTotalDifficulty) > 0 {
-			// (Effective) total difficulty of new block
-			// is higher than that of local head, reorg
-
-			err = poller.Reorg(block, head, canonicalAncestorHash)
-			if err != nil {
-				return err
-			}
-		} else if newTotalDifficulty.Cmp(currentTotalDifficulty) == 0 && orphanedBlockModel.Number.Int.Cmp(head.Number.Int) < 0 {
-			// (Effective) total difficulties of both new
-			// block and local head are equal, but new
-			// block has a lower block number (will
-			// necessarily have a higher total difficulty
-			// once it reaches the same block number),
-			// reorg
-
-			err = poller.Reorg(block, head, canonicalAncestorHash)
+		if shouldReorg {
+			err = poller.Reorg(fetchedBlock, head, canonicalAncestorHash)
 			if err != nil {
 				return err
 			}
@@ -156,70 +282,182 @@ func (poller *Poller) Index(block *types.Block) error {
 			}
 		}
 	} else {
-		err = poller.IndexNewBlock(block)
+		err = poller.IndexNewBlock(fetchedBlock)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (poller *Poller) IndexNewBlock(block *types.Block) error {
-	// Create model for block and write to DB
+// Runs fn with a copy of the poller whose store is transactional,
+// so that its writes are committed only if fn returns nil
+func (poller *Poller) Atomically(fn func(txPoller *Poller) error) error {
+	return poller.Store.Atomically(func(txStore models.Store) error {
+		txPoller := *poller
+		txPoller.Store = txStore
+		return fn(&txPoller)
+	})
+}
 
-	blockModel, err := MakeBlockModel(block)
+func (poller *Poller) IndexNewBlock(fetchedBlock *FetchedBlock) error {
+	block := fetchedBlock.Block
+
+	err := poller.CompleteFetchedBlock(fetchedBlock)
 	if err != nil {
 		return err
 	}
 
-	err = poller.DB.Create(blockModel).Error
+	blockModel, err := MakeBlockModel(block)
 	if err != nil {
 		return err
 	}
 
-	// For each transaction in the block, create a model for it
-	// and write to DB
+	err = poller.Atomically(func(txPoller *Poller) error {
+		// Write model for block to DB
+
+		err := txPoller.Store.CreateBlock(blockModel)
+		if err != nil {
+			return err
+		}
+
+		// For each transaction in the block, create a model
+		// for it and write to DB
+
+		for i, transaction := range block.Transactions() {
+			transactionModel, err := MakeTransactionModel(transaction, uint(i), block)
+			if err != nil {
+				return err
+			}
+
+			if txPoller.StoreRawTransactions {
+				transactionModel.Raw, err = transaction.MarshalBinary()
+				if err != nil {
+					return err
+				}
+			}
+
+			err = txPoller.Store.CreateTransaction(transactionModel)
+			if err != nil {
+				return err
+			}
+		}
+
+		// For each withdrawal in the block, create a model for
+		// it and write to DB
+
+		for _, withdrawal := range block.Withdrawals() {
+			withdrawalModel, err := MakeWithdrawalModel(withdrawal, block)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.CreateWithdrawal(withdrawalModel)
+			if err != nil {
+				return err
+			}
+		}
+
+		// For each uncle of the block, create a model for its
+		// header and write to DB
+
+		for i, uncle := range block.Uncles() {
+			uncleModel, err := MakeUncleModel(uncle, uint(i), block)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.CreateUncle(uncleModel)
+			if err != nil {
+				return err
+			}
+		}
+
+		// For each receipt (if fetched), create a model for it
+		// and its logs and write them to DB
+
+		logModels := []*models.Log{}
+		for i, receipt := range fetchedBlock.Receipts {
+			transaction := block.Transactions()[i]
+			receiptModel, err := MakeReceiptModel(
+				receipt,
+				GetEffectiveGasPrice(transaction.GasTipCap(), transaction.GasFeeCap(), block.BaseFee()),
+			)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.CreateReceipt(receiptModel)
+			if err != nil {
+				return err
+			}
+
+			for _, eventLog := range receipt.Logs {
+				logModel, err := MakeLogModel(eventLog)
+				if err != nil {
+					return err
+				}
+
+				err = txPoller.Store.CreateLog(logModel)
+				if err != nil {
+					return err
+				}
+
+				logModels = append(logModels, logModel)
+			}
+		}
+
+		err = txPoller.IndexTokenTransfers(logModels)
+		if err != nil {
+			return err
+		}
 
-	for _, transaction := range block.Transactions() {
-		transactionModel, err := MakeTransactionModel(transaction, blockModel.Hash)
+		err = txPoller.IndexNFTTransfers(logModels)
 		if err != nil {
 			return err
 		}
 
-		err = poller.DB.Create(transactionModel).Error
+		// Create models for the internal transactions (if
+		// traced) and write them to DB
+
+		err = txPoller.IndexInternalTransactions(fetchedBlock.Traces, blockModel.Hash, blockModel.Number)
 		if err != nil {
 			return err
 		}
-	}
 
-	// For each tracked address, create a model for it and write
-	// it to the DB
+		// Create models for the balance deltas (if traced) and
+		// write them to DB
+
+		if fetchedBlock.StateDiffs != nil {
+			err = txPoller.IndexBalanceDeltas(fetchedBlock.StateDiffs, block.Withdrawals(), blockModel.Hash, blockModel.Number)
+			if err != nil {
+				return err
+			}
+		}
 
-	err = poller.IndexAddressBalancesForBlock(blockModel.Number.Int, blockModel.Hash)
+		// For each tracked address, create a model for it and
+		// write it to the DB
+
+		return txPoller.IndexAddressBalances(fetchedBlock.Balances, blockModel.Hash, blockModel.Number)
+	})
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Indexed block %s\n", blockModel.Number.Int.String())
+	log.Printf("Indexed block %s\n", models.NumericToBigInt(blockModel.Number).String())
 
 	return nil
 }
 
-func (poller *Poller) IndexAddressBalancesForBlock(blockNumber *big.Int, blockHash string) error {
-	for _, address := range poller.TrackedAddresses {
-		balance, err := poller.EthClient.BalanceAt(
-			poller.Context,
-			common.HexToAddress(address),
-			blockNumber,
-		)
-		if err != nil {
-			return err
-		}
-
-		balanceModel, err := MakeBalanceModel(balance, address, blockHash)
+func (poller *Poller) IndexAddressBalances(balances map[string]*big.Int, blockHash string, blockNumber pgtype.Numeric) error {
+	for address, balance := range balances {
+		balanceModel, err := MakeBalanceModel(balance, address, blockHash, blockNumber)
 		if err != nil {
 			return err
 		}
 
-		err = poller.DB.Create(balanceModel).Error
+		err = poller.Store.CreateBalance(balanceModel)
 		if err != nil {
 			return err
 		}
@@ -229,44 +467,103 @@ func (poller *Poller) IndexAddressBalancesForBlock(blockNumber *big.Int, blockHa
 }
 
 func (poller *Poller) IndexNewOrphanedBlock(block *types.Block) error {
-	// Create model for block and write to DB
-
 	orphanedBlockModel, err := MakeOrphanedBlockModel(block)
 	if err != nil {
 		return err
 	}
 
-	err = poller.DB.Create(orphanedBlockModel).Error
-	if err != nil {
-		return err
-	}
-
-	// For each transaction in the block, create a model
-	// for it and write to DB
+	err = poller.Atomically(func(txPoller *Poller) error {
+		// Write model for block to DB
 
-	for _, transaction := range block.Transactions() {
-		orphanedTransactionModel, err := MakeOrphanedTransactionModel(transaction, orphanedBlockModel.Hash)
+		err := txPoller.Store.CreateOrphanedBlock(orphanedBlockModel)
 		if err != nil {
 			return err
 		}
 
-		err = poller.DB.Create(orphanedTransactionModel).Error
-		if err != nil {
-			return err
+		// For each transaction in the block, create a model
+		// for it and write to DB
+
+		for i, transaction := range block.Transactions() {
+			orphanedTransactionModel, err := MakeOrphanedTransactionModel(transaction, uint(i), block)
+			if err != nil {
+				return err
+			}
+
+			if txPoller.StoreRawTransactions {
+				orphanedTransactionModel.Raw, err = transaction.MarshalBinary()
+				if err != nil {
+					return err
+				}
+			}
+
+			err = txPoller.Store.CreateOrphanedTransaction(orphanedTransactionModel)
+			if err != nil {
+				return err
+			}
+		}
+
+		// For each withdrawal in the block, create a model for
+		// it and write to DB
+
+		for _, withdrawal := range block.Withdrawals() {
+			orphanedWithdrawalModel, err := MakeOrphanedWithdrawalModel(withdrawal, block)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.CreateOrphanedWithdrawal(orphanedWithdrawalModel)
+			if err != nil {
+				return err
+			}
+		}
+
+		// For each uncle of the block, create a model for its
+		// header and write to DB
+
+		for i, uncle := range block.Uncles() {
+			orphanedUncleModel, err := MakeOrphanedUncleModel(uncle, uint(i), block)
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.Store.CreateOrphanedUncle(orphanedUncleModel)
+			if err != nil {
+				return err
+			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Indexed orphaned block %s\n", orphanedBlockModel.Number.Int.String())
+	log.Printf("Indexed orphaned block %s\n", models.NumericToBigInt(orphanedBlockModel.Number).String())
 
 	return nil
 }
 
-// Fetches a chain of blocks that have been missed, until an ancestor
-// in this chain has been found in the indexer. Assumes that an
-// indexed ancestor exists. Indexes missed blocks as orphaned blocks,
-// they will be canonicalized appropriately if necessary in the reorg
-// check in Index()
-func (poller *Poller) IndexMissedBlocks(currentBlockHash string) error {
+// Fetches the blocks that have been missed between the local head and
+// the given block. First, the node's canonical blocks between the two
+// are fetched concurrently and indexed in order, which covers the
+// common case of the poller having been down for a while. Then, if
+// the given block's parent still hasn't been indexed (e.g. the block
+// is on another fork), walks back its ancestors one at a time until
+// an ancestor has been found in the indexer. Assumes that an indexed
+// ancestor exists. Indexes these ancestors as orphaned blocks, they
+// will be canonicalized appropriately if necessary in the reorg check
+// in Index()
+func (poller *Poller) IndexMissedBlocks(block *types.Block, head *models.Block) error {
+	fromBlock := new(big.Int).Add(models.NumericToBigInt(head.Number), big.NewInt(1))
+	toBlock := new(big.Int).Sub(block.Number(), big.NewInt(1))
+	if fromBlock.Cmp(toBlock) <= 0 {
+		err := poller.FetchBlockRange(fromBlock.Uint64(), toBlock.Uint64(), poller.IndexFetchedBlock)
+		if err != nil {
+			return err
+		}
+	}
+
+	currentBlockHash := block.ParentHash().Hex()
 	for {
 		isIndexed, err := poller.CheckIfIndexed(currentBlockHash)
 		if err != nil {
@@ -277,17 +574,17 @@ func (poller *Poller) IndexMissedBlocks(currentBlockHash string) error {
 			break
 		}
 
-		block, err := poller.EthClient.BlockByHash(poller.Context, common.HexToHash(currentBlockHash))
+		missedBlock, err := poller.EthClient.BlockByHash(poller.Context, common.HexToHash(currentBlockHash))
 		if err != nil {
 			return err
 		}
 
-		err = poller.IndexNewOrphanedBlock(block)
+		err = poller.IndexNewOrphanedBlock(missedBlock)
 		if err != nil {
 			return err
 		}
 
-		currentBlockHash = block.ParentHash().Hex()
+		currentBlockHash = missedBlock.ParentHash().Hex()
 	}
 
 	return nil
@@ -303,7 +600,7 @@ func (poller *Poller) FindCanonicalAncestorHash(orphanedBlockParentHash string)
 	// parent can't be found
 
 	for {
-		orphanedBlock, err = poller.DB.GetOrphanedBlockByHash(orphanedBlockParentHash)
+		orphanedBlock, err = poller.Store.GetOrphanedBlockByHash(orphanedBlockParentHash)
 		if err != nil {
 			break
 		}
@@ -311,7 +608,7 @@ func (poller *Poller) FindCanonicalAncestorHash(orphanedBlockParentHash string)
 		orphanedBlockParentHash = orphanedBlock.ParentHash
 	}
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if errors.Is(err, models.ErrRecordNotFound) {
 		// Could not find orphaned block matching
 		// orphanedBlockParentHash, parent block must
 		// be canonical
@@ -328,9 +625,9 @@ func (poller *Poller) GetTotalCanonicalDifficultySince(ancestorHash string, bloc
 	// Starting with given block, add difficulties up to (but
 	// excluding) the block with ancestorHash
 	for currentBlock := block; currentBlock.Hash != ancestorHash; {
-		totalDifficulty = new(big.Int).Add(totalDifficulty, currentBlock.Difficulty.Int)
+		totalDifficulty = new(big.Int).Add(totalDifficulty, models.NumericToBigInt(currentBlock.Difficulty))
 
-		currentBlock, err = poller.DB.GetBlockByHash(currentBlock.ParentHash)
+		currentBlock, err = poller.Store.GetBlockByHash(currentBlock.ParentHash)
 		if err != nil {
 			return nil, err
 		}
@@ -341,78 +638,106 @@ func (poller *Poller) GetTotalCanonicalDifficultySince(ancestorHash string, bloc
 
 func (poller *Poller) GetTotalOrphanedDifficultySince(ancestorHash string, orphanedBlock *models.OrphanedBlock) (*big.Int, error) {
 	var err error
-	totalDifficulty := orphanedBlock.Difficulty.Int
+	totalDifficulty := models.NumericToBigInt(orphanedBlock.Difficulty)
 
 	// Starting with the given orphaned block, add difficulties up
 	// to (but excluding) the block with ancestorHash
 	for currentOrphanedBlock := orphanedBlock; currentOrphanedBlock.ParentHash != ancestorHash; {
-		currentOrphanedBlock, err = poller.DB.GetOrphanedBlockByHash(currentOrphanedBlock.ParentHash)
+		currentOrphanedBlock, err = poller.Store.GetOrphanedBlockByHash(currentOrphanedBlock.ParentHash)
 		if err != nil {
 			return nil, err
 		}
 
-		totalDifficulty = new(big.Int).Add(totalDifficulty, currentOrphanedBlock.Difficulty.Int)
+		totalDifficulty = new(big.Int).Add(totalDifficulty, models.NumericToBigInt(currentOrphanedBlock.Difficulty))
 	}
 
 	return totalDifficulty, nil
 }
 
-func (poller *Poller) Reorg(newHead *types.Block, oldHead *models.Block, canonicalAncestorHash string) error {
+func (poller *Poller) Reorg(newHead *FetchedBlock, oldHead *models.Block, canonicalAncestorHash string) error {
 	var err error
 
-	// For each block from (and including) oldHead up to (but
-	// excluding) the block with canonicalAncestorHash, orphan the
-	// block
+	// Fetch the balances (and receipts, traces and state diffs) of
+	// every block that will be canonicalized up front, so that the DB
+	// transaction below doesn't wait on the node
+
+	err = poller.CompleteFetchedBlock(newHead)
+	if err != nil {
+		return err
+	}
+
+	fetchedOrphanedBlocks := []*FetchedOrphanedBlock{}
 
-	for currentBlock := oldHead; currentBlock.Hash != canonicalAncestorHash; {
-		err = poller.OrphanBlock(currentBlock)
+	// If reorg depth > 1, collect the orphaned blocks from (but
+	// excluding) newHead up to (but excluding) the block with
+	// canonicalAncestorHash
+	for currentHash := newHead.Block.ParentHash().Hex(); currentHash != canonicalAncestorHash; {
+		orphanedBlock, err := poller.Store.GetOrphanedBlockByHash(currentHash)
 		if err != nil {
 			return err
 		}
 
-		currentBlock, err = poller.DB.GetBlockByHash(currentBlock.ParentHash)
+		fetchedOrphanedBlock, err := poller.FetchOrphanedBlock(orphanedBlock)
 		if err != nil {
 			return err
 		}
-	}
 
-	// Index newHead, then for each block from (but excluding)
-	// newHead up to (but excluding) the block with
-	// canonicalAncestorHash, canonicalize the block.
+		fetchedOrphanedBlocks = append(fetchedOrphanedBlocks, fetchedOrphanedBlock)
 
-	err = poller.IndexNewBlock(newHead)
-	if err != nil {
-		return err
+		currentHash = orphanedBlock.ParentHash
 	}
 
-	// If reorg depth > 1
-	if newHead.ParentHash().Hex() != canonicalAncestorHash {
-		currentOrphanedBlock, err := poller.DB.GetOrphanedBlockByHash(newHead.ParentHash().Hex())
-		if err != nil {
-			return err
-		}
+	// Perform the entire reorg in a single DB transaction, so
+	// that the indexed chain is never left partially reorged
+
+	err = poller.Atomically(func(txPoller *Poller) error {
+		var err error
+
+		// For each block from (and including) oldHead up to
+		// (but excluding) the block with
+		// canonicalAncestorHash, orphan the block
+
+		for currentBlock := oldHead; currentBlock.Hash != canonicalAncestorHash; {
+			if currentBlock.Finality == models.FinalityFinalized {
+				return fmt.Errorf("Cannot orphan finalized block %s", currentBlock.Hash)
+			}
 
-		for {
-			err = poller.CanonicalizeBlock(currentOrphanedBlock)
+			err = txPoller.OrphanBlock(currentBlock)
 			if err != nil {
 				return err
 			}
 
-			if currentOrphanedBlock.ParentHash == canonicalAncestorHash {
-				break
+			currentBlock, err = txPoller.Store.GetBlockByHash(currentBlock.ParentHash)
+			if err != nil {
+				return err
 			}
+		}
+
+		// Index newHead, then canonicalize the orphaned
+		// blocks collected above
+
+		err = txPoller.IndexNewBlock(newHead)
+		if err != nil {
+			return err
+		}
 
-			currentOrphanedBlock, err = poller.DB.GetOrphanedBlockByHash(currentOrphanedBlock.ParentHash)
+		for _, fetchedOrphanedBlock := range fetchedOrphanedBlocks {
+			err = txPoller.CanonicalizeBlock(fetchedOrphanedBlock)
 			if err != nil {
 				return err
 			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf(
 		"Reorged head %s for head %s\n",
 		oldHead.Hash,
-		newHead.Hash().Hex(),
+		newHead.Block.Hash().Hex(),
 	)
 
 	return nil
@@ -421,52 +746,151 @@ func (poller *Poller) Reorg(newHead *types.Block, oldHead *models.Block, canonic
 func (poller *Poller) OrphanBlock(block *models.Block) error {
 	// Delete transactions associated with block, save temporarily
 
-	transactions, err := poller.DB.GetTransactionsForBlockHash(block.Hash)
+	transactions, err := poller.Store.GetTransactionsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteTransactionsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete logs and receipts associated with block, save
+	// temporarily
+
+	logs, err := poller.Store.GetLogsForBlockHash(block.Hash, nil)
 	if err != nil {
 		return err
 	}
 
-	err = poller.DB.Delete(&models.Transaction{}, "block_hash = ?", block.Hash).Error
+	err = poller.Store.DeleteLogsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	receipts, err := poller.Store.GetReceiptsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteReceiptsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete token and NFT transfers and balance deltas
+	// associated with block, they are recomputed from its logs if
+	// it's canonicalized
+
+	err = poller.Store.DeleteTokenTransfersForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteTokenBalanceDeltasForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteNFTTransfersForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteNFTBalanceDeltasForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete withdrawals associated with block, save temporarily
+
+	withdrawals, err := poller.Store.GetWithdrawalsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteWithdrawalsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete uncles associated with block, save temporarily
+
+	uncles, err := poller.Store.GetUnclesForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteUnclesForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete internal transactions associated with block, save
+	// temporarily
+
+	internalTransactions, err := poller.Store.GetInternalTransactionsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteInternalTransactionsForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete balance deltas associated with block, save temporarily
+
+	balanceDeltas, err := poller.Store.GetBalanceDeltasForBlockHash(block.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteBalanceDeltasForBlockHash(block.Hash)
 	if err != nil {
 		return err
 	}
 
 	// Delete balances associated with block
 
-	err = poller.DB.Delete(&models.Balance{}, "block_hash = ?", block.Hash).Error
+	err = poller.Store.DeleteBalancesForBlockHash(block.Hash)
 	if err != nil {
 		return err
 	}
 
 	// Delete block
 
-	err = poller.DB.Delete(block).Error
+	err = poller.Store.DeleteBlock(block.Hash)
 	if err != nil {
 		return err
 	}
 
 	// Create model for orphaned block
 
-	err = poller.DB.Create(&models.OrphanedBlock{
-		Hash:        block.Hash,
-		Size:        block.Size,
-		ParentHash:  block.ParentHash,
-		UncleHash:   block.UncleHash,
-		Coinbase:    block.Coinbase,
-		Root:        block.Root,
-		TxHash:      block.TxHash,
-		ReceiptHash: block.ReceiptHash,
-		Bloom:       block.Bloom,
-		Difficulty:  block.Difficulty,
-		Number:      block.Number,
-		GasLimit:    block.GasLimit,
-		GasUsed:     block.GasUsed,
-		Time:        block.Time,
-		Extra:       block.Extra,
-		MixDigest:   block.MixDigest,
-		Nonce:       block.Nonce,
-		BaseFee:     block.BaseFee,
-	}).Error
+	err = poller.Store.CreateOrphanedBlock(&models.OrphanedBlock{
+		Hash:            block.Hash,
+		Size:            block.Size,
+		ParentHash:      block.ParentHash,
+		UncleHash:       block.UncleHash,
+		Coinbase:        block.Coinbase,
+		Root:            block.Root,
+		TxHash:          block.TxHash,
+		ReceiptHash:     block.ReceiptHash,
+		Bloom:           block.Bloom,
+		Difficulty:      block.Difficulty,
+		Number:          block.Number,
+		GasLimit:        block.GasLimit,
+		GasUsed:         block.GasUsed,
+		Time:            block.Time,
+		Extra:           block.Extra,
+		MixDigest:       block.MixDigest,
+		Nonce:           block.Nonce,
+		BaseFee:         block.BaseFee,
+		BlobGasUsed:     block.BlobGasUsed,
+		ExcessBlobGas:   block.ExcessBlobGas,
+		WithdrawalsRoot: block.WithdrawalsRoot,
+	})
 	if err != nil {
 		return err
 	}
@@ -474,22 +898,167 @@ func (poller *Poller) OrphanBlock(block *models.Block) error {
 	// Create models for orphaned transactions
 
 	for _, transaction := range transactions {
-		err = poller.DB.Create(&models.OrphanedTransaction{
+		orphanedAccessList := make([]models.OrphanedAccessTuple, len(transaction.AccessList))
+		for i, accessTuple := range transaction.AccessList {
+			orphanedAccessList[i] = models.OrphanedAccessTuple{
+				TransactionHash:   accessTuple.TransactionHash,
+				OrphanedBlockHash: accessTuple.BlockHash,
+				TupleIndex:        accessTuple.TupleIndex,
+				Address:           accessTuple.Address,
+				StorageKeys:       accessTuple.StorageKeys,
+			}
+		}
+
+		err = poller.Store.CreateOrphanedTransaction(&models.OrphanedTransaction{
 			Hash:              transaction.Hash,
 			Size:              transaction.Size,
 			From:              transaction.From,
 			Type:              transaction.Type,
 			ChainID:           transaction.ChainID,
+			AccessList:        orphanedAccessList,
 			Data:              transaction.Data,
 			Gas:               transaction.Gas,
 			GasPrice:          transaction.GasPrice,
 			GasTipCap:         transaction.GasTipCap,
 			GasFeeCap:         transaction.GasFeeCap,
+			BlobGasFeeCap:     transaction.BlobGasFeeCap,
+			BlobHashes:        transaction.BlobHashes,
 			Value:             transaction.Value,
 			Nonce:             transaction.Nonce,
 			To:                transaction.To,
+			V:                 transaction.V,
+			R:                 transaction.R,
+			S:                 transaction.S,
+			Raw:               transaction.Raw,
 			OrphanedBlockHash: transaction.BlockHash,
-		}).Error
+			BlockNumber:       transaction.BlockNumber,
+			TransactionIndex:  transaction.TransactionIndex,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for orphaned withdrawals
+
+	for _, withdrawal := range withdrawals {
+		err = poller.Store.CreateOrphanedWithdrawal(&models.OrphanedWithdrawal{
+			OrphanedBlockHash: withdrawal.BlockHash,
+			WithdrawalIndex:   withdrawal.WithdrawalIndex,
+			ValidatorIndex:    withdrawal.ValidatorIndex,
+			Address:           withdrawal.Address,
+			Amount:            withdrawal.Amount,
+			BlockNumber:       withdrawal.BlockNumber,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for orphaned uncles
+
+	for _, uncle := range uncles {
+		err = poller.Store.CreateOrphanedUncle(&models.OrphanedUncle{
+			OrphanedBlockHash: uncle.BlockHash,
+			UncleIndex:        uncle.UncleIndex,
+			Hash:              uncle.Hash,
+			ParentHash:        uncle.ParentHash,
+			UncleHash:         uncle.UncleHash,
+			Coinbase:          uncle.Coinbase,
+			Root:              uncle.Root,
+			TxHash:            uncle.TxHash,
+			ReceiptHash:       uncle.ReceiptHash,
+			Bloom:             uncle.Bloom,
+			Difficulty:        uncle.Difficulty,
+			Number:            uncle.Number,
+			GasLimit:          uncle.GasLimit,
+			GasUsed:           uncle.GasUsed,
+			Time:              uncle.Time,
+			Extra:             uncle.Extra,
+			MixDigest:         uncle.MixDigest,
+			Nonce:             uncle.Nonce,
+			BaseFee:           uncle.BaseFee,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for orphaned internal transactions
+
+	for _, internalTransaction := range internalTransactions {
+		err = poller.Store.CreateOrphanedInternalTransaction(&models.OrphanedInternalTransaction{
+			OrphanedBlockHash: internalTransaction.BlockHash,
+			TraceIndex:        internalTransaction.TraceIndex,
+			TransactionHash:   internalTransaction.TransactionHash,
+			TransactionIndex:  internalTransaction.TransactionIndex,
+			TraceAddress:      internalTransaction.TraceAddress,
+			Type:              internalTransaction.Type,
+			From:              internalTransaction.From,
+			To:                internalTransaction.To,
+			Value:             internalTransaction.Value,
+			Gas:               internalTransaction.Gas,
+			GasUsed:           internalTransaction.GasUsed,
+			Input:             internalTransaction.Input,
+			Output:            internalTransaction.Output,
+			Error:             internalTransaction.Error,
+			BlockNumber:       internalTransaction.BlockNumber,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for orphaned balance deltas
+
+	for _, balanceDelta := range balanceDeltas {
+		err = poller.Store.CreateOrphanedBalanceDelta(&models.OrphanedBalanceDelta{
+			Address:           balanceDelta.Address,
+			OrphanedBlockHash: balanceDelta.BlockHash,
+			BlockNumber:       balanceDelta.BlockNumber,
+			Delta:             balanceDelta.Delta,
+			PreviousBalance:   balanceDelta.PreviousBalance,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for orphaned receipts and logs
+
+	for _, receipt := range receipts {
+		err = poller.Store.CreateOrphanedReceipt(&models.OrphanedReceipt{
+			TransactionHash:   receipt.TransactionHash,
+			TransactionIndex:  receipt.TransactionIndex,
+			Type:              receipt.Type,
+			PostState:         receipt.PostState,
+			Status:            receipt.Status,
+			CumulativeGasUsed: receipt.CumulativeGasUsed,
+			Bloom:             receipt.Bloom,
+			GasUsed:           receipt.GasUsed,
+			EffectiveGasPrice: receipt.EffectiveGasPrice,
+			ContractAddress:   receipt.ContractAddress,
+			OrphanedBlockHash: receipt.BlockHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, logModel := range logs {
+		err = poller.Store.CreateOrphanedLog(&models.OrphanedLog{
+			OrphanedBlockHash: logModel.BlockHash,
+			LogIndex:          logModel.LogIndex,
+			Address:           logModel.Address,
+			Topic0:            logModel.Topic0,
+			Topic1:            logModel.Topic1,
+			Topic2:            logModel.Topic2,
+			Topic3:            logModel.Topic3,
+			Data:              logModel.Data,
+			BlockNumber:       logModel.BlockNumber,
+			TransactionHash:   logModel.TransactionHash,
+			TransactionIndex:  logModel.TransactionIndex,
+		})
 		if err != nil {
 			return err
 		}
@@ -500,49 +1069,130 @@ func (poller *Poller) OrphanBlock(block *models.Block) error {
 	return nil
 }
 
-func (poller *Poller) CanonicalizeBlock(orphanedBlock *models.OrphanedBlock) error {
+func (poller *Poller) CanonicalizeBlock(fetchedOrphanedBlock *FetchedOrphanedBlock) error {
+	orphanedBlock := fetchedOrphanedBlock.OrphanedBlock
+
+	// Delete orphaned logs and receipts associated with orphaned
+	// block, save temporarily
+
+	orphanedLogs, err := poller.Store.GetOrphanedLogsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedLogsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	orphanedReceipts, err := poller.Store.GetOrphanedReceiptsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedReceiptsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
 	// Delete orphaned transactions associated with orphaned
 	// block, save temporarily
 
-	orphanedTransactions, err := poller.DB.GetOrphanedTransactionsForBlockHash(orphanedBlock.Hash)
+	orphanedTransactions, err := poller.Store.GetOrphanedTransactionsForBlockHash(orphanedBlock.Hash)
 	if err != nil {
 		return err
 	}
 
-	err = poller.DB.Delete(&models.OrphanedTransaction{}, "orphaned_block_hash = ?", orphanedBlock.Hash).Error
+	err = poller.Store.DeleteOrphanedTransactionsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned withdrawals associated with orphaned
+	// block, save temporarily
+
+	orphanedWithdrawals, err := poller.Store.GetOrphanedWithdrawalsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedWithdrawalsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned uncles associated with orphaned block, save
+	// temporarily
+
+	orphanedUncles, err := poller.Store.GetOrphanedUnclesForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedUnclesForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned internal transactions associated with
+	// orphaned block, save temporarily
+
+	orphanedInternalTransactions, err := poller.Store.GetOrphanedInternalTransactionsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedInternalTransactionsForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	// Delete orphaned balance deltas associated with orphaned
+	// block, save temporarily
+
+	orphanedBalanceDeltas, err := poller.Store.GetOrphanedBalanceDeltasForBlockHash(orphanedBlock.Hash)
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.DeleteOrphanedBalanceDeltasForBlockHash(orphanedBlock.Hash)
 	if err != nil {
 		return err
 	}
 
 	// Delete orphaned block
 
-	err = poller.DB.Delete(orphanedBlock).Error
+	err = poller.Store.DeleteOrphanedBlock(orphanedBlock.Hash)
 	if err != nil {
 		return err
 	}
 
 	// Create model for block
 
-	err = poller.DB.Create(&models.Block{
-		Hash:        orphanedBlock.Hash,
-		Size:        orphanedBlock.Size,
-		ParentHash:  orphanedBlock.ParentHash,
-		UncleHash:   orphanedBlock.UncleHash,
-		Coinbase:    orphanedBlock.Coinbase,
-		Root:        orphanedBlock.Root,
-		TxHash:      orphanedBlock.TxHash,
-		ReceiptHash: orphanedBlock.ReceiptHash,
-		Bloom:       orphanedBlock.Bloom,
-		Difficulty:  orphanedBlock.Difficulty,
-		Number:      orphanedBlock.Number,
-		GasLimit:    orphanedBlock.GasLimit,
-		GasUsed:     orphanedBlock.GasUsed,
-		Time:        orphanedBlock.Time,
-		Extra:       orphanedBlock.Extra,
-		MixDigest:   orphanedBlock.MixDigest,
-		Nonce:       orphanedBlock.Nonce,
-		BaseFee:     orphanedBlock.BaseFee,
-	}).Error
+	err = poller.Store.CreateBlock(&models.Block{
+		Hash:            orphanedBlock.Hash,
+		Size:            orphanedBlock.Size,
+		ParentHash:      orphanedBlock.ParentHash,
+		UncleHash:       orphanedBlock.UncleHash,
+		Coinbase:        orphanedBlock.Coinbase,
+		Root:            orphanedBlock.Root,
+		TxHash:          orphanedBlock.TxHash,
+		ReceiptHash:     orphanedBlock.ReceiptHash,
+		Bloom:           orphanedBlock.Bloom,
+		Difficulty:      orphanedBlock.Difficulty,
+		Number:          orphanedBlock.Number,
+		GasLimit:        orphanedBlock.GasLimit,
+		GasUsed:         orphanedBlock.GasUsed,
+		Time:            orphanedBlock.Time,
+		Extra:           orphanedBlock.Extra,
+		MixDigest:       orphanedBlock.MixDigest,
+		Nonce:           orphanedBlock.Nonce,
+		BaseFee:         orphanedBlock.BaseFee,
+		BlobGasUsed:     orphanedBlock.BlobGasUsed,
+		ExcessBlobGas:   orphanedBlock.ExcessBlobGas,
+		WithdrawalsRoot: orphanedBlock.WithdrawalsRoot,
+		Finality:        models.FinalityUnsafe,
+	})
 	if err != nil {
 		return err
 	}
@@ -550,30 +1200,260 @@ func (poller *Poller) CanonicalizeBlock(orphanedBlock *models.OrphanedBlock) err
 	// Create models for transactions
 
 	for _, orphanedTransaction := range orphanedTransactions {
-		err = poller.DB.Create(&models.Transaction{
-			Hash:      orphanedTransaction.Hash,
-			Size:      orphanedTransaction.Size,
-			From:      orphanedTransaction.From,
-			Type:      orphanedTransaction.Type,
-			ChainID:   orphanedTransaction.ChainID,
-			Data:      orphanedTransaction.Data,
-			Gas:       orphanedTransaction.Gas,
-			GasPrice:  orphanedTransaction.GasPrice,
-			GasTipCap: orphanedTransaction.GasTipCap,
-			GasFeeCap: orphanedTransaction.GasFeeCap,
-			Value:     orphanedTransaction.Value,
-			Nonce:     orphanedTransaction.Nonce,
-			To:        orphanedTransaction.To,
-			BlockHash: orphanedTransaction.OrphanedBlockHash,
-		}).Error
+		accessList := make([]models.AccessTuple, len(orphanedTransaction.AccessList))
+		for i, orphanedAccessTuple := range orphanedTransaction.AccessList {
+			accessList[i] = models.AccessTuple{
+				TransactionHash: orphanedAccessTuple.TransactionHash,
+				TupleIndex:      orphanedAccessTuple.TupleIndex,
+				Address:         orphanedAccessTuple.Address,
+				StorageKeys:     orphanedAccessTuple.StorageKeys,
+				BlockHash:       orphanedAccessTuple.OrphanedBlockHash,
+			}
+		}
+
+		err = poller.Store.CreateTransaction(&models.Transaction{
+			Hash:             orphanedTransaction.Hash,
+			Size:             orphanedTransaction.Size,
+			From:             orphanedTransaction.From,
+			Type:             orphanedTransaction.Type,
+			ChainID:          orphanedTransaction.ChainID,
+			AccessList:       accessList,
+			Data:             orphanedTransaction.Data,
+			Gas:              orphanedTransaction.Gas,
+			GasPrice:         orphanedTransaction.GasPrice,
+			GasTipCap:        orphanedTransaction.GasTipCap,
+			GasFeeCap:        orphanedTransaction.GasFeeCap,
+			BlobGasFeeCap:    orphanedTransaction.BlobGasFeeCap,
+			BlobHashes:       orphanedTransaction.BlobHashes,
+			Value:            orphanedTransaction.Value,
+			Nonce:            orphanedTransaction.Nonce,
+			To:               orphanedTransaction.To,
+			V:                orphanedTransaction.V,
+			R:                orphanedTransaction.R,
+			S:                orphanedTransaction.S,
+			Raw:              orphanedTransaction.Raw,
+			BlockHash:        orphanedTransaction.OrphanedBlockHash,
+			BlockNumber:      orphanedTransaction.BlockNumber,
+			TransactionIndex: orphanedTransaction.TransactionIndex,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for withdrawals
+
+	for _, orphanedWithdrawal := range orphanedWithdrawals {
+		err = poller.Store.CreateWithdrawal(&models.Withdrawal{
+			BlockHash:       orphanedWithdrawal.OrphanedBlockHash,
+			WithdrawalIndex: orphanedWithdrawal.WithdrawalIndex,
+			ValidatorIndex:  orphanedWithdrawal.ValidatorIndex,
+			Address:         orphanedWithdrawal.Address,
+			Amount:          orphanedWithdrawal.Amount,
+			BlockNumber:     orphanedWithdrawal.BlockNumber,
+		})
 		if err != nil {
 			return err
 		}
 	}
 
+	// Create models for uncles
+
+	for _, orphanedUncle := range orphanedUncles {
+		err = poller.Store.CreateUncle(&models.Uncle{
+			BlockHash:   orphanedUncle.OrphanedBlockHash,
+			UncleIndex:  orphanedUncle.UncleIndex,
+			Hash:        orphanedUncle.Hash,
+			ParentHash:  orphanedUncle.ParentHash,
+			UncleHash:   orphanedUncle.UncleHash,
+			Coinbase:    orphanedUncle.Coinbase,
+			Root:        orphanedUncle.Root,
+			TxHash:      orphanedUncle.TxHash,
+			ReceiptHash: orphanedUncle.ReceiptHash,
+			Bloom:       orphanedUncle.Bloom,
+			Difficulty:  orphanedUncle.Difficulty,
+			Number:      orphanedUncle.Number,
+			GasLimit:    orphanedUncle.GasLimit,
+			GasUsed:     orphanedUncle.GasUsed,
+			Time:        orphanedUncle.Time,
+			Extra:       orphanedUncle.Extra,
+			MixDigest:   orphanedUncle.MixDigest,
+			Nonce:       orphanedUncle.Nonce,
+			BaseFee:     orphanedUncle.BaseFee,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for internal transactions, either from the
+	// freshly fetched traces or from the orphaned ones
+
+	err = poller.IndexInternalTransactions(fetchedOrphanedBlock.Traces, orphanedBlock.Hash, orphanedBlock.Number)
+	if err != nil {
+		return err
+	}
+
+	for _, orphanedInternalTransaction := range orphanedInternalTransactions {
+		err = poller.Store.CreateInternalTransaction(&models.InternalTransaction{
+			BlockHash:        orphanedInternalTransaction.OrphanedBlockHash,
+			TraceIndex:       orphanedInternalTransaction.TraceIndex,
+			TransactionHash:  orphanedInternalTransaction.TransactionHash,
+			TransactionIndex: orphanedInternalTransaction.TransactionIndex,
+			TraceAddress:     orphanedInternalTransaction.TraceAddress,
+			Type:             orphanedInternalTransaction.Type,
+			From:             orphanedInternalTransaction.From,
+			To:               orphanedInternalTransaction.To,
+			Value:            orphanedInternalTransaction.Value,
+			Gas:              orphanedInternalTransaction.Gas,
+			GasUsed:          orphanedInternalTransaction.GasUsed,
+			Input:            orphanedInternalTransaction.Input,
+			Output:           orphanedInternalTransaction.Output,
+			Error:            orphanedInternalTransaction.Error,
+			BlockNumber:      orphanedInternalTransaction.BlockNumber,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for balance deltas, either from the freshly
+	// fetched state diffs or from the orphaned ones
+
+	if fetchedOrphanedBlock.StateDiffs != nil {
+		withdrawals := make(types.Withdrawals, len(orphanedWithdrawals))
+		for i, orphanedWithdrawal := range orphanedWithdrawals {
+			withdrawals[i] = &types.Withdrawal{
+				Index:     orphanedWithdrawal.WithdrawalIndex,
+				Validator: orphanedWithdrawal.ValidatorIndex,
+				Address:   common.HexToAddress(orphanedWithdrawal.Address),
+				Amount:    orphanedWithdrawal.Amount,
+			}
+		}
+
+		err = poller.IndexBalanceDeltas(fetchedOrphanedBlock.StateDiffs, withdrawals, orphanedBlock.Hash, orphanedBlock.Number)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, orphanedBalanceDelta := range orphanedBalanceDeltas {
+		err = poller.CreateBalanceDelta(&models.BalanceDelta{
+			Address:         orphanedBalanceDelta.Address,
+			BlockHash:       orphanedBalanceDelta.OrphanedBlockHash,
+			BlockNumber:     orphanedBalanceDelta.BlockNumber,
+			Delta:           orphanedBalanceDelta.Delta,
+			PreviousBalance: orphanedBalanceDelta.PreviousBalance,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create models for receipts and logs, either from the
+	// freshly fetched receipts or from the orphaned ones
+
+	logModels := []*models.Log{}
+	if fetchedOrphanedBlock.Receipts != nil {
+		orphanedTransactionsByHash := make(map[string]models.OrphanedTransaction, len(orphanedTransactions))
+		for _, orphanedTransaction := range orphanedTransactions {
+			orphanedTransactionsByHash[orphanedTransaction.Hash] = orphanedTransaction
+		}
+
+		var baseFee *big.Int
+		if orphanedBlock.BaseFee != nil {
+			baseFee = models.NumericToBigInt(*orphanedBlock.BaseFee)
+		}
+
+		for _, receipt := range fetchedOrphanedBlock.Receipts {
+			orphanedTransaction := orphanedTransactionsByHash[receipt.TxHash.Hex()]
+			receiptModel, err := MakeReceiptModel(
+				receipt,
+				GetEffectiveGasPrice(models.NumericToBigInt(orphanedTransaction.GasTipCap), models.NumericToBigInt(orphanedTransaction.GasFeeCap), baseFee),
+			)
+			if err != nil {
+				return err
+			}
+
+			err = poller.Store.CreateReceipt(receiptModel)
+			if err != nil {
+				return err
+			}
+
+			for _, eventLog := range receipt.Logs {
+				logModel, err := MakeLogModel(eventLog)
+				if err != nil {
+					return err
+				}
+
+				err = poller.Store.CreateLog(logModel)
+				if err != nil {
+					return err
+				}
+
+				logModels = append(logModels, logModel)
+			}
+		}
+	}
+
+	for _, orphanedReceipt := range orphanedReceipts {
+		err = poller.Store.CreateReceipt(&models.Receipt{
+			TransactionHash:   orphanedReceipt.TransactionHash,
+			TransactionIndex:  orphanedReceipt.TransactionIndex,
+			Type:              orphanedReceipt.Type,
+			PostState:         orphanedReceipt.PostState,
+			Status:            orphanedReceipt.Status,
+			CumulativeGasUsed: orphanedReceipt.CumulativeGasUsed,
+			Bloom:             orphanedReceipt.Bloom,
+			GasUsed:           orphanedReceipt.GasUsed,
+			EffectiveGasPrice: orphanedReceipt.EffectiveGasPrice,
+			ContractAddress:   orphanedReceipt.ContractAddress,
+			BlockHash:         orphanedReceipt.OrphanedBlockHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, orphanedLog := range orphanedLogs {
+		logModel := &models.Log{
+			BlockHash:        orphanedLog.OrphanedBlockHash,
+			LogIndex:         orphanedLog.LogIndex,
+			Address:          orphanedLog.Address,
+			Topic0:           orphanedLog.Topic0,
+			Topic1:           orphanedLog.Topic1,
+			Topic2:           orphanedLog.Topic2,
+			Topic3:           orphanedLog.Topic3,
+			Data:             orphanedLog.Data,
+			BlockNumber:      orphanedLog.BlockNumber,
+			TransactionHash:  orphanedLog.TransactionHash,
+			TransactionIndex: orphanedLog.TransactionIndex,
+		}
+
+		err = poller.Store.CreateLog(logModel)
+		if err != nil {
+			return err
+		}
+
+		logModels = append(logModels, logModel)
+	}
+
+	// Recompute token and NFT transfers and balance deltas from
+	// the logs
+
+	err = poller.IndexTokenTransfers(logModels)
+	if err != nil {
+		return err
+	}
+
+	err = poller.IndexNFTTransfers(logModels)
+	if err != nil {
+		return err
+	}
+
 	// Create models for balances
 
-	err = poller.IndexAddressBalancesForBlock(orphanedBlock.Number.Int, orphanedBlock.Hash)
+	err = poller.IndexAddressBalances(fetchedOrphanedBlock.Balances, orphanedBlock.Hash, orphanedBlock.Number)
 	if err != nil {
 		return err
 	}
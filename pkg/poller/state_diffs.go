@@ -0,0 +1,260 @@
+package poller
+
+import (
+	"errors"
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/jackc/pgtype"
+)
+
+// State of an account as reported by the node's prestateTracer. Only
+// balances are used
+type AccountState struct {
+	Balance *hexutil.Big `json:"balance,omitempty"`
+}
+
+// Accounts touched by a transaction as reported by the prestateTracer
+// in diff mode: their state before the transaction, and the fields
+// that it changed. Accounts that the transaction deleted (i.e. that
+// self-destructed) are only in Pre
+type StateDiff struct {
+	Pre  map[common.Address]*AccountState `json:"pre"`
+	Post map[common.Address]*AccountState `json:"post"`
+}
+
+// Result of tracing the state changes of one of the transactions of a
+// block
+type TransactionStateDiff struct {
+	// Not returned by older nodes, in which case it's set from the
+	// block's transactions
+	TxHash common.Hash `json:"txHash"`
+	Result *StateDiff  `json:"result"`
+	// Set if the transaction couldn't be traced
+	Error string `json:"error,omitempty"`
+}
+
+// Traces the state changes made by the given transactions of the block
+// with debug_traceBlockByHash. State diffs come back in transaction
+// order
+func (poller *Poller) FetchStateDiffs(blockHash common.Hash, transactionHashes []common.Hash) ([]*TransactionStateDiff, error) {
+	stateDiffs := []*TransactionStateDiff{}
+	if len(transactionHashes) == 0 {
+		return stateDiffs, nil
+	}
+
+	err := poller.RPCClient.CallContext(
+		poller.Context,
+		&stateDiffs,
+		"debug_traceBlockByHash",
+		blockHash,
+		map[string]interface{}{
+			"tracer":       "prestateTracer",
+			"tracerConfig": map[string]interface{}{"diffMode": true},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stateDiffs) != len(transactionHashes) {
+		return nil, fmt.Errorf("Got %d state diffs for the %d transactions of block %s", len(stateDiffs), len(transactionHashes), blockHash.Hex())
+	}
+
+	for i, stateDiff := range stateDiffs {
+		if stateDiff.Error != "" {
+			return nil, fmt.Errorf("Could not trace transaction %s: %s", transactionHashes[i].Hex(), stateDiff.Error)
+		}
+
+		if stateDiff.TxHash == (common.Hash{}) {
+			stateDiff.TxHash = transactionHashes[i]
+		}
+
+		if stateDiff.TxHash != transactionHashes[i] || stateDiff.Result == nil {
+			return nil, fmt.Errorf("State diff of transaction %s is not from block %s", transactionHashes[i].Hex(), blockHash.Hex())
+		}
+	}
+
+	return stateDiffs, nil
+}
+
+func accountBalance(accountState *AccountState) *big.Int {
+	if accountState == nil || accountState.Balance == nil {
+		return new(big.Int)
+	}
+
+	return accountState.Balance.ToInt()
+}
+
+// Adds up the balance changes of the block's transactions and
+// withdrawals, by address. Block and uncle rewards aren't part of the
+// transactions' traces, so miners' deltas only include fees
+func MakeBalanceDeltaModels(stateDiffs []*TransactionStateDiff, withdrawals types.Withdrawals, blockHash string, blockNumber pgtype.Numeric) ([]*models.BalanceDelta, error) {
+	deltas := make(map[common.Address]*big.Int)
+	previousBalances := make(map[common.Address]*big.Int)
+	addresses := []common.Address{}
+
+	addDelta := func(address common.Address, delta *big.Int) {
+		if deltas[address] == nil {
+			deltas[address] = new(big.Int)
+			addresses = append(addresses, address)
+		}
+
+		deltas[address].Add(deltas[address], delta)
+	}
+
+	for _, stateDiff := range stateDiffs {
+		touchedAddresses := make(map[common.Address]bool)
+		for address := range stateDiff.Result.Pre {
+			touchedAddresses[address] = true
+		}
+
+		for address := range stateDiff.Result.Post {
+			touchedAddresses[address] = true
+		}
+
+		for address := range touchedAddresses {
+			preBalance := accountBalance(stateDiff.Result.Pre[address])
+
+			// Accounts missing from Post were deleted, and
+			// accounts in Post without a balance kept theirs
+			postBalance := new(big.Int)
+			if postState, ok := stateDiff.Result.Post[address]; ok {
+				postBalance = preBalance
+				if postState != nil && postState.Balance != nil {
+					postBalance = postState.Balance.ToInt()
+				}
+			}
+
+			// The first transaction touching the address
+			// gives its balance before the block, as the
+			// transactions before it left it as is
+			if _, ok := previousBalances[address]; !ok {
+				previousBalances[address] = preBalance
+			}
+
+			addDelta(address, new(big.Int).Sub(postBalance, preBalance))
+		}
+	}
+
+	// Withdrawals are processed after the transactions
+	for _, withdrawal := range withdrawals {
+		amount := new(big.Int).Mul(new(big.Int).SetUint64(withdrawal.Amount), big.NewInt(params.GWei))
+		addDelta(withdrawal.Address, amount)
+	}
+
+	balanceDeltaModels := make([]*models.BalanceDelta, 0, len(addresses))
+	for _, address := range addresses {
+		balanceDeltaDelta := new(pgtype.Numeric)
+		err := balanceDeltaDelta.Set(deltas[address].String())
+		if err != nil {
+			return nil, err
+		}
+
+		var balanceDeltaPreviousBalance *pgtype.Numeric
+		if previousBalance, ok := previousBalances[address]; ok {
+			balanceDeltaPreviousBalance = new(pgtype.Numeric)
+			err = balanceDeltaPreviousBalance.Set(previousBalance.String())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		balanceDeltaModels = append(balanceDeltaModels, &models.BalanceDelta{
+			Address:         address.Hex(),
+			BlockHash:       blockHash,
+			BlockNumber:     blockNumber,
+			Delta:           *balanceDeltaDelta,
+			PreviousBalance: balanceDeltaPreviousBalance,
+		})
+	}
+
+	return balanceDeltaModels, nil
+}
+
+// Indexes the balance deltas of a canonical block from the state diffs
+// of its transactions and from its withdrawals
+func (poller *Poller) IndexBalanceDeltas(stateDiffs []*TransactionStateDiff, withdrawals types.Withdrawals, blockHash string, blockNumber pgtype.Numeric) error {
+	balanceDeltaModels, err := MakeBalanceDeltaModels(stateDiffs, withdrawals, blockHash, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	for _, balanceDeltaModel := range balanceDeltaModels {
+		err = poller.CreateBalanceDelta(balanceDeltaModel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Creates a canonical balance delta along with its running balance.
+// Blocks aren't always canonicalized in order (a reorg canonicalizes
+// its blocks from the new head down, and backfills index blocks below
+// the head), so the balances of the address's later deltas that only
+// add up from this one are shifted as well
+func (poller *Poller) CreateBalanceDelta(balanceDelta *models.BalanceDelta) error {
+	previousBalance := new(big.Int)
+	previousBalanceDelta, err := poller.Store.GetLatestAddressBalanceDelta(balanceDelta.Address, balanceDelta.BlockNumber)
+	if err == nil {
+		previousBalance = models.NumericToBigInt(previousBalanceDelta.Balance)
+	} else if !errors.Is(err, models.ErrRecordNotFound) {
+		return err
+	}
+
+	// Later deltas were added up from previousBalance so far
+	shift := new(big.Int).Neg(previousBalance)
+
+	if balanceDelta.PreviousBalance != nil {
+		previousBalance = models.NumericToBigInt(*balanceDelta.PreviousBalance)
+	}
+
+	balance := new(big.Int).Add(previousBalance, models.NumericToBigInt(balanceDelta.Delta))
+	err = balanceDelta.Balance.Set(balance.String())
+	if err != nil {
+		return err
+	}
+
+	err = poller.Store.CreateBalanceDelta(balanceDelta)
+	if err != nil {
+		return err
+	}
+
+	shift.Add(shift, balance)
+	if shift.Sign() == 0 {
+		return nil
+	}
+
+	laterBalanceDeltas, err := poller.Store.GetLaterAddressBalanceDeltas(balanceDelta.Address, balanceDelta.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	for _, laterBalanceDelta := range laterBalanceDeltas {
+		// Deltas with a previous balance don't depend on the
+		// ones before them
+		if laterBalanceDelta.PreviousBalance != nil {
+			break
+		}
+
+		laterBalance := models.NumericToBigInt(laterBalanceDelta.Balance)
+		err = laterBalanceDelta.Balance.Set(laterBalance.Add(laterBalance, shift).String())
+		if err != nil {
+			return err
+		}
+
+		err = poller.Store.SaveBalanceDelta(&laterBalanceDelta)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package poller
+
+import (
+	"getherscan/pkg/models"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/jackc/pgtype"
+)
+
+// Topic of Transfer(address,address,uint256) events, which ERC-20
+// and ERC-721 tokens share
+var TransferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")).Hex()
+
+var zeroAddress = common.Address{}.Hex()
+
+// Decodes an ERC-20 Transfer event, returns nil if the log isn't one.
+// ERC-721 Transfer events have the same topic, but index the token ID
+// as a 4th topic instead of holding the value in their data
+func MakeTokenTransferModel(logModel *models.Log) (*models.TokenTransfer, error) {
+	if logModel.Topic0 != TransferEventTopic || logModel.Topic2 == "" || logModel.Topic3 != "" || len(logModel.Data) != 32 {
+		return nil, nil
+	}
+
+	tokenTransferValue := new(pgtype.Numeric)
+	err := tokenTransferValue.Set(new(big.Int).SetBytes(logModel.Data).String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenTransfer{
+		BlockHash:       logModel.BlockHash,
+		LogIndex:        logModel.LogIndex,
+		Token:           logModel.Address,
+		From:            common.HexToAddress(logModel.Topic1).Hex(),
+		To:              common.HexToAddress(logModel.Topic2).Hex(),
+		Value:           *tokenTransferValue,
+		BlockNumber:     logModel.BlockNumber,
+		TransactionHash: logModel.TransactionHash,
+	}, nil
+}
+
+type tokenHolder struct {
+	token  string
+	holder string
+}
+
+// Indexes the ERC-20 transfers among the logs of a canonical block,
+// along with the balance changes they add up to
+func (poller *Poller) IndexTokenTransfers(logModels []*models.Log) error {
+	if len(logModels) == 0 {
+		return nil
+	}
+
+	deltas := make(map[tokenHolder]*big.Int)
+	addDelta := func(token, holder string, delta *big.Int) {
+		// Mints and burns only change the other party's
+		// balance
+		if holder == zeroAddress {
+			return
+		}
+
+		key := tokenHolder{token, holder}
+		if deltas[key] == nil {
+			deltas[key] = new(big.Int)
+		}
+
+		deltas[key].Add(deltas[key], delta)
+	}
+
+	for _, logModel := range logModels {
+		tokenTransferModel, err := MakeTokenTransferModel(logModel)
+		if err != nil {
+			return err
+		}
+
+		if tokenTransferModel == nil {
+			continue
+		}
+
+		err = poller.Store.CreateTokenTransfer(tokenTransferModel)
+		if err != nil {
+			return err
+		}
+
+		value := new(big.Int).SetBytes(logModel.Data)
+		addDelta(tokenTransferModel.Token, tokenTransferModel.From, new(big.Int).Neg(value))
+		addDelta(tokenTransferModel.Token, tokenTransferModel.To, value)
+	}
+
+	for key, delta := range deltas {
+		// e.g. transfers to self
+		if delta.Sign() == 0 {
+			continue
+		}
+
+		tokenBalanceDelta := new(pgtype.Numeric)
+		err := tokenBalanceDelta.Set(delta.String())
+		if err != nil {
+			return err
+		}
+
+		err = poller.Store.CreateTokenBalanceDelta(&models.TokenBalanceDelta{
+			Token:       key.token,
+			Holder:      key.holder,
+			BlockHash:   logModels[0].BlockHash,
+			BlockNumber: logModels[0].BlockNumber,
+			Delta:       *tokenBalanceDelta,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package poller
+
+import (
+	"fmt"
+	"getherscan/pkg/models"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/jackc/pgtype"
+)
+
+// A call as reported by the node's callTracer, along with the calls it
+// made in turn
+type CallFrame struct {
+	Type    string          `json:"type"`
+	From    common.Address  `json:"from"`
+	To      *common.Address `json:"to,omitempty"`
+	Value   *hexutil.Big    `json:"value,omitempty"`
+	Gas     hexutil.Uint64  `json:"gas"`
+	GasUsed hexutil.Uint64  `json:"gasUsed"`
+	Input   hexutil.Bytes   `json:"input"`
+	Output  hexutil.Bytes   `json:"output,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Calls   []*CallFrame    `json:"calls,omitempty"`
+}
+
+// Result of tracing one of the transactions of a block
+type TransactionTrace struct {
+	// Not returned by older nodes, in which case it's set from the
+	// block's transactions
+	TxHash common.Hash `json:"txHash"`
+	Result *CallFrame  `json:"result"`
+	// Set if the transaction couldn't be traced
+	Error string `json:"error,omitempty"`
+}
+
+// Traces the calls made by the given transactions of the block with
+// debug_traceBlockByHash. Traces come back in transaction order
+func (poller *Poller) FetchTraces(blockHash common.Hash, transactionHashes []common.Hash) ([]*TransactionTrace, error) {
+	traces := []*TransactionTrace{}
+	if len(transactionHashes) == 0 {
+		return traces, nil
+	}
+
+	err := poller.RPCClient.CallContext(
+		poller.Context,
+		&traces,
+		"debug_traceBlockByHash",
+		blockHash,
+		map[string]interface{}{"tracer": "callTracer"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(traces) != len(transactionHashes) {
+		return nil, fmt.Errorf("Got %d traces for the %d transactions of block %s", len(traces), len(transactionHashes), blockHash.Hex())
+	}
+
+	for i, trace := range traces {
+		if trace.Error != "" {
+			return nil, fmt.Errorf("Could not trace transaction %s: %s", transactionHashes[i].Hex(), trace.Error)
+		}
+
+		if trace.TxHash == (common.Hash{}) {
+			trace.TxHash = transactionHashes[i]
+		}
+
+		if trace.TxHash != transactionHashes[i] || trace.Result == nil {
+			return nil, fmt.Errorf("Trace of transaction %s is not from block %s", transactionHashes[i].Hex(), blockHash.Hex())
+		}
+	}
+
+	return traces, nil
+}
+
+// Flattens the call trees of a block's transactions into internal
+// transactions, in execution order. The top-level call of each
+// transaction is the transaction itself, so it's left out
+func MakeInternalTransactionModels(traces []*TransactionTrace, blockHash string, blockNumber pgtype.Numeric) ([]*models.InternalTransaction, error) {
+	internalTransactionModels := []*models.InternalTransaction{}
+
+	var addCalls func(calls []*CallFrame, traceAddress []string, transactionIndex int, transactionHash string) error
+	addCalls = func(calls []*CallFrame, traceAddress []string, transactionIndex int, transactionHash string) error {
+		for i, call := range calls {
+			callTraceAddress := append(traceAddress[:len(traceAddress):len(traceAddress)], strconv.Itoa(i))
+
+			// Delegate and static calls don't move value
+			callValue := big.NewInt(0)
+			if call.Value != nil {
+				callValue = call.Value.ToInt()
+			}
+
+			internalTransactionValue := new(pgtype.Numeric)
+			err := internalTransactionValue.Set(callValue.String())
+			if err != nil {
+				return err
+			}
+
+			internalTransactionTo := ""
+			if call.To != nil {
+				internalTransactionTo = call.To.Hex()
+			}
+
+			internalTransactionModels = append(internalTransactionModels, &models.InternalTransaction{
+				BlockHash:        blockHash,
+				TraceIndex:       uint(len(internalTransactionModels)),
+				TransactionHash:  transactionHash,
+				TransactionIndex: uint(transactionIndex),
+				TraceAddress:     strings.Join(callTraceAddress, "."),
+				Type:             call.Type,
+				From:             call.From.Hex(),
+				To:               internalTransactionTo,
+				Value:            *internalTransactionValue,
+				Gas:              uint64(call.Gas),
+				GasUsed:          uint64(call.GasUsed),
+				Input:            call.Input,
+				Output:           call.Output,
+				Error:            call.Error,
+				BlockNumber:      blockNumber,
+			})
+
+			err = addCalls(call.Calls, callTraceAddress, transactionIndex, transactionHash)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for i, trace := range traces {
+		err := addCalls(trace.Result.Calls, []string{}, i, trace.TxHash.Hex())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return internalTransactionModels, nil
+}
+
+// Indexes the internal transactions of a canonical block from its
+// traces
+func (poller *Poller) IndexInternalTransactions(traces []*TransactionTrace, blockHash string, blockNumber pgtype.Numeric) error {
+	internalTransactionModels, err := MakeInternalTransactionModels(traces, blockHash, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	for _, internalTransactionModel := range internalTransactionModels {
+		err = poller.Store.CreateInternalTransaction(internalTransactionModel)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
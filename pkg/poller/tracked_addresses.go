@@ -0,0 +1,269 @@
+package poller
+
+import (
+	"errors"
+	"getherscan/pkg/models"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/jackc/pgtype"
+)
+
+const DefaultTrackedAddressesPollInterval = 10 * time.Second
+
+// Nodes without archival state keep the state of the latest 128 blocks
+const DefaultTrackedAddressesBackfillDepth = 128
+
+// Stores the given addresses (e.g. from a tracked addresses file) as
+// tracked, if they aren't already. Addresses removed through the API
+// server stay removed. They're only tracked from the next indexed
+// block on, their past balances aren't backfilled
+func (poller *Poller) SeedTrackedAddresses(addresses []string) error {
+	for _, address := range addresses {
+		address = common.HexToAddress(address).Hex()
+
+		_, err := poller.Store.GetTrackedAddress(address)
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, models.ErrRecordNotFound) {
+			return err
+		}
+
+		err = poller.Store.CreateTrackedAddress(&models.TrackedAddress{Address: address})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reloads the tracked addresses from the store, so that addresses
+// added or removed since (e.g. through the API server) are picked up
+// by the next indexed block
+func (poller *Poller) LoadTrackedAddresses() ([]models.TrackedAddress, error) {
+	trackedAddresses, err := poller.Store.GetTrackedAddresses()
+	if err != nil {
+		return nil, err
+	}
+
+	isTracked := make(map[string]bool, len(poller.TrackedAddresses))
+	for _, address := range poller.TrackedAddresses {
+		isTracked[address] = true
+	}
+
+	// Replace rather than modify the slice, fetch workers may
+	// still hold the previous one
+	addresses := make([]string, len(trackedAddresses))
+	for i, trackedAddress := range trackedAddresses {
+		addresses[i] = trackedAddress.Address
+
+		if !isTracked[trackedAddress.Address] {
+			log.Printf("Tracking address %s\n", trackedAddress.Address)
+		}
+
+		delete(isTracked, trackedAddress.Address)
+	}
+
+	for address := range isTracked {
+		log.Printf("No longer tracking address %s\n", address)
+	}
+
+	poller.TrackedAddresses = addresses
+
+	return trackedAddresses, nil
+}
+
+// Whether any of the tracked addresses still has its balances to
+// backfill
+func IsBackfillPending(trackedAddresses []models.TrackedAddress) bool {
+	for _, trackedAddress := range trackedAddresses {
+		if trackedAddress.BackfillPending {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backfills the balances of the tracked addresses that were added with
+// a backfill, one address at a time, until they're all done
+func (poller *Poller) BackfillTrackedAddresses() error {
+	for {
+		pending, err := poller.BackfillTrackedAddressesStep()
+		if err != nil {
+			return err
+		}
+
+		if !pending {
+			return nil
+		}
+	}
+}
+
+// Backfills the balance of the first tracked address whose backfill is
+// pending at its next block, and returns whether any backfill is still
+// pending. As a backfill makes a request to the node for every block,
+// the poller runs one step at a time in between new heads, so that
+// backfills don't hold up indexing
+func (poller *Poller) BackfillTrackedAddressesStep() (bool, error) {
+	// Re-read the addresses every step, as they may have been
+	// removed (or added again) through the API server since
+	trackedAddresses, err := poller.Store.GetTrackedAddresses()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range trackedAddresses {
+		if !trackedAddresses[i].BackfillPending {
+			continue
+		}
+
+		return true, poller.BackfillAddressBalance(&trackedAddresses[i])
+	}
+
+	return false, nil
+}
+
+// Fetches the balance of the given tracked address at the next indexed
+// canonical block of its backfill, if it doesn't have one. Backfills
+// walk down from the head at the time they started, to at most
+// TrackedAddressesBackfillDepth blocks below it. Progress is saved
+// after every block, so that an interrupted or failed backfill resumes
+// where it left off. Nodes without archival state can only answer for
+// recent blocks, so the backfill also stops at the first block the
+// node doesn't have the state of
+func (poller *Poller) BackfillAddressBalance(trackedAddress *models.TrackedAddress) error {
+	if trackedAddress.BackfillNextBlock == nil {
+		head, err := poller.Store.GetHead()
+		if errors.Is(err, models.ErrRecordNotFound) {
+			// Nothing was indexed before the address was
+			// tracked
+			trackedAddress.BackfillPending = false
+			return poller.Store.SaveTrackedAddressBackfill(trackedAddress)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		headNumber := models.NumericToBigInt(head.Number).Uint64()
+		trackedAddress.BackfillNextBlock = &headNumber
+
+		trackedAddress.BackfillFromBlock = 0
+		if headNumber > poller.TrackedAddressesBackfillDepth {
+			trackedAddress.BackfillFromBlock = headNumber - poller.TrackedAddressesBackfillDepth
+		}
+	}
+
+	blockNumber := *trackedAddress.BackfillNextBlock
+
+	block, balance, err := poller.fetchBackfillBalance(trackedAddress.Address, blockNumber)
+	available := err == nil
+	if err != nil {
+		// Other errors (e.g. timeouts) are retried on the next
+		// step
+		if !IsStateUnavailableError(err) {
+			return err
+		}
+
+		log.Printf("Stopped backfilling balances of %s at block %d: %s\n", trackedAddress.Address, blockNumber, err)
+	}
+
+	// The backfill is done once it reaches its lowest block, or a
+	// block the node doesn't have the state of
+	done := !available || blockNumber <= trackedAddress.BackfillFromBlock
+
+	err = poller.Atomically(func(txPoller *Poller) error {
+		if balance != nil {
+			// The block may have been orphaned since its
+			// balance was fetched, in which case the block
+			// now canonical at its number is backfilled
+			// instead
+			_, err := txPoller.Store.GetBlockByHash(block.Hash)
+			if errors.Is(err, models.ErrRecordNotFound) {
+				done = false
+				return txPoller.Store.SaveTrackedAddressBackfill(trackedAddress)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			err = txPoller.IndexAddressBalances(map[string]*big.Int{trackedAddress.Address: balance}, block.Hash, block.Number)
+			if err != nil {
+				return err
+			}
+		}
+
+		if done {
+			trackedAddress.BackfillPending = false
+		} else {
+			nextBlock := blockNumber - 1
+			trackedAddress.BackfillNextBlock = &nextBlock
+		}
+
+		return txPoller.Store.SaveTrackedAddressBackfill(trackedAddress)
+	})
+	if err != nil {
+		return err
+	}
+
+	if done {
+		log.Printf("Backfilled balances of %s\n", trackedAddress.Address)
+	}
+
+	return nil
+}
+
+// Fetches the balance of the given address at the canonical block with
+// blockNumber, by the block's hash so that the balance is the block's
+// even if it's replaced in the meantime. Returns a nil balance if no
+// block with that number is indexed, or its balance already is
+func (poller *Poller) fetchBackfillBalance(address string, blockNumber uint64) (*models.Block, *big.Int, error) {
+	blockNumberNumeric := new(pgtype.Numeric)
+	err := blockNumberNumeric.Set(blockNumber)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := poller.Store.GetBlockByNumber(*blockNumberNumeric)
+	if errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = poller.Store.GetAddressBalanceByBlockHash(address, block.Hash)
+	if err == nil {
+		return nil, nil, nil
+	}
+
+	if !errors.Is(err, models.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	balance, err := poller.EthClient.BalanceAtHash(poller.Context, common.HexToAddress(address), common.HexToHash(block.Hash))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, balance, nil
+}
+
+// Whether err is a node's answer that it doesn't have the state of the
+// requested block, e.g. as it has pruned it
+func IsStateUnavailableError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "missing trie node") ||
+		strings.Contains(message, "state unavailable") ||
+		strings.Contains(message, "state is not available") ||
+		strings.Contains(message, "state not available")
+}
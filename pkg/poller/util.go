@@ -3,6 +3,7 @@ package poller
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"getherscan/pkg/models"
 	"math/big"
 	"os"
@@ -11,7 +12,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/jackc/pgtype"
-	"gorm.io/gorm"
 )
 
 func MakeBlockModel(block *types.Block) (*models.Block, error) {
@@ -33,38 +33,52 @@ func MakeBlockModel(block *types.Block) (*models.Block, error) {
 		return nil, err
 	}
 
-	blockBaseFee := new(pgtype.Numeric)
-	err = blockBaseFee.Set(block.BaseFee().String())
-	if err != nil {
-		return nil, err
+	// Null unless the block is post-London
+	var blockBaseFee *pgtype.Numeric
+	if block.BaseFee() != nil {
+		blockBaseFee = new(pgtype.Numeric)
+		err = blockBaseFee.Set(block.BaseFee().String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only set since Shanghai
+	blockWithdrawalsRoot := ""
+	if block.Header().WithdrawalsHash != nil {
+		blockWithdrawalsRoot = block.Header().WithdrawalsHash.Hex()
 	}
 
 	return &models.Block{
-		Hash:        block.Hash().Hex(),
-		Size:        uint64(block.Size()),
-		ParentHash:  block.ParentHash().Hex(),
-		UncleHash:   block.UncleHash().Hex(),
-		Coinbase:    block.Coinbase().Hex(),
-		Root:        block.Root().Hex(),
-		TxHash:      block.TxHash().Hex(),
-		ReceiptHash: block.ReceiptHash().Hex(),
-		Bloom:       block.Bloom().Bytes(),
-		Difficulty:  *blockDifficulty,
-		Number:      *blockNumber,
-		GasLimit:    block.GasLimit(),
-		GasUsed:     block.GasUsed(),
-		Time:        block.Time(),
-		Extra:       block.Extra(),
-		MixDigest:   block.MixDigest().Hex(),
-		Nonce:       *blockNonce,
-		BaseFee:     *blockBaseFee,
+		Hash:            block.Hash().Hex(),
+		Size:            uint64(block.Size()),
+		ParentHash:      block.ParentHash().Hex(),
+		UncleHash:       block.UncleHash().Hex(),
+		Coinbase:        block.Coinbase().Hex(),
+		Root:            block.Root().Hex(),
+		TxHash:          block.TxHash().Hex(),
+		ReceiptHash:     block.ReceiptHash().Hex(),
+		Bloom:           block.Bloom().Bytes(),
+		Difficulty:      *blockDifficulty,
+		Number:          *blockNumber,
+		GasLimit:        block.GasLimit(),
+		GasUsed:         block.GasUsed(),
+		Time:            block.Time(),
+		Extra:           block.Extra(),
+		MixDigest:       block.MixDigest().Hex(),
+		Nonce:           *blockNonce,
+		BaseFee:         blockBaseFee,
+		BlobGasUsed:     block.BlobGasUsed(),
+		ExcessBlobGas:   block.ExcessBlobGas(),
+		WithdrawalsRoot: blockWithdrawalsRoot,
+		Finality:        models.FinalityUnsafe,
 	}, nil
 }
 
-func MakeTransactionModel(transaction *types.Transaction, blockHash string) (*models.Transaction, error) {
-	message, err := transaction.AsMessage(
+func MakeTransactionModel(transaction *types.Transaction, transactionIndex uint, block *types.Block) (*models.Transaction, error) {
+	sender, err := types.Sender(
 		types.LatestSignerForChainID(transaction.ChainId()),
-		nil,
+		transaction,
 	)
 	if err != nil {
 		return nil, err
@@ -94,6 +108,15 @@ func MakeTransactionModel(transaction *types.Transaction, blockHash string) (*mo
 		return nil, err
 	}
 
+	var transactionBlobGasFeeCap *pgtype.Numeric
+	if transaction.BlobGasFeeCap() != nil {
+		transactionBlobGasFeeCap = new(pgtype.Numeric)
+		err = transactionBlobGasFeeCap.Set(transaction.BlobGasFeeCap().String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	transactionValue := new(pgtype.Numeric)
 	err = transactionValue.Set(transaction.Value().String())
 	if err != nil {
@@ -106,31 +129,98 @@ func MakeTransactionModel(transaction *types.Transaction, blockHash string) (*mo
 		return nil, err
 	}
 
+	transactionBlockNumber := new(pgtype.Numeric)
+	err = transactionBlockNumber.Set(block.Number().String())
+	if err != nil {
+		return nil, err
+	}
+
 	transactionTo := ""
 	transactionToAddress := transaction.To()
 	if transactionToAddress != nil {
 		transactionTo = transactionToAddress.Hex()
 	}
 
+	v, r, s := transaction.RawSignatureValues()
+
+	transactionV := new(pgtype.Numeric)
+	err = transactionV.Set(v.String())
+	if err != nil {
+		return nil, err
+	}
+
+	transactionR := new(pgtype.Numeric)
+	err = transactionR.Set(r.String())
+	if err != nil {
+		return nil, err
+	}
+
+	transactionS := new(pgtype.Numeric)
+	err = transactionS.Set(s.String())
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.Transaction{
-		Hash:      transaction.Hash().Hex(),
-		Size:      uint64(transaction.Size()),
-		From:      message.From().Hex(),
-		Type:      byte(transaction.Type()),
-		ChainID:   *transactionChainID,
-		Data:      transaction.Data(),
-		Gas:       transaction.Gas(),
-		GasPrice:  *transactionGasPrice,
-		GasTipCap: *transactionGasTipCap,
-		GasFeeCap: *transactionGasFeeCap,
-		Value:     *transactionValue,
-		Nonce:     *transactionNonce,
-		To:        transactionTo,
-		BlockHash: blockHash,
+		Hash:             transaction.Hash().Hex(),
+		Size:             uint64(transaction.Size()),
+		From:             sender.Hex(),
+		Type:             byte(transaction.Type()),
+		ChainID:          *transactionChainID,
+		AccessList:       MakeAccessTupleModels(transaction, block.Hash().Hex()),
+		Data:             transaction.Data(),
+		Gas:              transaction.Gas(),
+		GasPrice:         *transactionGasPrice,
+		GasTipCap:        *transactionGasTipCap,
+		GasFeeCap:        *transactionGasFeeCap,
+		BlobGasFeeCap:    transactionBlobGasFeeCap,
+		BlobHashes:       MakeHashes(transaction.BlobHashes()),
+		Value:            *transactionValue,
+		Nonce:            *transactionNonce,
+		To:               transactionTo,
+		V:                *transactionV,
+		R:                *transactionR,
+		S:                *transactionS,
+		BlockHash:        block.Hash().Hex(),
+		BlockNumber:      *transactionBlockNumber,
+		TransactionIndex: transactionIndex,
 	}, nil
 }
 
-func MakeBalanceModel(balanceBigInt *big.Int, address, blockHash string) (*models.Balance, error) {
+func MakeAccessTupleModels(transaction *types.Transaction, blockHash string) []models.AccessTuple {
+	accessTupleModels := []models.AccessTuple{}
+	for i, accessTuple := range transaction.AccessList() {
+		accessTupleModels = append(accessTupleModels, models.AccessTuple{
+			TransactionHash: transaction.Hash().Hex(),
+			TupleIndex:      uint(i),
+			Address:         accessTuple.Address.Hex(),
+			StorageKeys:     MakeStorageKeys(accessTuple.StorageKeys),
+			BlockHash:       blockHash,
+		})
+	}
+
+	return accessTupleModels
+}
+
+func MakeStorageKeys(storageKeys []common.Hash) models.StorageKeys {
+	hexStorageKeys := make(models.StorageKeys, len(storageKeys))
+	for i, storageKey := range storageKeys {
+		hexStorageKeys[i] = storageKey.Hex()
+	}
+
+	return hexStorageKeys
+}
+
+func MakeHashes(hashes []common.Hash) models.Hashes {
+	hexHashes := make(models.Hashes, len(hashes))
+	for i, hash := range hashes {
+		hexHashes[i] = hash.Hex()
+	}
+
+	return hexHashes
+}
+
+func MakeBalanceModel(balanceBigInt *big.Int, address, blockHash string, blockNumber pgtype.Numeric) (*models.Balance, error) {
 	balance := new(pgtype.Numeric)
 	err := balance.Set(balanceBigInt.String())
 	if err != nil {
@@ -138,9 +228,153 @@ func MakeBalanceModel(balanceBigInt *big.Int, address, blockHash string) (*model
 	}
 
 	return &models.Balance{
-		Address:   address,
-		BlockHash: blockHash,
-		Balance:   *balance,
+		Address:     address,
+		BlockHash:   blockHash,
+		BlockNumber: blockNumber,
+		Balance:     *balance,
+	}, nil
+}
+
+// Price per unit of gas actually paid by a transaction with the given
+// fee caps, in a block with the given base fee. Legacy transactions
+// have both caps set to their gas price
+func GetEffectiveGasPrice(gasTipCap, gasFeeCap, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return gasFeeCap
+	}
+
+	gasTip := new(big.Int).Sub(gasFeeCap, baseFee)
+	if gasTip.Cmp(gasTipCap) > 0 {
+		gasTip = gasTipCap
+	}
+
+	return new(big.Int).Add(baseFee, gasTip)
+}
+
+func MakeReceiptModel(receipt *types.Receipt, effectiveGasPrice *big.Int) (*models.Receipt, error) {
+	receiptEffectiveGasPrice := new(pgtype.Numeric)
+	err := receiptEffectiveGasPrice.Set(effectiveGasPrice.String())
+	if err != nil {
+		return nil, err
+	}
+
+	receiptContractAddress := ""
+	if receipt.ContractAddress != (common.Address{}) {
+		receiptContractAddress = receipt.ContractAddress.Hex()
+	}
+
+	return &models.Receipt{
+		TransactionHash:   receipt.TxHash.Hex(),
+		TransactionIndex:  receipt.TransactionIndex,
+		Type:              receipt.Type,
+		PostState:         receipt.PostState,
+		Status:            receipt.Status,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		Bloom:             receipt.Bloom.Bytes(),
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: *receiptEffectiveGasPrice,
+		ContractAddress:   receiptContractAddress,
+		BlockHash:         receipt.BlockHash.Hex(),
+	}, nil
+}
+
+func MakeLogModel(eventLog *types.Log) (*models.Log, error) {
+	logBlockNumber := new(pgtype.Numeric)
+	err := logBlockNumber.Set(eventLog.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	logTopics := make([]string, 4)
+	for i, topic := range eventLog.Topics {
+		if i >= len(logTopics) {
+			return nil, fmt.Errorf("Log %d in block %s has too many topics", eventLog.Index, eventLog.BlockHash.Hex())
+		}
+
+		logTopics[i] = topic.Hex()
+	}
+
+	return &models.Log{
+		BlockHash:        eventLog.BlockHash.Hex(),
+		LogIndex:         eventLog.Index,
+		Address:          eventLog.Address.Hex(),
+		Topic0:           logTopics[0],
+		Topic1:           logTopics[1],
+		Topic2:           logTopics[2],
+		Topic3:           logTopics[3],
+		Data:             eventLog.Data,
+		BlockNumber:      *logBlockNumber,
+		TransactionHash:  eventLog.TxHash.Hex(),
+		TransactionIndex: eventLog.TxIndex,
+	}, nil
+}
+
+func MakeWithdrawalModel(withdrawal *types.Withdrawal, block *types.Block) (*models.Withdrawal, error) {
+	withdrawalBlockNumber := new(pgtype.Numeric)
+	err := withdrawalBlockNumber.Set(block.Number().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Withdrawal{
+		BlockHash:       block.Hash().Hex(),
+		WithdrawalIndex: withdrawal.Index,
+		ValidatorIndex:  withdrawal.Validator,
+		Address:         withdrawal.Address.Hex(),
+		Amount:          withdrawal.Amount,
+		BlockNumber:     *withdrawalBlockNumber,
+	}, nil
+}
+
+func MakeUncleModel(uncle *types.Header, uncleIndex uint, block *types.Block) (*models.Uncle, error) {
+	uncleDifficulty := new(pgtype.Numeric)
+	err := uncleDifficulty.Set(uncle.Difficulty.String())
+	if err != nil {
+		return nil, err
+	}
+
+	uncleNumber := new(pgtype.Numeric)
+	err = uncleNumber.Set(uncle.Number.String())
+	if err != nil {
+		return nil, err
+	}
+
+	uncleNonce := new(pgtype.Numeric)
+	err = uncleNonce.Set(uncle.Nonce.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	// Null unless the uncle is post-London
+	var uncleBaseFee *pgtype.Numeric
+	if uncle.BaseFee != nil {
+		uncleBaseFee = new(pgtype.Numeric)
+		err = uncleBaseFee.Set(uncle.BaseFee.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.Uncle{
+		BlockHash:   block.Hash().Hex(),
+		UncleIndex:  uncleIndex,
+		Hash:        uncle.Hash().Hex(),
+		ParentHash:  uncle.ParentHash.Hex(),
+		UncleHash:   uncle.UncleHash.Hex(),
+		Coinbase:    uncle.Coinbase.Hex(),
+		Root:        uncle.Root.Hex(),
+		TxHash:      uncle.TxHash.Hex(),
+		ReceiptHash: uncle.ReceiptHash.Hex(),
+		Bloom:       uncle.Bloom.Bytes(),
+		Difficulty:  *uncleDifficulty,
+		Number:      *uncleNumber,
+		GasLimit:    uncle.GasLimit,
+		GasUsed:     uncle.GasUsed,
+		Time:        uncle.Time,
+		Extra:       uncle.Extra,
+		MixDigest:   uncle.MixDigest.Hex(),
+		Nonce:       *uncleNonce,
+		BaseFee:     uncleBaseFee,
 	}, nil
 }
 
@@ -163,38 +397,51 @@ func MakeOrphanedBlockModel(block *types.Block) (*models.OrphanedBlock, error) {
 		return nil, err
 	}
 
-	orphanedBlockBaseFee := new(pgtype.Numeric)
-	err = orphanedBlockBaseFee.Set(block.BaseFee().String())
-	if err != nil {
-		return nil, err
+	// Null unless the block is post-London
+	var orphanedBlockBaseFee *pgtype.Numeric
+	if block.BaseFee() != nil {
+		orphanedBlockBaseFee = new(pgtype.Numeric)
+		err = orphanedBlockBaseFee.Set(block.BaseFee().String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only set since Shanghai
+	orphanedBlockWithdrawalsRoot := ""
+	if block.Header().WithdrawalsHash != nil {
+		orphanedBlockWithdrawalsRoot = block.Header().WithdrawalsHash.Hex()
 	}
 
 	return &models.OrphanedBlock{
-		Hash:        block.Hash().Hex(),
-		Size:        uint64(block.Size()),
-		ParentHash:  block.ParentHash().Hex(),
-		UncleHash:   block.UncleHash().Hex(),
-		Coinbase:    block.Coinbase().Hex(),
-		Root:        block.Root().Hex(),
-		TxHash:      block.TxHash().Hex(),
-		ReceiptHash: block.ReceiptHash().Hex(),
-		Bloom:       block.Bloom().Bytes(),
-		Difficulty:  *orphanedBlockDifficulty,
-		Number:      *orphanedBlockNumber,
-		GasLimit:    block.GasLimit(),
-		GasUsed:     block.GasUsed(),
-		Time:        block.Time(),
-		Extra:       block.Extra(),
-		MixDigest:   block.MixDigest().Hex(),
-		Nonce:       *orphanedBlockNonce,
-		BaseFee:     *orphanedBlockBaseFee,
+		Hash:            block.Hash().Hex(),
+		Size:            uint64(block.Size()),
+		ParentHash:      block.ParentHash().Hex(),
+		UncleHash:       block.UncleHash().Hex(),
+		Coinbase:        block.Coinbase().Hex(),
+		Root:            block.Root().Hex(),
+		TxHash:          block.TxHash().Hex(),
+		ReceiptHash:     block.ReceiptHash().Hex(),
+		Bloom:           block.Bloom().Bytes(),
+		Difficulty:      *orphanedBlockDifficulty,
+		Number:          *orphanedBlockNumber,
+		GasLimit:        block.GasLimit(),
+		GasUsed:         block.GasUsed(),
+		Time:            block.Time(),
+		Extra:           block.Extra(),
+		MixDigest:       block.MixDigest().Hex(),
+		Nonce:           *orphanedBlockNonce,
+		BaseFee:         orphanedBlockBaseFee,
+		BlobGasUsed:     block.BlobGasUsed(),
+		ExcessBlobGas:   block.ExcessBlobGas(),
+		WithdrawalsRoot: orphanedBlockWithdrawalsRoot,
 	}, nil
 }
 
-func MakeOrphanedTransactionModel(transaction *types.Transaction, blockHash string) (*models.OrphanedTransaction, error) {
-	message, err := transaction.AsMessage(
+func MakeOrphanedTransactionModel(transaction *types.Transaction, transactionIndex uint, block *types.Block) (*models.OrphanedTransaction, error) {
+	sender, err := types.Sender(
 		types.LatestSignerForChainID(transaction.ChainId()),
-		nil,
+		transaction,
 	)
 	if err != nil {
 		return nil, err
@@ -224,6 +471,15 @@ func MakeOrphanedTransactionModel(transaction *types.Transaction, blockHash stri
 		return nil, err
 	}
 
+	var orphanedTransactionBlobGasFeeCap *pgtype.Numeric
+	if transaction.BlobGasFeeCap() != nil {
+		orphanedTransactionBlobGasFeeCap = new(pgtype.Numeric)
+		err = orphanedTransactionBlobGasFeeCap.Set(transaction.BlobGasFeeCap().String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	orphanedTransactionValue := new(pgtype.Numeric)
 	err = orphanedTransactionValue.Set(transaction.Value().String())
 	if err != nil {
@@ -236,21 +492,145 @@ func MakeOrphanedTransactionModel(transaction *types.Transaction, blockHash stri
 		return nil, err
 	}
 
+	orphanedTransactionBlockNumber := new(pgtype.Numeric)
+	err = orphanedTransactionBlockNumber.Set(block.Number().String())
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedTransactionTo := ""
+	orphanedTransactionToAddress := transaction.To()
+	if orphanedTransactionToAddress != nil {
+		orphanedTransactionTo = orphanedTransactionToAddress.Hex()
+	}
+
+	v, r, s := transaction.RawSignatureValues()
+
+	orphanedTransactionV := new(pgtype.Numeric)
+	err = orphanedTransactionV.Set(v.String())
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedTransactionR := new(pgtype.Numeric)
+	err = orphanedTransactionR.Set(r.String())
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedTransactionS := new(pgtype.Numeric)
+	err = orphanedTransactionS.Set(s.String())
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.OrphanedTransaction{
 		Hash:              transaction.Hash().Hex(),
 		Size:              uint64(transaction.Size()),
-		From:              message.From().Hex(),
+		From:              sender.Hex(),
 		Type:              byte(transaction.Type()),
 		ChainID:           *orphanedTransactionChainID,
+		AccessList:        MakeOrphanedAccessTupleModels(transaction, block.Hash().Hex()),
 		Data:              transaction.Data(),
 		Gas:               transaction.Gas(),
 		GasPrice:          *orphanedTransactionGasPrice,
 		GasTipCap:         *orphanedTransactionGasTipCap,
 		GasFeeCap:         *orphanedTransactionGasFeeCap,
+		BlobGasFeeCap:     orphanedTransactionBlobGasFeeCap,
+		BlobHashes:        MakeHashes(transaction.BlobHashes()),
 		Value:             *orphanedTransactionValue,
 		Nonce:             *orphanedTransactionNonce,
-		To:                transaction.To().Hex(),
-		OrphanedBlockHash: blockHash,
+		To:                orphanedTransactionTo,
+		V:                 *orphanedTransactionV,
+		R:                 *orphanedTransactionR,
+		S:                 *orphanedTransactionS,
+		OrphanedBlockHash: block.Hash().Hex(),
+		BlockNumber:       *orphanedTransactionBlockNumber,
+		TransactionIndex:  transactionIndex,
+	}, nil
+}
+
+func MakeOrphanedAccessTupleModels(transaction *types.Transaction, orphanedBlockHash string) []models.OrphanedAccessTuple {
+	orphanedAccessTupleModels := []models.OrphanedAccessTuple{}
+	for i, accessTuple := range transaction.AccessList() {
+		orphanedAccessTupleModels = append(orphanedAccessTupleModels, models.OrphanedAccessTuple{
+			TransactionHash:   transaction.Hash().Hex(),
+			OrphanedBlockHash: orphanedBlockHash,
+			TupleIndex:        uint(i),
+			Address:           accessTuple.Address.Hex(),
+			StorageKeys:       MakeStorageKeys(accessTuple.StorageKeys),
+		})
+	}
+
+	return orphanedAccessTupleModels
+}
+
+func MakeOrphanedWithdrawalModel(withdrawal *types.Withdrawal, block *types.Block) (*models.OrphanedWithdrawal, error) {
+	orphanedWithdrawalBlockNumber := new(pgtype.Numeric)
+	err := orphanedWithdrawalBlockNumber.Set(block.Number().String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OrphanedWithdrawal{
+		OrphanedBlockHash: block.Hash().Hex(),
+		WithdrawalIndex:   withdrawal.Index,
+		ValidatorIndex:    withdrawal.Validator,
+		Address:           withdrawal.Address.Hex(),
+		Amount:            withdrawal.Amount,
+		BlockNumber:       *orphanedWithdrawalBlockNumber,
+	}, nil
+}
+
+func MakeOrphanedUncleModel(uncle *types.Header, uncleIndex uint, block *types.Block) (*models.OrphanedUncle, error) {
+	orphanedUncleDifficulty := new(pgtype.Numeric)
+	err := orphanedUncleDifficulty.Set(uncle.Difficulty.String())
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedUncleNumber := new(pgtype.Numeric)
+	err = orphanedUncleNumber.Set(uncle.Number.String())
+	if err != nil {
+		return nil, err
+	}
+
+	orphanedUncleNonce := new(pgtype.Numeric)
+	err = orphanedUncleNonce.Set(uncle.Nonce.Uint64())
+	if err != nil {
+		return nil, err
+	}
+
+	// Null unless the uncle is post-London
+	var orphanedUncleBaseFee *pgtype.Numeric
+	if uncle.BaseFee != nil {
+		orphanedUncleBaseFee = new(pgtype.Numeric)
+		err = orphanedUncleBaseFee.Set(uncle.BaseFee.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.OrphanedUncle{
+		OrphanedBlockHash: block.Hash().Hex(),
+		UncleIndex:        uncleIndex,
+		Hash:              uncle.Hash().Hex(),
+		ParentHash:        uncle.ParentHash.Hex(),
+		UncleHash:         uncle.UncleHash.Hex(),
+		Coinbase:          uncle.Coinbase.Hex(),
+		Root:              uncle.Root.Hex(),
+		TxHash:            uncle.TxHash.Hex(),
+		ReceiptHash:       uncle.ReceiptHash.Hex(),
+		Bloom:             uncle.Bloom.Bytes(),
+		Difficulty:        *orphanedUncleDifficulty,
+		Number:            *orphanedUncleNumber,
+		GasLimit:          uncle.GasLimit,
+		GasUsed:           uncle.GasUsed,
+		Time:              uncle.Time,
+		Extra:             uncle.Extra,
+		MixDigest:         uncle.MixDigest.Hex(),
+		Nonce:             *orphanedUncleNonce,
+		BaseFee:           orphanedUncleBaseFee,
 	}, nil
 }
 
@@ -272,10 +652,13 @@ func GetTrackedAddressesFromFile(trackedAddressesFilePath string) ([]string, err
 		return nil, err
 	}
 
-	for _, trackedAddress := range trackedAddresses {
+	for i, trackedAddress := range trackedAddresses {
 		if !common.IsHexAddress(trackedAddress) {
 			return nil, errors.New("Addresses to track are improperly formatted")
 		}
+
+		// Addresses are tracked in their checksummed form
+		trackedAddresses[i] = common.HexToAddress(trackedAddress).Hex()
 	}
 
 	return trackedAddresses, nil
@@ -283,15 +666,15 @@ func GetTrackedAddressesFromFile(trackedAddressesFilePath string) ([]string, err
 
 func (poller *Poller) CheckIfIndexed(blockHash string) (bool, error) {
 	// Check if block has been indexed as a canonical block
-	_, err := poller.DB.GetBlockByHash(blockHash)
+	_, err := poller.Store.GetBlockByHash(blockHash)
 	if err == nil {
 		return true, nil
-	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+	} else if errors.Is(err, models.ErrRecordNotFound) {
 		// Check if it's been indexed as an orphaned block
-		_, err = poller.DB.GetOrphanedBlockByHash(blockHash)
+		_, err = poller.Store.GetOrphanedBlockByHash(blockHash)
 		if err == nil {
 			return true, nil
-		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		} else if errors.Is(err, models.ErrRecordNotFound) {
 			return false, nil
 		} else {
 			return false, err
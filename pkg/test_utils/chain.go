@@ -0,0 +1,409 @@
+package test_utils
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"getherscan/pkg/poller"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+// Chain ID of the transactions in test chains, which matches the
+// mock RPC server used in tests
+var TestChainID = big.NewInt(1)
+
+// Number of the root block of test chains
+const TestChainRootNumber = 1
+
+// Receives the withdrawal processed in every block of withdrawal and
+// blob test chains
+var TestChainWithdrawalAddress = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+// Signs the transaction included in every test chain block
+var testChainKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+// Block names are letters and digits, optionally followed by a
+// difficulty, e.g. "D:3", and by the names of uncles, e.g. "D^U^V"
+var testChainBlockPattern = regexp.MustCompile(`^([A-Za-z0-9]+)(?::([0-9]+))?((?:\^[A-Za-z0-9]+)*)$`)
+
+// Synthetic chain of validly linked blocks built from a compact
+// description, for testing reorgs without capturing real blocks.
+// The description lists branches separated by commas, each a path of
+// block names separated by dashes. The first branch starts at the
+// root block, and every other branch starts at an existing block,
+// from which it forks. For example, "A-B-C, B-D-E-F" describes:
+//
+//	A - B - C
+//	     \
+//	      D - E - F
+//
+// Blocks have a difficulty of 1 unless given one, e.g. "B-D:3", and
+// can include previously described blocks as uncles, e.g. in
+// "A-U, A-B-C^U", C includes U. Each block holds a single
+// transaction, unique to it
+type TestChain struct {
+	blocks map[string]*types.Block
+	// Block names, in the order in which they appear in the
+	// description
+	names []string
+	// Whether the blocks are post-Shanghai ones processing a
+	// withdrawal
+	withdrawals bool
+	// Whether the blocks are post-Cancun ones holding a blob
+	// transaction
+	blobs bool
+	// Whether the blocks are pre-London ones, without a base fee,
+	// holding a legacy transaction
+	preLondon bool
+}
+
+func NewTestChain(description string) (*TestChain, error) {
+	return newTestChain(description, false, false, false)
+}
+
+// Same as NewTestChain, but each block processes a withdrawal to
+// TestChainWithdrawalAddress. The withdrawal's index, validator index
+// and amount (in Gwei) are the block number
+func NewWithdrawalTestChain(description string) (*TestChain, error) {
+	return newTestChain(description, true, false, false)
+}
+
+// Same as NewWithdrawalTestChain, as post-Cancun blocks are also
+// post-Shanghai ones, but each block holds a blob transaction (with an
+// access list) instead, and records its blob gas
+func NewBlobTestChain(description string) (*TestChain, error) {
+	return newTestChain(description, true, true, false)
+}
+
+// Same as NewTestChain, but the blocks have no base fee, and each holds
+// a legacy transaction instead
+func NewPreLondonTestChain(description string) (*TestChain, error) {
+	return newTestChain(description, false, false, true)
+}
+
+func newTestChain(description string, withdrawals, blobs, preLondon bool) (*TestChain, error) {
+	chain := &TestChain{
+		blocks:      make(map[string]*types.Block),
+		withdrawals: withdrawals,
+		blobs:       blobs,
+		preLondon:   preLondon,
+	}
+
+	for i, branch := range strings.Split(description, ",") {
+		var parent *types.Block
+		for j, blockSpec := range strings.Split(strings.TrimSpace(branch), "-") {
+			match := testChainBlockPattern.FindStringSubmatch(strings.TrimSpace(blockSpec))
+			if match == nil {
+				return nil, fmt.Errorf("Invalid block %q in test chain %q", blockSpec, description)
+			}
+
+			name := match[1]
+
+			// Every branch but the first starts at an
+			// existing block
+			if j == 0 && i > 0 {
+				if match[2] != "" || match[3] != "" {
+					return nil, fmt.Errorf("Fork point %s can't be given a difficulty or uncles", name)
+				}
+
+				var ok bool
+				parent, ok = chain.blocks[name]
+				if !ok {
+					return nil, fmt.Errorf("Unknown fork point %s in test chain %q", name, description)
+				}
+
+				continue
+			}
+
+			if _, ok := chain.blocks[name]; ok {
+				return nil, fmt.Errorf("Duplicate block %s in test chain %q", name, description)
+			}
+
+			difficulty := int64(1)
+			if match[2] != "" {
+				var err error
+				difficulty, err = strconv.ParseInt(match[2], 10, 64)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			uncles := []*types.Header{}
+			for _, uncleName := range strings.Split(match[3], "^")[1:] {
+				uncle, ok := chain.blocks[uncleName]
+				if !ok {
+					return nil, fmt.Errorf("Unknown uncle %s in test chain %q", uncleName, description)
+				}
+
+				uncles = append(uncles, uncle.Header())
+			}
+
+			block, err := chain.makeBlock(name, parent, difficulty, uncles)
+			if err != nil {
+				return nil, err
+			}
+
+			chain.blocks[name] = block
+			chain.names = append(chain.names, name)
+			parent = block
+		}
+	}
+
+	return chain, nil
+}
+
+func (chain *TestChain) makeBlock(name string, parent *types.Block, difficulty int64, uncles []*types.Header) (*types.Block, error) {
+	header := &types.Header{
+		UncleHash:  types.EmptyUncleHash,
+		Root:       types.EmptyRootHash,
+		Difficulty: big.NewInt(difficulty),
+		Number:     big.NewInt(TestChainRootNumber),
+		GasLimit:   params.GenesisGasLimit,
+		GasUsed:    params.TxGas,
+		// Blocks with the same parent only differ by name
+		Extra:   []byte(name),
+		BaseFee: big.NewInt(params.InitialBaseFee),
+	}
+
+	if parent != nil {
+		header.ParentHash = parent.Hash()
+		header.Number = new(big.Int).Add(parent.Number(), big.NewInt(1))
+		header.Time = parent.Time() + 12
+	}
+
+	var transaction *types.Transaction
+	var err error
+	if chain.preLondon {
+		header.BaseFee = nil
+
+		transaction, err = makeTestChainLegacyTransaction(name, header.Number.Uint64(), testChainKey)
+	} else if chain.blobs {
+		blobGasUsed := uint64(params.BlobTxBlobGasPerBlob)
+		header.BlobGasUsed = &blobGasUsed
+		header.ExcessBlobGas = new(uint64)
+
+		transaction, err = makeTestChainBlobTransaction(name, header.Number.Uint64(), testChainKey)
+	} else {
+		transaction, err = makeTestChainTransaction(name, header.Number.Uint64(), testChainKey)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if chain.withdrawals {
+		withdrawals := []*types.Withdrawal{{
+			Index:     header.Number.Uint64(),
+			Validator: header.Number.Uint64(),
+			Address:   TestChainWithdrawalAddress,
+			Amount:    header.Number.Uint64(),
+		}}
+
+		return types.NewBlockWithWithdrawals(header, []*types.Transaction{transaction}, uncles, nil, withdrawals, trie.NewStackTrie(nil)), nil
+	}
+
+	return types.NewBlock(header, []*types.Transaction{transaction}, uncles, nil, trie.NewStackTrie(nil)), nil
+}
+
+// Makes a transfer to the zero address with the block name as data,
+// so that forks at the same height have different transactions
+func makeTestChainTransaction(name string, nonce uint64, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	return types.SignNewTx(key, types.LatestSignerForChainID(TestChainID), &types.DynamicFeeTx{
+		ChainID:   TestChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(params.GWei),
+		GasFeeCap: big.NewInt(2 * params.InitialBaseFee),
+		Gas:       params.TxGas + uint64(len(name))*params.TxDataNonZeroGasEIP2028,
+		To:        &common.Address{},
+		Value:     big.NewInt(1),
+		Data:      []byte(name),
+	})
+}
+
+// Same as makeTestChainTransaction, but paying a fixed gas price, as
+// transactions did before London
+func makeTestChainLegacyTransaction(name string, nonce uint64, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	return types.SignNewTx(key, types.LatestSignerForChainID(TestChainID), &types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: big.NewInt(2 * params.InitialBaseFee),
+		Gas:      params.TxGas + uint64(len(name))*params.TxDataNonZeroGasEIP2028,
+		To:       &common.Address{},
+		Value:    big.NewInt(1),
+		Data:     []byte(name),
+	})
+}
+
+// Same as makeTestChainTransaction, but carrying a single blob whose
+// versioned hash is derived from the block name, and accessing a
+// storage slot of the zero address
+func makeTestChainBlobTransaction(name string, nonce uint64, key *ecdsa.PrivateKey) (*types.Transaction, error) {
+	// Versioned hashes start with the version of the commitment
+	// scheme, 0x01 for KZG
+	blobHash := crypto.Keccak256Hash([]byte(name))
+	blobHash[0] = 0x01
+
+	return types.SignNewTx(key, types.LatestSignerForChainID(TestChainID), &types.BlobTx{
+		ChainID:   uint256.MustFromBig(TestChainID),
+		Nonce:     nonce,
+		GasTipCap: uint256.NewInt(params.GWei),
+		GasFeeCap: uint256.NewInt(2 * params.InitialBaseFee),
+		Gas:       params.TxGas + uint64(len(name))*params.TxDataNonZeroGasEIP2028 + params.TxAccessListAddressGas + params.TxAccessListStorageKeyGas,
+		To:        common.Address{},
+		Value:     uint256.NewInt(1),
+		Data:      []byte(name),
+		AccessList: types.AccessList{{
+			Address:     common.Address{},
+			StorageKeys: []common.Hash{{}},
+		}},
+		BlobFeeCap: uint256.NewInt(params.BlobTxMinBlobGasprice),
+		BlobHashes: []common.Hash{blobHash},
+	})
+}
+
+func (chain *TestChain) Block(name string) (*types.Block, error) {
+	block, ok := chain.blocks[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown block %s", name)
+	}
+
+	return block, nil
+}
+
+// Fetches the blocks with the given names, in the given order
+func (chain *TestChain) Blocks(names ...string) ([]types.Block, error) {
+	blocks := make([]types.Block, len(names))
+	for i, name := range names {
+		block, err := chain.Block(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+
+		blocks[i] = *block
+	}
+
+	return blocks, nil
+}
+
+// Fetches the blocks of a dash-separated path through the chain (e.g.
+// "A-B-D"), from NEWEST to OLDEST, which is the order the Assert
+// functions expect
+func (chain *TestChain) path(path string) ([]types.Block, error) {
+	blocks, err := chain.Blocks(strings.Split(path, "-")...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < len(blocks); i++ {
+		if blocks[i].ParentHash() != blocks[i-1].Hash() {
+			return nil, fmt.Errorf("Path %s is not linked", path)
+		}
+	}
+
+	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+		blocks[i], blocks[j] = blocks[j], blocks[i]
+	}
+
+	return blocks, nil
+}
+
+// Serves every block of the chain from server, with canonicalHead and
+// its ancestors as the canonical chain. Missing blocks the poller
+// fetches by number come from this chain
+func (chain *TestChain) Serve(server *MockRPCServer, canonicalHead string) error {
+	allBlocks, err := chain.Blocks(chain.names...)
+	if err != nil {
+		return err
+	}
+
+	server.AddBlocks(allBlocks)
+
+	canonicalBlocks := []types.Block{}
+	for block, ok := chain.blocks[canonicalHead]; ok; block, ok = chain.blockByHash(block.ParentHash()) {
+		canonicalBlocks = append([]types.Block{*block}, canonicalBlocks...)
+	}
+
+	if len(canonicalBlocks) == 0 {
+		return fmt.Errorf("Unknown block %s", canonicalHead)
+	}
+
+	server.AddBlocks(canonicalBlocks)
+
+	return nil
+}
+
+func (chain *TestChain) blockByHash(blockHash common.Hash) (*types.Block, bool) {
+	for _, block := range chain.blocks {
+		if block.Hash() == blockHash {
+			return block, true
+		}
+	}
+
+	return nil, false
+}
+
+// Indexes the blocks with the given names, in the given order, as if
+// the node had announced them in that order
+func (chain *TestChain) Deliver(testPoller *poller.Poller, names ...string) error {
+	blocks, err := chain.Blocks(names...)
+	if err != nil {
+		return err
+	}
+
+	return TestPoll(testPoller, blocks)
+}
+
+// Asserts that the poller's canonical chain, down from its head, is
+// the path canonicalPath (e.g. "A-B-D-E-F"), and that its orphaned
+// blocks are exactly those of orphanedPaths (e.g. "C"), each of which
+// forks off the canonical chain
+func (chain *TestChain) AssertIndexed(testPoller *poller.Poller, canonicalPath string, orphanedPaths ...string) error {
+	canonicalBlocks, err := chain.path(canonicalPath)
+	if err != nil {
+		return err
+	}
+
+	err = AssertCanonicalBlocks(testPoller, canonicalBlocks)
+	if err != nil {
+		return err
+	}
+
+	orphanedBlockCount := 0
+	for _, orphanedPath := range orphanedPaths {
+		orphanedBlocks, err := chain.path(orphanedPath)
+		if err != nil {
+			return err
+		}
+
+		err = AssertOrphanedBlocks(testPoller, orphanedBlocks)
+		if err != nil {
+			return err
+		}
+
+		orphanedBlockCount += len(orphanedBlocks)
+	}
+
+	if len(orphanedPaths) == 0 {
+		return AssertOrphanedBlocks(testPoller, []types.Block{})
+	}
+
+	orphanedBlockModels, err := testPoller.Store.GetAllOrphanedBlocks()
+	if err != nil {
+		return err
+	}
+
+	if len(orphanedBlockModels) != orphanedBlockCount {
+		return errors.New(fmt.Sprintf("There are %d orphaned blocks, there should be %d", len(orphanedBlockModels), orphanedBlockCount))
+	}
+
+	return nil
+}
@@ -0,0 +1,691 @@
+package test_utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"getherscan/pkg/poller"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Recorded address balances, by address and then by block number.
+// Serialized to JSON as decimal strings, e.g.
+// {"0xabc...": {"13706606": "1000000000000000000"}}
+type BalanceTable map[string]map[uint64]*big.Int
+
+func (balanceTable BalanceTable) MarshalJSON() ([]byte, error) {
+	encodedBalanceTable := make(map[string]map[string]string, len(balanceTable))
+	for address, balances := range balanceTable {
+		encodedBalances := make(map[string]string, len(balances))
+		for blockNumber, balance := range balances {
+			encodedBalances[strconv.FormatUint(blockNumber, 10)] = balance.String()
+		}
+
+		encodedBalanceTable[address] = encodedBalances
+	}
+
+	return json.Marshal(encodedBalanceTable)
+}
+
+func (balanceTable *BalanceTable) UnmarshalJSON(data []byte) error {
+	var encodedBalanceTable map[string]map[string]string
+	err := json.Unmarshal(data, &encodedBalanceTable)
+	if err != nil {
+		return err
+	}
+
+	*balanceTable = make(BalanceTable, len(encodedBalanceTable))
+	for address, encodedBalances := range encodedBalanceTable {
+		balances := make(map[uint64]*big.Int, len(encodedBalances))
+		for encodedBlockNumber, encodedBalance := range encodedBalances {
+			blockNumber, err := strconv.ParseUint(encodedBlockNumber, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			balance, ok := new(big.Int).SetString(encodedBalance, 10)
+			if !ok {
+				return fmt.Errorf("Invalid balance %s for %s at block %s", encodedBalance, address, encodedBlockNumber)
+			}
+
+			balances[blockNumber] = balance
+		}
+
+		(*balanceTable)[strings.ToLower(address)] = balances
+	}
+
+	return nil
+}
+
+// Fetches the balances of addresses at each of blocks via RPC, to be
+// saved alongside the blocks and served by MockRPCServer. Like
+// SaveBlocks, this needs to be run while the blocks are recent enough
+// for the endpoint to have their state
+func SaveBalances(ethClient *ethclient.Client, addresses []string, blocks []types.Block) (BalanceTable, error) {
+	balanceTable := make(BalanceTable, len(addresses))
+	for _, address := range addresses {
+		balances := make(map[uint64]*big.Int, len(blocks))
+		for _, block := range blocks {
+			balance, err := ethClient.BalanceAt(context.Background(), common.HexToAddress(address), block.Number())
+			if err != nil {
+				return nil, err
+			}
+
+			balances[block.NumberU64()] = balance
+		}
+
+		balanceTable[strings.ToLower(address)] = balances
+	}
+
+	return balanceTable, nil
+}
+
+// Reads a JSON-encoded BalanceTable from the given file path
+func GetBalancesFromFile(balancesFilePath string) (BalanceTable, error) {
+	balancesFile, err := os.ReadFile(balancesFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var balanceTable BalanceTable
+	err = json.Unmarshal(balancesFile, &balanceTable)
+	if err != nil {
+		return nil, err
+	}
+
+	return balanceTable, nil
+}
+
+// ERC-20 and ERC-721 tokens whose transfers are logged by the
+// receipts that MakeTransferReceipts synthesizes
+var TestTokenAddress = common.HexToAddress("0x00000000000000000000000000000000000000e2")
+var TestNFTAddress = common.HexToAddress("0x00000000000000000000000000000000000000e7")
+
+// Synthesizes successful receipts for the transactions of blocks, for
+// MockRPCServer to serve in place of recorded ones. Each receipt logs
+// an ERC-20 transfer of TestTokenAddress and an ERC-721 transfer of
+// TestNFTAddress, both minted to the transaction's sender. The
+// block number times 1000 plus the transaction's index is both the
+// ERC-20 value (in units of 10^18) and the ERC-721 token ID
+func MakeTransferReceipts(blocks []types.Block) ([]*types.Receipt, error) {
+	var receipts []*types.Receipt
+	for _, block := range blocks {
+		logIndex := uint(0)
+		for i, transaction := range block.Transactions() {
+			// Unlike the recipient, the sender is never the
+			// zero address, which the indexer treats as a
+			// burn
+			sender, err := types.Sender(types.LatestSignerForChainID(transaction.ChainId()), transaction)
+			if err != nil {
+				return nil, err
+			}
+
+			tokenID := new(big.Int).SetUint64(block.NumberU64()*1000 + uint64(i))
+			value := new(big.Int).Mul(tokenID, big.NewInt(params.Ether))
+
+			transferLog := &types.Log{
+				Address: TestTokenAddress,
+				Topics: []common.Hash{
+					common.HexToHash(poller.TransferEventTopic),
+					common.BytesToHash(common.Address{}.Bytes()),
+					common.BytesToHash(sender.Bytes()),
+				},
+				Data:        common.BigToHash(value).Bytes(),
+				BlockNumber: block.NumberU64(),
+				TxHash:      transaction.Hash(),
+				TxIndex:     uint(i),
+				BlockHash:   block.Hash(),
+				Index:       logIndex,
+			}
+			logIndex++
+
+			nftTransferLog := &types.Log{
+				Address: TestNFTAddress,
+				Topics: []common.Hash{
+					common.HexToHash(poller.TransferEventTopic),
+					common.BytesToHash(common.Address{}.Bytes()),
+					common.BytesToHash(sender.Bytes()),
+					common.BigToHash(tokenID),
+				},
+				BlockNumber: block.NumberU64(),
+				TxHash:      transaction.Hash(),
+				TxIndex:     uint(i),
+				BlockHash:   block.Hash(),
+				Index:       logIndex,
+			}
+			logIndex++
+
+			receipts = append(receipts, &types.Receipt{
+				Type:              transaction.Type(),
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: transaction.Gas(),
+				TxHash:            transaction.Hash(),
+				GasUsed:           transaction.Gas(),
+				BlockHash:         block.Hash(),
+				BlockNumber:       block.Number(),
+				TransactionIndex:  uint(i),
+				Logs:              []*types.Log{transferLog, nftTransferLog},
+			})
+		}
+	}
+
+	return receipts, nil
+}
+
+// Local stand-in for an Ethereum node's websocket RPC endpoint, which
+// serves saved blocks (e.g. from GetBlocksFromDir) and recorded
+// balances and receipts, so that the poller can run with no network.
+// Serves eth_chainId, eth_subscribe (newHeads), eth_getBlockByHash,
+// eth_getBlockByNumber (including the latest, safe and finalized
+// tags), eth_getUncleByBlockHashAndIndex, eth_getTransactionReceipt,
+// eth_getBalance and debug_traceBlockByHash (callTracer, and
+// prestateTracer in diff mode)
+type MockRPCServer struct {
+	// Websocket URL to pass to poller.Initialize
+	URL string
+
+	httpServer *httptest.Server
+	rpcServer  *rpc.Server
+	service    *mockEthService
+}
+
+// Starts a mock RPC server for the chain with the given ID, with no
+// blocks
+func NewMockRPCServer(chainID *big.Int) (*MockRPCServer, error) {
+	service := &mockEthService{chainID: chainID}
+	service.reset()
+
+	rpcServer := rpc.NewServer()
+	err := rpcServer.RegisterName("eth", service)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rpcServer.RegisterName("debug", &mockDebugService{service})
+	if err != nil {
+		return nil, err
+	}
+
+	httpServer := httptest.NewServer(rpcServer.WebsocketHandler([]string{"*"}))
+
+	return &MockRPCServer{
+		URL:        "ws" + strings.TrimPrefix(httpServer.URL, "http"),
+		httpServer: httpServer,
+		rpcServer:  rpcServer,
+		service:    service,
+	}, nil
+}
+
+func (server *MockRPCServer) Close() {
+	server.rpcServer.Stop()
+	server.httpServer.Close()
+}
+
+// Forgets every block, balance, receipt, trace and state diff, the
+// safe and finalized blocks, the request counts and failures
+func (server *MockRPCServer) Reset() {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	server.service.reset()
+}
+
+// Serves the given blocks. Each block becomes the canonical block at
+// its number, replacing any block added before it at the same number,
+// so forks should be added in the order in which they were canonical
+func (server *MockRPCServer) AddBlocks(blocks []types.Block) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	for i := range blocks {
+		server.service.addBlock(&blocks[i])
+	}
+}
+
+// Serves the given block, and announces it as the new head to newHeads
+// subscribers
+func (server *MockRPCServer) AnnounceBlock(block *types.Block) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	server.service.addBlock(block)
+
+	for id, notifier := range server.service.subscriptions {
+		// Subscriptions whose client has gone away are removed
+		// by the goroutine watching them
+		notifier.Notify(id, block.Header())
+	}
+}
+
+// Serves balances from balanceTable, for blocks requested by number
+// or hash
+func (server *MockRPCServer) SetBalances(balanceTable BalanceTable) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	server.service.balances = balanceTable
+}
+
+// Serves the given receipts for their transactions. A transaction
+// included in several blocks (e.g. on both sides of a reorg) can have
+// a receipt for each of them, in which case the one from the block
+// canonical at the time of the request is served, as a node would
+func (server *MockRPCServer) AddReceipts(receipts []*types.Receipt) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	for _, receipt := range receipts {
+		transactionReceipts := server.service.receipts[receipt.TxHash]
+		if transactionReceipts == nil {
+			transactionReceipts = make(map[common.Hash]*types.Receipt)
+			server.service.receipts[receipt.TxHash] = transactionReceipts
+		}
+
+		transactionReceipts[receipt.BlockHash] = receipt
+	}
+}
+
+// Serves the given traces for their transactions. Transactions
+// without one are traced as a call making no internal calls
+func (server *MockRPCServer) AddTraces(traces []*poller.TransactionTrace) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	for _, trace := range traces {
+		server.service.traces[trace.TxHash] = trace
+	}
+}
+
+// Serves the given state diffs for their transactions. Transactions
+// without one are traced as changing nothing
+func (server *MockRPCServer) AddStateDiffs(stateDiffs []*poller.TransactionStateDiff) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	for _, stateDiff := range stateDiffs {
+		server.service.stateDiffs[stateDiff.TxHash] = stateDiff
+	}
+}
+
+// Fails the next count eth_getBalance requests, as a node timing out
+// would
+func (server *MockRPCServer) FailBalanceRequests(count int) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	server.service.balanceFailures = count
+}
+
+// Sets the blocks returned for the safe and finalized tags, which are
+// unknown until set
+func (server *MockRPCServer) SetFinality(safeBlockHash, finalizedBlockHash common.Hash) {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	server.service.safeBlockHash = &safeBlockHash
+	server.service.finalizedBlockHash = &finalizedBlockHash
+}
+
+// Number of eth_getBlockByNumber requests served since the last reset
+func (server *MockRPCServer) BlockByNumberRequests() int {
+	server.service.lock.Lock()
+	defer server.service.lock.Unlock()
+
+	return server.service.blockByNumberRequests
+}
+
+// Receiver for the eth namespace of the mock server. The rpc package
+// exposes its exported methods, e.g. GetBlockByHash as
+// eth_getBlockByHash
+type mockEthService struct {
+	lock    sync.Mutex
+	chainID *big.Int
+
+	blocksByHash       map[common.Hash]*types.Block
+	canonicalBlocks    map[uint64]*types.Block
+	head               *types.Block
+	safeBlockHash      *common.Hash
+	finalizedBlockHash *common.Hash
+	balances           BalanceTable
+	// By transaction hash, then by block hash
+	receipts      map[common.Hash]map[common.Hash]*types.Receipt
+	traces        map[common.Hash]*poller.TransactionTrace
+	stateDiffs    map[common.Hash]*poller.TransactionStateDiff
+	subscriptions map[rpc.ID]*rpc.Notifier
+
+	balanceFailures       int
+	blockByNumberRequests int
+}
+
+// Must be called with the lock held, except when creating the service
+func (service *mockEthService) reset() {
+	service.blocksByHash = make(map[common.Hash]*types.Block)
+	service.canonicalBlocks = make(map[uint64]*types.Block)
+	service.head = nil
+	service.safeBlockHash = nil
+	service.finalizedBlockHash = nil
+	service.balances = make(BalanceTable)
+	service.receipts = make(map[common.Hash]map[common.Hash]*types.Receipt)
+	service.traces = make(map[common.Hash]*poller.TransactionTrace)
+	service.stateDiffs = make(map[common.Hash]*poller.TransactionStateDiff)
+	service.balanceFailures = 0
+	service.blockByNumberRequests = 0
+	if service.subscriptions == nil {
+		service.subscriptions = make(map[rpc.ID]*rpc.Notifier)
+	}
+}
+
+// Must be called with the lock held
+func (service *mockEthService) addBlock(block *types.Block) {
+	service.blocksByHash[block.Hash()] = block
+	service.canonicalBlocks[block.NumberU64()] = block
+
+	if service.head == nil || block.NumberU64() >= service.head.NumberU64() {
+		service.head = block
+	}
+}
+
+// Resolves a block number or tag the way a node does. Must be called
+// with the lock held
+func (service *mockEthService) blockByNumberOrTag(blockNumberOrTag string) (*types.Block, error) {
+	switch blockNumberOrTag {
+	case "latest", "pending":
+		return service.head, nil
+	case "earliest":
+		return service.canonicalBlocks[0], nil
+	case "safe":
+		if service.safeBlockHash == nil {
+			return nil, nil
+		}
+
+		return service.blocksByHash[*service.safeBlockHash], nil
+	case "finalized":
+		if service.finalizedBlockHash == nil {
+			return nil, nil
+		}
+
+		return service.blocksByHash[*service.finalizedBlockHash], nil
+	}
+
+	blockNumber, err := hexutil.DecodeUint64(blockNumberOrTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.canonicalBlocks[blockNumber], nil
+}
+
+func (service *mockEthService) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(service.chainID)
+}
+
+func (service *mockEthService) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	subscription := notifier.CreateSubscription()
+
+	service.lock.Lock()
+	service.subscriptions[subscription.ID] = notifier
+	service.lock.Unlock()
+
+	go func() {
+		<-subscription.Err()
+
+		service.lock.Lock()
+		delete(service.subscriptions, subscription.ID)
+		service.lock.Unlock()
+	}()
+
+	return subscription, nil
+}
+
+func (service *mockEthService) GetBlockByHash(blockHash common.Hash, fullTransactions bool) (map[string]interface{}, error) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	return service.marshalBlock(service.blocksByHash[blockHash], fullTransactions)
+}
+
+func (service *mockEthService) GetBlockByNumber(blockNumberOrTag string, fullTransactions bool) (map[string]interface{}, error) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	service.blockByNumberRequests++
+
+	block, err := service.blockByNumberOrTag(blockNumberOrTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.marshalBlock(block, fullTransactions)
+}
+
+func (service *mockEthService) GetUncleByBlockHashAndIndex(blockHash common.Hash, index hexutil.Uint) (*types.Header, error) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	block, ok := service.blocksByHash[blockHash]
+	if !ok || int(index) >= len(block.Uncles()) {
+		return nil, nil
+	}
+
+	return block.Uncles()[index], nil
+}
+
+func (service *mockEthService) GetTransactionReceipt(transactionHash common.Hash) (*types.Receipt, error) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	// Otherwise a receipt from a block that isn't canonical is
+	// served, e.g. for a fork a test delivers without serving it
+	// as canonical
+	var fallbackReceipt *types.Receipt
+	for blockHash, receipt := range service.receipts[transactionHash] {
+		canonicalBlock := service.canonicalBlocks[receipt.BlockNumber.Uint64()]
+		if canonicalBlock != nil && canonicalBlock.Hash() == blockHash {
+			return receipt, nil
+		}
+
+		fallbackReceipt = receipt
+	}
+
+	return fallbackReceipt, nil
+}
+
+// Receiver for the debug namespace of the mock server, sharing the
+// state of the eth namespace
+type mockDebugService struct {
+	eth *mockEthService
+}
+
+func (service *mockDebugService) TraceBlockByHash(blockHash common.Hash, config map[string]interface{}) (interface{}, error) {
+	service.eth.lock.Lock()
+	defer service.eth.lock.Unlock()
+
+	block, ok := service.eth.blocksByHash[blockHash]
+	if !ok {
+		return nil, fmt.Errorf("Block %s not found", blockHash.Hex())
+	}
+
+	switch config["tracer"] {
+	case "callTracer":
+		return service.traceCalls(block), nil
+	case "prestateTracer":
+		tracerConfig, _ := config["tracerConfig"].(map[string]interface{})
+		if tracerConfig["diffMode"] != true {
+			return nil, fmt.Errorf("Only the diff mode of the prestateTracer is supported")
+		}
+
+		return service.traceStateDiffs(block), nil
+	default:
+		return nil, fmt.Errorf("Unsupported tracer %v", config["tracer"])
+	}
+}
+
+// Must be called with the lock held
+func (service *mockDebugService) traceCalls(block *types.Block) []*poller.TransactionTrace {
+	traces := make([]*poller.TransactionTrace, len(block.Transactions()))
+	for i, transaction := range block.Transactions() {
+		trace, ok := service.eth.traces[transaction.Hash()]
+		if !ok {
+			trace = &poller.TransactionTrace{
+				TxHash: transaction.Hash(),
+				Result: &poller.CallFrame{
+					Type:  "CALL",
+					To:    transaction.To(),
+					Value: (*hexutil.Big)(transaction.Value()),
+					Gas:   hexutil.Uint64(transaction.Gas()),
+					Input: transaction.Data(),
+				},
+			}
+		}
+
+		traces[i] = trace
+	}
+
+	return traces
+}
+
+// Must be called with the lock held
+func (service *mockDebugService) traceStateDiffs(block *types.Block) []*poller.TransactionStateDiff {
+	stateDiffs := make([]*poller.TransactionStateDiff, len(block.Transactions()))
+	for i, transaction := range block.Transactions() {
+		stateDiff, ok := service.eth.stateDiffs[transaction.Hash()]
+		if !ok {
+			stateDiff = &poller.TransactionStateDiff{
+				TxHash: transaction.Hash(),
+				Result: &poller.StateDiff{
+					Pre:  map[common.Address]*poller.AccountState{},
+					Post: map[common.Address]*poller.AccountState{},
+				},
+			}
+		}
+
+		stateDiffs[i] = stateDiff
+	}
+
+	return stateDiffs
+}
+
+func (service *mockEthService) GetBalance(address common.Address, blockNumberOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	service.lock.Lock()
+	defer service.lock.Unlock()
+
+	if service.balanceFailures > 0 {
+		service.balanceFailures--
+		return nil, errors.New("Request timed out")
+	}
+
+	var block *types.Block
+	if blockHash, ok := blockNumberOrHash.Hash(); ok {
+		block = service.blocksByHash[blockHash]
+	} else {
+		var err error
+		block, err = service.blockByNumberOrTag(blockNumberOrHash.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if block == nil {
+		return nil, fmt.Errorf("Unknown block %s", blockNumberOrHash.String())
+	}
+
+	// Like a node without archival state, we can only answer for
+	// the blocks we have state (here, recorded balances) for
+	balance, ok := service.balances[strings.ToLower(address.Hex())][block.NumberU64()]
+	if !ok {
+		return nil, fmt.Errorf("missing trie node %s (no recorded balance for %s at block %d)", block.Root().Hex(), address.Hex(), block.NumberU64())
+	}
+
+	return (*hexutil.Big)(balance), nil
+}
+
+// Encodes a block the way a node does, as its header's fields plus
+// its size, transactions (or their hashes), uncle hashes and, since
+// Shanghai, withdrawals. Must be called with the lock held
+func (service *mockEthService) marshalBlock(block *types.Block, fullTransactions bool) (map[string]interface{}, error) {
+	if block == nil {
+		return nil, nil
+	}
+
+	fields, err := marshalToFields(block.Header())
+	if err != nil {
+		return nil, err
+	}
+
+	fields["size"] = hexutil.Uint64(block.Size())
+
+	signer := types.LatestSignerForChainID(service.chainID)
+	transactions := make([]interface{}, len(block.Transactions()))
+	for i, transaction := range block.Transactions() {
+		if !fullTransactions {
+			transactions[i] = transaction.Hash()
+			continue
+		}
+
+		transactionFields, err := marshalToFields(transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		from, err := types.Sender(signer, transaction)
+		if err != nil {
+			return nil, err
+		}
+
+		transactionFields["blockHash"] = block.Hash()
+		transactionFields["blockNumber"] = (*hexutil.Big)(block.Number())
+		transactionFields["transactionIndex"] = hexutil.Uint64(i)
+		transactionFields["from"] = from
+		transactions[i] = transactionFields
+	}
+
+	fields["transactions"] = transactions
+
+	uncleHashes := make([]common.Hash, len(block.Uncles()))
+	for i, uncle := range block.Uncles() {
+		uncleHashes[i] = uncle.Hash()
+	}
+
+	fields["uncles"] = uncleHashes
+
+	if block.Header().WithdrawalsHash != nil {
+		fields["withdrawals"] = block.Withdrawals()
+	}
+
+	return fields, nil
+}
+
+// Encodes value to JSON and decodes it into a map of its fields, to
+// which more fields can be added
+func marshalToFields(value interface{}) (map[string]interface{}, error) {
+	encodedValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	err = json.Unmarshal(encodedValue, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
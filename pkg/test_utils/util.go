@@ -13,7 +13,6 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rlp"
-	"gorm.io/gorm"
 )
 
 // Fetches the blocks whose hashes are in hexBlockHashes via RPC for
@@ -90,7 +89,7 @@ func TestPoll(testPoller *poller.Poller, blocks []types.Block) error {
 
 // Expects that blocks has the chain's blocks from NEWEST to OLDEST
 func AssertCanonicalBlocks(testPoller *poller.Poller, blocks []types.Block) error {
-	currentBlockModel, err := testPoller.DB.GetHead()
+	currentBlockModel, err := testPoller.Store.GetHead()
 	if err != nil {
 		return err
 	}
@@ -105,19 +104,59 @@ func AssertCanonicalBlocks(testPoller *poller.Poller, blocks []types.Block) erro
 		// Assert that the block's transactions have been
 		// indexed
 		for _, transaction := range block.Transactions() {
-			_, err := testPoller.DB.GetTransactionByHash(transaction.Hash().Hex(), false)
+			transactionModel, err := testPoller.Store.GetTransactionByHash(transaction.Hash().Hex(), false)
 			if err != nil {
 				return err
 			}
+
+			// Assert that the transaction's access list
+			// has been indexed along with it
+			if len(transactionModel.AccessList) != len(transaction.AccessList()) {
+				return errors.New(fmt.Sprintf("Access list of transaction %s has %d tuples, expected %d", transaction.Hash().Hex(), len(transactionModel.AccessList), len(transaction.AccessList())))
+			}
+
+			// Assert that the transaction's receipt has
+			// been indexed, if we're indexing receipts
+			if testPoller.IndexReceipts {
+				receipt, err := testPoller.Store.GetReceiptByTransactionHash(transaction.Hash().Hex())
+				if err != nil {
+					return err
+				}
+
+				if receipt.BlockHash != block.Hash().Hex() {
+					return errors.New(fmt.Sprintf("Receipt for transaction %s is not from block at depth %d", transaction.Hash().Hex(), i))
+				}
+			}
 		}
 
-		currentBlockModel, err = testPoller.DB.GetBlockByHash(currentBlockModel.ParentHash)
+		// Assert that the block's withdrawals have been
+		// indexed
+		withdrawalModels, err := testPoller.Store.GetWithdrawalsForBlockHash(block.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(withdrawalModels) != len(block.Withdrawals()) {
+			return errors.New(fmt.Sprintf("Block at depth %d has %d withdrawals, expected %d", i, len(withdrawalModels), len(block.Withdrawals())))
+		}
+
+		// Assert that the block's uncles have been indexed
+		uncleModels, err := testPoller.Store.GetUnclesForBlockHash(block.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(uncleModels) != len(block.Uncles()) {
+			return errors.New(fmt.Sprintf("Block at depth %d has %d uncles, expected %d", i, len(uncleModels), len(block.Uncles())))
+		}
+
+		currentBlockModel, err = testPoller.Store.GetBlockByHash(currentBlockModel.ParentHash)
 		if err != nil {
 			break
 		}
 	}
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if errors.Is(err, models.ErrRecordNotFound) {
 		// Last block checked (first block indexed) should
 		// have no indexed parent
 		return nil
@@ -131,7 +170,7 @@ func AssertCanonicalBlocks(testPoller *poller.Poller, blocks []types.Block) erro
 func AssertOrphanedBlocks(testPoller *poller.Poller, orphanedBlocks []types.Block) error {
 	if len(orphanedBlocks) == 0 {
 		// Assert that there are no orphaned blocks
-		orphanedBlockModels, err := testPoller.DB.GetAllOrphanedBlocks()
+		orphanedBlockModels, err := testPoller.Store.GetAllOrphanedBlocks()
 		if err != nil {
 			return err
 		}
@@ -143,7 +182,7 @@ func AssertOrphanedBlocks(testPoller *poller.Poller, orphanedBlocks []types.Bloc
 		return nil
 	}
 
-	currentOrphanedBlockModel, err := testPoller.DB.GetOrphanedBlockByHash(orphanedBlocks[0].Hash().Hex())
+	currentOrphanedBlockModel, err := testPoller.Store.GetOrphanedBlockByHash(orphanedBlocks[0].Hash().Hex())
 	if err != nil {
 		return err
 	}
@@ -158,19 +197,41 @@ func AssertOrphanedBlocks(testPoller *poller.Poller, orphanedBlocks []types.Bloc
 		// Assert that the block's transactions have been
 		// indexed
 		for _, orphanedTransaction := range orphanedBlock.Transactions() {
-			_, err := testPoller.DB.GetOrphanedTransactionByHashAndBlockHash(orphanedTransaction.Hash().Hex(), orphanedBlock.Hash().Hex())
+			orphanedTransactionModel, err := testPoller.Store.GetOrphanedTransactionByHashAndBlockHash(orphanedTransaction.Hash().Hex(), orphanedBlock.Hash().Hex())
 			if err != nil {
 				return err
 			}
+
+			if len(orphanedTransactionModel.AccessList) != len(orphanedTransaction.AccessList()) {
+				return errors.New(fmt.Sprintf("Access list of orphaned transaction %s has %d tuples, expected %d", orphanedTransaction.Hash().Hex(), len(orphanedTransactionModel.AccessList), len(orphanedTransaction.AccessList())))
+			}
+		}
+
+		orphanedWithdrawalModels, err := testPoller.Store.GetOrphanedWithdrawalsForBlockHash(orphanedBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(orphanedWithdrawalModels) != len(orphanedBlock.Withdrawals()) {
+			return errors.New(fmt.Sprintf("Orphaned block at depth %d has %d withdrawals, expected %d", i, len(orphanedWithdrawalModels), len(orphanedBlock.Withdrawals())))
 		}
 
-		currentOrphanedBlockModel, err = testPoller.DB.GetOrphanedBlockByHash(currentOrphanedBlockModel.ParentHash)
+		orphanedUncleModels, err := testPoller.Store.GetOrphanedUnclesForBlockHash(orphanedBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(orphanedUncleModels) != len(orphanedBlock.Uncles()) {
+			return errors.New(fmt.Sprintf("Orphaned block at depth %d has %d uncles, expected %d", i, len(orphanedUncleModels), len(orphanedBlock.Uncles())))
+		}
+
+		currentOrphanedBlockModel, err = testPoller.Store.GetOrphanedBlockByHash(currentOrphanedBlockModel.ParentHash)
 		if err != nil {
 			break
 		}
 	}
 
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	if errors.Is(err, models.ErrRecordNotFound) {
 		// Last block checked (first block indexed) should
 		// have no indexed parent
 		return nil
@@ -189,7 +250,7 @@ func AssertBalance(testPoller *poller.Poller, balanceModel models.Balance, addre
 		return err
 	}
 
-	if balance.Cmp(balanceModel.Balance.Int) != 0 {
+	if balance.Cmp(models.NumericToBigInt(balanceModel.Balance)) != 0 {
 		return errors.New(fmt.Sprintf("%s's balance at block %s does not match", address, block.Hash().Hex()))
 	}
 
@@ -200,7 +261,7 @@ func AssertBalances(testPoller *poller.Poller, canonicalBlocks, orphanedBlocks [
 	var err error
 	for _, canonicalBlock := range canonicalBlocks {
 		for _, address := range testPoller.TrackedAddresses {
-			balanceModel, err := testPoller.DB.GetAddressBalanceByBlockHash(address, canonicalBlock.Hash().Hex())
+			balanceModel, err := testPoller.Store.GetAddressBalanceByBlockHash(address, canonicalBlock.Hash().Hex())
 			if err != nil {
 				return err
 			}
@@ -215,12 +276,12 @@ func AssertBalances(testPoller *poller.Poller, canonicalBlocks, orphanedBlocks [
 	for _, orphanedBlock := range orphanedBlocks {
 		orphanedBlockHash := orphanedBlock.Hash().Hex()
 		for _, address := range testPoller.TrackedAddresses {
-			_, err = testPoller.DB.GetAddressBalanceByBlockHash(address, orphanedBlockHash)
+			_, err = testPoller.Store.GetAddressBalanceByBlockHash(address, orphanedBlockHash)
 			if err == nil {
 				return errors.New(fmt.Sprintf("Found balance record for orphaned block %s", orphanedBlockHash))
 			}
 
-			if !errors.Is(err, gorm.ErrRecordNotFound) {
+			if !errors.Is(err, models.ErrRecordNotFound) {
 				return err
 			}
 		}
@@ -228,3 +289,150 @@ func AssertBalances(testPoller *poller.Poller, canonicalBlocks, orphanedBlocks [
 
 	return nil
 }
+
+// Asserts that the ERC-20 transfers among the logs of canonical blocks
+// have been indexed, and that none remain for orphaned blocks
+func AssertTokenTransfers(testPoller *poller.Poller, canonicalBlocks, orphanedBlocks []types.Block) error {
+	for _, canonicalBlock := range canonicalBlocks {
+		logModels, err := testPoller.Store.GetLogsForBlockHash(canonicalBlock.Hash().Hex(), nil)
+		if err != nil {
+			return err
+		}
+
+		expectedCount := 0
+		for i := range logModels {
+			tokenTransferModel, err := poller.MakeTokenTransferModel(&logModels[i])
+			if err != nil {
+				return err
+			}
+
+			if tokenTransferModel != nil {
+				expectedCount++
+			}
+		}
+
+		tokenTransferModels, err := testPoller.Store.GetTokenTransfersForBlockHash(canonicalBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(tokenTransferModels) != expectedCount {
+			return errors.New(fmt.Sprintf("Expected %d token transfers for block %s, found %d", expectedCount, canonicalBlock.Hash().Hex(), len(tokenTransferModels)))
+		}
+	}
+
+	for _, orphanedBlock := range orphanedBlocks {
+		tokenTransferModels, err := testPoller.Store.GetTokenTransfersForBlockHash(orphanedBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(tokenTransferModels) > 0 {
+			return errors.New(fmt.Sprintf("Found token transfers for orphaned block %s", orphanedBlock.Hash().Hex()))
+		}
+	}
+
+	return nil
+}
+
+// Asserts that the NFT transfers among the logs of canonical blocks
+// have been indexed, and that none remain for orphaned blocks
+func AssertNFTTransfers(testPoller *poller.Poller, canonicalBlocks, orphanedBlocks []types.Block) error {
+	for _, canonicalBlock := range canonicalBlocks {
+		logModels, err := testPoller.Store.GetLogsForBlockHash(canonicalBlock.Hash().Hex(), nil)
+		if err != nil {
+			return err
+		}
+
+		expectedCount := 0
+		for i := range logModels {
+			nftTransferModels, err := poller.MakeNFTTransferModels(&logModels[i])
+			if err != nil {
+				return err
+			}
+
+			expectedCount += len(nftTransferModels)
+		}
+
+		nftTransferModels, err := testPoller.Store.GetNFTTransfersForBlockHash(canonicalBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(nftTransferModels) != expectedCount {
+			return errors.New(fmt.Sprintf("Expected %d NFT transfers for block %s, found %d", expectedCount, canonicalBlock.Hash().Hex(), len(nftTransferModels)))
+		}
+	}
+
+	for _, orphanedBlock := range orphanedBlocks {
+		nftTransferModels, err := testPoller.Store.GetNFTTransfersForBlockHash(orphanedBlock.Hash().Hex())
+		if err != nil {
+			return err
+		}
+
+		if len(nftTransferModels) > 0 {
+			return errors.New(fmt.Sprintf("Found NFT transfers for orphaned block %s", orphanedBlock.Hash().Hex()))
+		}
+	}
+
+	return nil
+}
+
+var ErrInjectedFailure = errors.New("Injected failure")
+
+// Wraps a store, failing every insert into table with
+// ErrInjectedFailure
+type failingStore struct {
+	models.Store
+	table string
+}
+
+func (store *failingStore) Atomically(fn func(txStore models.Store) error) error {
+	return store.Store.Atomically(func(txStore models.Store) error {
+		return fn(&failingStore{Store: txStore, table: store.table})
+	})
+}
+
+func (store *failingStore) CreateBlock(block *models.Block) error {
+	if store.table == "blocks" {
+		return ErrInjectedFailure
+	}
+
+	return store.Store.CreateBlock(block)
+}
+
+func (store *failingStore) CreateOrphanedBlock(orphanedBlock *models.OrphanedBlock) error {
+	if store.table == "orphaned_blocks" {
+		return ErrInjectedFailure
+	}
+
+	return store.Store.CreateOrphanedBlock(orphanedBlock)
+}
+
+func (store *failingStore) CreateTransaction(transaction *models.Transaction) error {
+	if store.table == "transactions" {
+		return ErrInjectedFailure
+	}
+
+	return store.Store.CreateTransaction(transaction)
+}
+
+// Makes every subsequent insert by the poller into the given table
+// (one of blocks, orphaned_blocks or transactions) fail with
+// ErrInjectedFailure, until the returned function is called. Works
+// with any store
+func InjectCreateFailure(testPoller *poller.Poller, table string) (func() error, error) {
+	switch table {
+	case "blocks", "orphaned_blocks", "transactions":
+	default:
+		return nil, errors.New(fmt.Sprintf("Failure injection is not supported for table %s", table))
+	}
+
+	store := testPoller.Store
+	testPoller.Store = &failingStore{Store: store, table: table}
+
+	return func() error {
+		testPoller.Store = store
+		return nil
+	}, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -10,12 +11,23 @@ import (
 	"getherscan/pkg/poller"
 	"getherscan/pkg/test_utils"
 	"log"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/jackc/pgtype"
 )
 
 // NOTE: ALL TESTS ASSUME THEY ARE BEING RUN FROM /test DIR (IMPORTANT
@@ -24,19 +36,23 @@ import (
 var testPoller *poller.Poller
 var testAPIServer *api_server.APIServer
 
+// Set if tests run against saved data rather than a live RPC endpoint
+var testRPCServer *test_utils.MockRPCServer
+var testBalances test_utils.BalanceTable
+
 func TestMain(m *testing.M) {
 	var err error
 
 	wsRPCEndpoint := flag.String(
 		"ws-rpc-endpoint",
-		"wss://mainnet.infura.io/ws/v3/5b913333cf074541ac8566a9e91d807b",
-		"websocket RPC endpoint",
+		"",
+		"websocket RPC endpoint, a mock serving testdata is used if not set",
 	)
 
 	dbConnectionString := flag.String(
 		"db-connection-string",
-		"host=localhost port=5432 user=postgres password=12345 dbname=postgres sslmode=disable",
-		"database connection string",
+		models.MemoryStorePrefix,
+		"database connection string, in-memory by default",
 	)
 
 	// By default, we don't use any tracked addresses in testing,
@@ -44,13 +60,21 @@ func TestMain(m *testing.M) {
 	// endpoint to get address balances during indexing, and this
 	// fails if we're testing blocks further than 128 spots from
 	// the head of the current chain (our RPC endpoint doesn't
-	// have access to archival state)
+	// have access to archival state). Without an RPC endpoint, the
+	// balances have to be recorded beforehand (see
+	// test_utils.SaveBalances) and passed with the balances flag
 	trackedAddressesFilePath := flag.String(
 		"tracked-addresses",
 		"",
 		"path to JSON file with array of addresses to track",
 	)
 
+	balancesFilePath := flag.String(
+		"balances",
+		"",
+		"path to JSON file with recorded balances to serve if ws-rpc-endpoint isn't set",
+	)
+
 	port := flag.String(
 		"port",
 		"8000",
@@ -67,12 +91,55 @@ func TestMain(m *testing.M) {
 		}
 	}
 
+	// Without an RPC endpoint, we serve the saved testdata blocks
+	// (and recorded balances, if any) from a mock server
+	if *wsRPCEndpoint == "" {
+		testRPCServer, err = test_utils.NewMockRPCServer(big.NewInt(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if *balancesFilePath != "" {
+			testBalances, err = test_utils.GetBalancesFromFile(*balancesFilePath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		*wsRPCEndpoint = testRPCServer.URL
+	}
+
+	// The poller and API server refuse to start against a
+	// database that hasn't been migrated
+	if !strings.HasPrefix(*dbConnectionString, models.MemoryStorePrefix) {
+		db := new(models.DB)
+		err = db.Connect(*dbConnectionString)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		_, err = db.MigrateUp()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	testPoller = new(poller.Poller)
 	err = testPoller.Initialize(*wsRPCEndpoint, *dbConnectionString, trackedAddresses)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// The saved blocks all predate the merge, so reorgs between
+	// them are resolved by total difficulty
+	testPoller.ForkChoice = poller.TotalDifficultyForkChoice{}
+
+	// We don't have recorded receipts to serve, tests indexing the
+	// synthesized ones use receiptIndexingPoller
+	if testRPCServer != nil {
+		testPoller.IndexReceipts = false
+	}
+
 	testAPIServer = new(api_server.APIServer)
 	err = testAPIServer.Initialize(*dbConnectionString, *port)
 	if err != nil {
@@ -83,15 +150,78 @@ func TestMain(m *testing.M) {
 
 	exitCode := m.Run()
 
+	if testRPCServer != nil {
+		testRPCServer.Close()
+	}
+
 	os.Exit(exitCode)
 }
 
+// Reads the blocks for a test, and serves them from the mock RPC
+// server if we're using it
+func getBlocksFromDir(blocksDirPath string) ([]types.Block, error) {
+	blocks, err := test_utils.GetBlocksFromDir(blocksDirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if testRPCServer != nil {
+		testRPCServer.Reset()
+		testRPCServer.AddBlocks(blocks)
+		testRPCServer.SetBalances(testBalances)
+
+		receipts, err := test_utils.MakeTransferReceipts(blocks)
+		if err != nil {
+			return nil, err
+		}
+
+		testRPCServer.AddReceipts(receipts)
+	}
+
+	return blocks, nil
+}
+
+// Returns a copy of testPoller that indexes receipts if it can fetch
+// them. The mock RPC server has no recorded receipts, but serves
+// synthesized ones for the saved blocks (see getBlocksFromDir)
+func receiptIndexingPoller() *poller.Poller {
+	indexingPoller := *testPoller
+	if testRPCServer != nil {
+		indexingPoller.IndexReceipts = true
+	}
+
+	return &indexingPoller
+}
+
+// Builds a synthetic chain from description (see
+// test_utils.NewTestChain), and serves it from the mock RPC server
+// with canonicalHead as the node's head
+func serveTestChain(description, canonicalHead string) (*test_utils.TestChain, error) {
+	chain, err := test_utils.NewTestChain(description)
+	if err != nil {
+		return nil, err
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, canonicalHead)
+	if err != nil {
+		return nil, err
+	}
+
+	return chain, nil
+}
+
 func testPrologue() (bool, error) {
-	err := testPoller.DB.ClearDB()
+	err := testPoller.Store.ClearDB()
 	if err != nil {
 		return false, err
 	}
 
+	return isTrackedAddressesFlagSet(), nil
+}
+
+func isTrackedAddressesFlagSet() bool {
 	trackedAddressesFlagIsSet := false
 	flag.Visit(func(f *flag.Flag) {
 		if f.Name == "tracked-addresses" {
@@ -99,7 +229,15 @@ func testPrologue() (bool, error) {
 		}
 	})
 
-	return trackedAddressesFlagIsSet, nil
+	return trackedAddressesFlagIsSet
+}
+
+// Skips tests of synthetic blocks, which are only served by the mock
+// RPC server, and have no recorded balances
+func requireMockRPC(t *testing.T) {
+	if testRPCServer == nil || isTrackedAddressesFlagSet() {
+		t.Skip("Not using the mock RPC server")
+	}
 }
 
 func TestBasicIndexing(t *testing.T) {
@@ -110,12 +248,12 @@ func TestBasicIndexing(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/basic_test/basic_blocks")
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -162,20 +300,41 @@ func TestReorgIndexing(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/reorg_test/reorg_blocks")
+		blocks, err = getBlocksFromDir("testdata/reorg_test/reorg_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
 
-	err = test_utils.TestPoll(testPoller, blocks)
-	if err != nil {
-		t.Fatal(err)
+	// The node only serves receipts for its canonical chain, so
+	// the mock serves each block as it's indexed, as the node's
+	// head at the time
+	if testRPCServer != nil {
+		receipts, err := test_utils.MakeTransferReceipts(blocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testRPCServer.Reset()
+		testRPCServer.SetBalances(testBalances)
+		testRPCServer.AddReceipts(receipts)
+	}
+
+	indexingPoller := receiptIndexingPoller()
+	for i := range blocks {
+		if testRPCServer != nil {
+			testRPCServer.AddBlocks(blocks[i : i+1])
+		}
+
+		err = indexingPoller.Index(&blocks[i])
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
 
 	// Regardless of whether or not recent blocks are being used,
@@ -217,9 +376,21 @@ func TestReorgIndexing(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
+
+	if indexingPoller.IndexReceipts {
+		err = test_utils.AssertTokenTransfers(testPoller, canonicalBlocks, orphanedBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = test_utils.AssertNFTTransfers(testPoller, canonicalBlocks, orphanedBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
 }
 
-func TestOutOfOrderIndexing(t *testing.T) {
+func TestFailedIndexingIsRolledBack(t *testing.T) {
 	trackedAddressesFlagIsSet, err := testPrologue()
 	if err != nil {
 		t.Fatal(err)
@@ -227,55 +398,56 @@ func TestOutOfOrderIndexing(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/out_of_order_test/out_of_order_blocks")
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
 
-	err = test_utils.TestPoll(testPoller, blocks)
+	err = test_utils.TestPoll(testPoller, blocks[:1])
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Regardless of whether or not recent blocks are being used,
-	// assumes blocks are in reverse order and all canonical
-
-	canonicalBlocks := []types.Block{
-		blocks[1],
-		blocks[2],
-		blocks[3],
-		blocks[0],
+	// Fail after the block itself has been written, but before
+	// its transactions have
+	removeFailure, err := test_utils.InjectCreateFailure(testPoller, "transactions")
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	orphanedBlocks := []types.Block{}
+	err = testPoller.Index(&blocks[1])
+	if !errors.Is(err, test_utils.ErrInjectedFailure) {
+		removeFailure()
+		t.Fatal(fmt.Errorf("Expected injected failure, got %v", err))
+	}
 
-	err = test_utils.AssertCanonicalBlocks(testPoller, canonicalBlocks)
+	err = removeFailure()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	err = test_utils.AssertOrphanedBlocks(testPoller, orphanedBlocks)
+	isIndexed, err := testPoller.CheckIfIndexed(blocks[1].Hash().Hex())
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if trackedAddressesFlagIsSet {
-		err = test_utils.AssertBalances(testPoller, canonicalBlocks, orphanedBlocks)
-		if err != nil {
-			t.Fatal(err)
-		}
+	if isIndexed {
+		t.Fatal(errors.New("Partially indexed block was committed"))
 	}
-}
 
-// TODO: Test for multiple reorgs?
+	err = test_utils.AssertCanonicalBlocks(testPoller, []types.Block{blocks[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
 
-func TestGetHead(t *testing.T) {
+func TestFailedReorgIsRolledBack(t *testing.T) {
 	trackedAddressesFlagIsSet, err := testPrologue()
 	if err != nil {
 		t.Fatal(err)
@@ -283,88 +455,97 @@ func TestGetHead(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/basic_test/basic_blocks")
+		blocks, err = getBlocksFromDir("testdata/reorg_test/reorg_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
 
-	err = test_utils.TestPoll(testPoller, blocks)
+	// Assumes same ordering as in TestReorgIndexing
+
+	err = test_utils.TestPoll(testPoller, blocks[:3])
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	response, err := http.Get(fmt.Sprintf("http://localhost%s/getHead", testAPIServer.Server.Addr))
+	// Fail once the old head has been orphaned, when the new
+	// head is written
+	removeFailure, err := test_utils.InjectCreateFailure(testPoller, "blocks")
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer response.Body.Close()
 
-	var head models.Block
-	err = json.NewDecoder(response.Body).Decode(&head)
+	err = testPoller.Index(&blocks[3])
+	if !errors.Is(err, test_utils.ErrInjectedFailure) {
+		removeFailure()
+		t.Fatal(fmt.Errorf("Expected injected failure, got %v", err))
+	}
+
+	err = removeFailure()
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if head.Hash != blocks[len(blocks)-1].Hash().Hex() {
-		t.Fatal(errors.New("Incorrect head"))
+	// Nothing from the failed reorg should have been committed
+
+	err = test_utils.AssertCanonicalBlocks(testPoller, []types.Block{blocks[1], blocks[0]})
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestGetBlockByHash(t *testing.T) {
-	trackedAddressesFlagIsSet, err := testPrologue()
+	err = test_utils.AssertOrphanedBlocks(testPoller, []types.Block{blocks[2]})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	var blocks []types.Block
-	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
-		if err != nil {
-			t.Fatal(err)
-		}
-	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/basic_test/basic_blocks")
-		if err != nil {
-			t.Fatal(err)
-		}
+	isIndexed, err := testPoller.CheckIfIndexed(blocks[3].Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	err = test_utils.TestPoll(testPoller, blocks)
+	if isIndexed {
+		t.Fatal(errors.New("New head of failed reorg was committed"))
+	}
+
+	// Retrying the reorg should now succeed
+
+	err = testPoller.Index(&blocks[3])
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	block := blocks[rand.Intn(len(blocks))]
-	blockHash := block.Hash().Hex()
+	canonicalBlocks := []types.Block{
+		blocks[3],
+		blocks[2],
+		blocks[0],
+	}
 
-	response, err := http.Get(fmt.Sprintf(
-		"http://localhost%s/getBlockByHash/%s",
-		testAPIServer.Server.Addr,
-		blockHash,
-	))
+	orphanedBlocks := []types.Block{blocks[1]}
+
+	err = test_utils.AssertCanonicalBlocks(testPoller, canonicalBlocks)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer response.Body.Close()
 
-	var blockModel models.Block
-	err = json.NewDecoder(response.Body).Decode(&blockModel)
+	err = test_utils.AssertOrphanedBlocks(testPoller, orphanedBlocks)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if blockModel.Hash != blockHash {
-		t.Fatal(errors.New("Incorrect block"))
+	if trackedAddressesFlagIsSet {
+		err = test_utils.AssertBalances(testPoller, canonicalBlocks, orphanedBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
 }
 
-func TestGetBlockByNumber(t *testing.T) {
+func TestOutOfOrderIndexing(t *testing.T) {
 	trackedAddressesFlagIsSet, err := testPrologue()
 	if err != nil {
 		t.Fatal(err)
@@ -372,12 +553,12 @@ func TestGetBlockByNumber(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/basic_test/basic_blocks")
+		blocks, err = getBlocksFromDir("testdata/out_of_order_test/out_of_order_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -388,33 +569,37 @@ func TestGetBlockByNumber(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	block := blocks[rand.Intn(len(blocks))]
-	blockHash := block.Hash().Hex()
-	// NOTE: big.Int.String() will truncate trailing zeroes...
-	blockNumberString := block.Number().String()
+	// Regardless of whether or not recent blocks are being used,
+	// assumes blocks are in reverse order and all canonical
 
-	response, err := http.Get(fmt.Sprintf(
-		"http://localhost%s/getBlockByNumber/%s",
-		testAPIServer.Server.Addr,
-		blockNumberString,
-	))
+	canonicalBlocks := []types.Block{
+		blocks[1],
+		blocks[2],
+		blocks[3],
+		blocks[0],
+	}
+
+	orphanedBlocks := []types.Block{}
+
+	err = test_utils.AssertCanonicalBlocks(testPoller, canonicalBlocks)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer response.Body.Close()
 
-	var blockModel models.Block
-	err = json.NewDecoder(response.Body).Decode(&blockModel)
+	err = test_utils.AssertOrphanedBlocks(testPoller, orphanedBlocks)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if blockModel.Hash != blockHash {
-		t.Fatal(errors.New("Incorrect block"))
+	if trackedAddressesFlagIsSet {
+		err = test_utils.AssertBalances(testPoller, canonicalBlocks, orphanedBlocks)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
 }
 
-func TestGetBlocksByTransactionHash(t *testing.T) {
+func TestResumedBackfill(t *testing.T) {
 	trackedAddressesFlagIsSet, err := testPrologue()
 	if err != nil {
 		t.Fatal(err)
@@ -422,90 +607,2848 @@ func TestGetBlocksByTransactionHash(t *testing.T) {
 
 	var blocks []types.Block
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/reorg_test/reorg_blocks")
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
 
-	err = test_utils.TestPoll(testPoller, blocks)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	// Assumes same ordering as in TestReorgIndexing
+	// Assumes blocks are sequential and all canonical, as in
+	// TestBasicIndexing
 
-	// Find highest-fee transaction in canonical block, it should
-	// be present in orphaned block
-	block := blocks[2]
-	blockHash := block.Hash().Hex()
-	// Assumes proper indexing transactions (delegate the checking
-	// of this to TestReorgIndexing)
-	transaction, err := testPoller.DB.GetMostExpensiveTransactionForBlockHash(blockHash)
-	if err != nil {
-		t.Fatal(err)
-	}
+	fromBlock := blocks[0].NumberU64()
+	toBlock := blocks[len(blocks)-1].NumberU64()
 
-	response, err := http.Get(fmt.Sprintf(
-		"http://localhost%s/getBlocksByTransactionHash/%s",
-		testAPIServer.Server.Addr,
-		transaction.Hash,
-	))
+	// Pretend that a previous backfill over the same range was
+	// interrupted after indexing the first 2 blocks
+	err = testPoller.Store.SaveBackfillProgress(&models.BackfillProgress{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		NextBlock: fromBlock + 2,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer response.Body.Close()
 
-	var payload api_server.GetBlocksByTransactionHashPayload
-	err = json.NewDecoder(response.Body).Decode(&payload)
+	err = testPoller.Backfill(fromBlock, toBlock)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if payload.CanonicalBlock.Hash != blockHash {
-		t.Fatal(errors.New("Incorrect canonical block"))
+	canonicalBlocks := []types.Block{}
+	for i := len(blocks) - 1; i >= 2; i-- {
+		canonicalBlocks = append(canonicalBlocks, blocks[i])
 	}
 
-	if len(payload.OrphanedBlocks) == 0 {
-		t.Fatal(errors.New("No orphaned blocks"))
-	}
+	orphanedBlocks := []types.Block{}
 
-	if len(payload.OrphanedBlocks) > 1 {
-		t.Fatal(errors.New("Too many orphaned blocks"))
+	err = test_utils.AssertCanonicalBlocks(testPoller, canonicalBlocks)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if payload.OrphanedBlocks[0].Hash != blocks[1].Hash().Hex() {
-		t.Fatal(errors.New("Incorrect orphaned block"))
+	err = test_utils.AssertOrphanedBlocks(testPoller, orphanedBlocks)
+	if err != nil {
+		t.Fatal(err)
 	}
-}
 
-func TestGetTransactionByHash(t *testing.T) {
-	trackedAddressesFlagIsSet, err := testPrologue()
+	backfillProgress, err := testPoller.Store.GetBackfillProgress(fromBlock, toBlock)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	var blocks []types.Block
+	if backfillProgress.NextBlock != toBlock+1 {
+		t.Fatal(errors.New("Backfill progress not saved"))
+	}
+
 	if trackedAddressesFlagIsSet {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
-		if err != nil {
-			t.Fatal(err)
-		}
-	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/basic_test/basic_blocks")
+		err = test_utils.AssertBalances(testPoller, canonicalBlocks, orphanedBlocks)
 		if err != nil {
 			t.Fatal(err)
 		}
 	}
+}
 
-	err = test_utils.TestPoll(testPoller, blocks)
-	if err != nil {
-		t.Fatal(err)
+// Waits for the goroutines started since there were baseline of them
+// to exit
+func waitForGoroutines(baseline int) error {
+	for deadline := time.Now().Add(2 * time.Second); runtime.NumGoroutine() > baseline; {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%d goroutines still running", runtime.NumGoroutine()-baseline)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// Blocks are handled in order, workers stay within the prefetch limit,
+// and stop on the first error
+func TestFetchBlockRange(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C-D-E-F-G-H-I-J-K-L", "L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchingPoller := *testPoller
+	fetchingPoller.Concurrency = 2
+	fetchingPoller.PrefetchLimit = 3
+
+	fromBlock := blocks[0].NumberU64()
+	toBlock := blocks[len(blocks)-1].NumberU64()
+	baseline := runtime.NumGoroutine()
+
+	var handledBlocks []uint64
+	err = fetchingPoller.FetchBlockRange(fromBlock, toBlock, func(fetchedBlock *poller.FetchedBlock) error {
+		if len(handledBlocks) == 0 {
+			// Give the workers time to fetch as far ahead as
+			// they can
+			time.Sleep(200 * time.Millisecond)
+
+			requests := testRPCServer.BlockByNumberRequests()
+			if requests > 1+fetchingPoller.PrefetchLimit {
+				return fmt.Errorf("Fetched %d blocks while handling the first one", requests)
+			}
+		}
+
+		handledBlocks = append(handledBlocks, fetchedBlock.Block.NumberU64())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(handledBlocks) != len(blocks) {
+		t.Fatal(fmt.Errorf("Handled %d blocks instead of %d", len(handledBlocks), len(blocks)))
+	}
+
+	for i, blockNumber := range handledBlocks {
+		if blockNumber != fromBlock+uint64(i) {
+			t.Fatal(fmt.Errorf("Handled block %d in position %d", blockNumber, i))
+		}
+	}
+
+	err = waitForGoroutines(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The node doesn't have the blocks past L
+	handledBlocks = nil
+	err = fetchingPoller.FetchBlockRange(fromBlock, toBlock+10, func(fetchedBlock *poller.FetchedBlock) error {
+		handledBlocks = append(handledBlocks, fetchedBlock.Block.NumberU64())
+		return nil
+	})
+	if err == nil || len(handledBlocks) != len(blocks) {
+		t.Fatal(fmt.Errorf("Fetching past the head handled %d blocks and returned %v", len(handledBlocks), err))
+	}
+
+	err = waitForGoroutines(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handleErr := errors.New("Failed to handle block")
+	handledBlocks = nil
+	err = fetchingPoller.FetchBlockRange(fromBlock, toBlock, func(fetchedBlock *poller.FetchedBlock) error {
+		if len(handledBlocks) == 2 {
+			return handleErr
+		}
+
+		handledBlocks = append(handledBlocks, fetchedBlock.Block.NumberU64())
+		return nil
+	})
+	if !errors.Is(err, handleErr) || len(handledBlocks) != 2 {
+		t.Fatal(fmt.Errorf("Failing to handle a block handled %d blocks and returned %v", len(handledBlocks), err))
+	}
+
+	err = waitForGoroutines(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The fork overtakes the canonical chain 2 blocks after forking off
+func TestDeepReorg(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C, B-D-E-F", "F")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.Deliver(testPoller, "A", "B", "C", "D", "E", "F")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D-E-F", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Blocks arrive out of order and across forks, so the poller has to
+// fetch the missing ones
+func TestOutOfOrderFork(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C, B-D-E-F", "F")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.Deliver(testPoller, "A", "F", "C", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D-E-F", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// The chain reorgs onto a fork, then back onto the original chain,
+// whose orphaned blocks become canonical again
+func TestMultipleReorgs(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C, A-D-E-F, C-G-H-I", "I")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.Deliver(testPoller, "A", "B", "C", "D", "E", "F", "G", "H", "I")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-C-G-H-I", "D-E-F")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A shorter fork with more total difficulty wins
+func TestHeavierShorterFork(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C-D, B-E:5", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.Deliver(testPoller, "A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-E", "C-D")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Keeps every fork orphaned, so that fork choices can be checked on
+// their own against the indexed forks
+type neverReorgForkChoice struct{}
+
+func (forkChoice neverReorgForkChoice) ShouldReorg(poller *poller.Poller, head *models.Block, orphanedBlock *models.OrphanedBlock, canonicalAncestorHash string) (bool, error) {
+	return false, nil
+}
+
+func TestForkChoice(t *testing.T) {
+	requireMockRPC(t)
+
+	// Every fork branches off A, and the first branch is canonical
+	for _, expected := range []struct {
+		description     string
+		names           []string
+		totalDifficulty bool
+		longestChain    bool
+	}{
+		// Higher total difficulty, same length
+		{"A-B, A-C:2", []string{"A", "B", "C"}, true, true},
+		// Same total difficulty, shorter
+		{"A-B-C, A-D:2", []string{"A", "B", "C", "D"}, true, false},
+		// Same total difficulty, same length
+		{"A-B-C, A-D-E", []string{"A", "B", "C", "D", "E"}, false, true},
+		// Lower total difficulty, shorter
+		{"A-B-C, A-D", []string{"A", "B", "C", "D"}, false, false},
+		// Higher total difficulty, longer
+		{"A-B, A-C-D", []string{"A", "B", "C", "D"}, true, true},
+	} {
+		_, err := testPrologue()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chain, err := serveTestChain(expected.description, expected.names[len(expected.names)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		forkPoller := *testPoller
+		forkPoller.ForkChoice = neverReorgForkChoice{}
+
+		err = chain.Deliver(&forkPoller, expected.names...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		blocks, err := chain.Blocks("A", expected.names[len(expected.names)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		head, err := testPoller.Store.GetHead()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		orphanedBlock, err := testPoller.Store.GetOrphanedBlockByHash(blocks[1].Hash().Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, forkChoice := range []struct {
+			forkChoice  poller.ForkChoice
+			shouldReorg bool
+		}{
+			{poller.TotalDifficultyForkChoice{}, expected.totalDifficulty},
+			{poller.LongestChainForkChoice{}, expected.longestChain},
+		} {
+			shouldReorg, err := forkChoice.forkChoice.ShouldReorg(testPoller, head, orphanedBlock, blocks[0].Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if shouldReorg != forkChoice.shouldReorg {
+				t.Fatal(fmt.Errorf("%T decided to reorg (%t) incorrectly for %q", forkChoice.forkChoice, shouldReorg, expected.description))
+			}
+		}
+	}
+
+	for _, expected := range []struct {
+		chainID      *big.Int
+		proofOfStake bool
+	}{
+		{big.NewInt(1), true},
+		{big.NewInt(11155111), true},
+		{big.NewInt(1337), false},
+		{new(big.Int).Lsh(big.NewInt(1), 64), false},
+	} {
+		_, isLongestChain := poller.GetDefaultForkChoiceForChainID(expected.chainID).(poller.LongestChainForkChoice)
+		if isLongestChain != expected.proofOfStake {
+			t.Fatal(fmt.Errorf("Incorrect default fork choice for chain ID %s", expected.chainID))
+		}
+	}
+}
+
+// The safe head is marked even while the finalized head isn't indexed
+func TestUpdateFinality(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C-D-E", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Indexing starts after the finalized head, B
+	err = chain.Deliver(testPoller, "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []struct {
+		safe       int
+		finalized  int
+		finalities []string
+	}{
+		{3, 1, []string{models.FinalitySafe, models.FinalitySafe, models.FinalityUnsafe}},
+		{4, 2, []string{models.FinalityFinalized, models.FinalitySafe, models.FinalitySafe}},
+	} {
+		testRPCServer.SetFinality(blocks[expected.safe].Hash(), blocks[expected.finalized].Hash())
+
+		err = testPoller.UpdateFinality()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, finality := range expected.finalities {
+			blockModel, err := testPoller.Store.GetBlockByHash(blocks[2+i].Hash().Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if blockModel.Finality != finality {
+				t.Fatal(fmt.Errorf("Block %s is %s instead of %s", blockModel.Hash, blockModel.Finality, finality))
+			}
+		}
+	}
+}
+
+// Blob transactions and blob gas survive being orphaned and
+// canonicalized again
+func TestBlobTransactions(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewBlobTestChain("A-B-D:2, A-C:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// B is orphaned by C, then canonicalized by D
+	err = chain.Deliver(testPoller, "A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := chain.Block("B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getBlockByHash/%s",
+		testAPIServer.Server.Addr,
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var blockModel models.Block
+	err = json.NewDecoder(response.Body).Decode(&blockModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockModel.BlobGasUsed == nil || *blockModel.BlobGasUsed != *block.BlobGasUsed() ||
+		blockModel.ExcessBlobGas == nil || *blockModel.ExcessBlobGas != *block.ExcessBlobGas() {
+		t.Fatal(errors.New("Incorrect blob gas"))
+	}
+
+	transaction := block.Transactions()[0]
+
+	response, err = http.Get(fmt.Sprintf(
+		"http://localhost%s/getTransactionByHash/%s",
+		testAPIServer.Server.Addr,
+		transaction.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var transactionModel models.Transaction
+	err = json.NewDecoder(response.Body).Decode(&transactionModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transactionModel.Type != types.BlobTxType {
+		t.Fatal(fmt.Errorf("Transaction has type %d, expected %d", transactionModel.Type, types.BlobTxType))
+	}
+
+	if transactionModel.BlobGasFeeCap == nil || models.NumericToBigInt(*transactionModel.BlobGasFeeCap).Cmp(transaction.BlobGasFeeCap()) != 0 {
+		t.Fatal(errors.New("Incorrect blob gas fee cap"))
+	}
+
+	if len(transactionModel.BlobHashes) != 1 || transactionModel.BlobHashes[0] != transaction.BlobHashes()[0].Hex() {
+		t.Fatal(errors.New("Incorrect blob hashes"))
+	}
+
+	orphanedBlock, err := chain.Block("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphanedTransaction := orphanedBlock.Transactions()[0]
+	orphanedTransactionModel, err := testPoller.Store.GetOrphanedTransactionByHashAndBlockHash(orphanedTransaction.Hash().Hex(), orphanedBlock.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orphanedTransactionModel.BlobHashes) != 1 || orphanedTransactionModel.BlobHashes[0] != orphanedTransaction.BlobHashes()[0].Hex() {
+		t.Fatal(errors.New("Incorrect orphaned blob hashes"))
+	}
+}
+
+func TestWithdrawals(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewWithdrawalTestChain("A-B-C, B-D:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C is orphaned by D, taking its withdrawal along
+	err = chain.Deliver(testPoller, "A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := chain.Block("D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockModel, err := testPoller.Store.GetBlockByHash(block.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockModel.WithdrawalsRoot != block.Header().WithdrawalsHash.Hex() {
+		t.Fatal(errors.New("Incorrect withdrawals root"))
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getWithdrawalsByBlockHash/%s",
+		testAPIServer.Server.Addr,
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var withdrawalModels []models.Withdrawal
+	err = json.NewDecoder(response.Body).Decode(&withdrawalModels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withdrawal := block.Withdrawals()[0]
+	if len(withdrawalModels) != 1 ||
+		withdrawalModels[0].WithdrawalIndex != withdrawal.Index ||
+		withdrawalModels[0].ValidatorIndex != withdrawal.Validator ||
+		withdrawalModels[0].Address != withdrawal.Address.Hex() ||
+		withdrawalModels[0].Amount != withdrawal.Amount {
+		t.Fatal(errors.New("Incorrect withdrawals"))
+	}
+
+	// Orphaned blocks' withdrawals aren't served
+	orphanedBlock, err := chain.Block("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err = http.Get(fmt.Sprintf(
+		"http://localhost%s/getWithdrawalsByBlockHash/%s",
+		testAPIServer.Server.Addr,
+		orphanedBlock.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatal(fmt.Errorf("Fetching withdrawals of orphaned block returned status %d", response.StatusCode))
+	}
+
+	// Page through the withdrawals of canonical blocks A, B and D
+	canonicalBlocks, err := chain.Blocks("A", "B", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cursor := ""
+	withdrawalModels = []models.Withdrawal{}
+	for {
+		response, err = http.Get(fmt.Sprintf(
+			"http://localhost%s/getWithdrawalsByAddress/%s?limit=2&cursor=%s",
+			testAPIServer.Server.Addr,
+			test_utils.TestChainWithdrawalAddress.Hex(),
+			cursor,
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var payload api_server.GetWithdrawalsByAddressPayload
+		err = json.NewDecoder(response.Body).Decode(&payload)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		withdrawalModels = append(withdrawalModels, payload.Withdrawals...)
+
+		if payload.NextCursor == "" {
+			break
+		}
+
+		cursor = payload.NextCursor
+	}
+
+	if len(withdrawalModels) != len(canonicalBlocks) {
+		t.Fatal(fmt.Errorf("Fetched %d withdrawals, expected %d", len(withdrawalModels), len(canonicalBlocks)))
+	}
+
+	for i, canonicalBlock := range canonicalBlocks {
+		if withdrawalModels[i].BlockHash != canonicalBlock.Hash().Hex() {
+			t.Fatal(fmt.Errorf("Withdrawal %d is not from the expected block", i))
+		}
+	}
+
+	orphanedWithdrawalModels, err := testPoller.Store.GetOrphanedWithdrawalsForBlockHash(orphanedBlock.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orphanedWithdrawalModels) != 1 || orphanedWithdrawalModels[0].WithdrawalIndex != orphanedBlock.Withdrawals()[0].Index {
+		t.Fatal(errors.New("Incorrect orphaned withdrawals"))
+	}
+}
+
+func TestPreLondonBlocks(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewPreLondonTestChain("A-B-C, B-D:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receipts, err := test_utils.MakeTransferReceipts(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.AddReceipts(receipts)
+
+	preLondonPoller := *testPoller
+	preLondonPoller.IndexReceipts = true
+
+	// C is orphaned by D, so both canonical and orphaned blocks are
+	// stored without a base fee
+	err = chain.Deliver(&preLondonPoller, "A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockModel, err := testPoller.Store.GetBlockByHash(blocks[3].Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockModel.BaseFee != nil {
+		t.Fatal(errors.New("Pre-London block has a base fee"))
+	}
+
+	orphanedBlockModel, err := testPoller.Store.GetOrphanedBlockByHash(blocks[2].Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if orphanedBlockModel.BaseFee != nil {
+		t.Fatal(errors.New("Pre-London orphaned block has a base fee"))
+	}
+
+	// Legacy transactions pay their gas price
+	transaction := blocks[3].Transactions()[0]
+	receiptModel, err := testPoller.Store.GetReceiptByTransactionHash(transaction.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if models.NumericToBigInt(receiptModel.EffectiveGasPrice).Cmp(transaction.GasPrice()) != 0 {
+		t.Fatal(errors.New("Incorrect effective gas price"))
+	}
+
+	orphanedReceiptModels, err := testPoller.Store.GetOrphanedReceiptsForBlockHash(blocks[2].Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orphanedReceiptModels) != 1 ||
+		models.NumericToBigInt(orphanedReceiptModels[0].EffectiveGasPrice).Cmp(blocks[2].Transactions()[0].GasPrice()) != 0 {
+		t.Fatal(errors.New("Incorrect orphaned effective gas price"))
+	}
+
+	client, err := ethclient.Dial(fmt.Sprintf("http://localhost%s/rpc", testAPIServer.Server.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	// Serving a base fee would change the block's hash
+	block, err := client.BlockByHash(ctx, blocks[3].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash() != blocks[3].Hash() || block.BaseFee() != nil {
+		t.Fatal(errors.New("Incorrect block D"))
+	}
+
+	servedTransaction, _, err := client.TransactionByHash(ctx, transaction.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if servedTransaction.Hash() != transaction.Hash() || servedTransaction.Type() != types.LegacyTxType {
+		t.Fatal(errors.New("Incorrect transaction of D"))
+	}
+}
+
+func TestUncles(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C includes U as an uncle
+	chain, err := test_utils.NewTestChain("A-U, A-B-C^U, B-D:3, C-E:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C (and its uncle) is orphaned by D...
+	err = chain.Deliver(testPoller, "A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-D", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ... then canonicalized by E
+	err = chain.Deliver(testPoller, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-C-E", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := chain.Block("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uncle, err := chain.Block("U")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getUnclesByBlockHash/%s",
+		testAPIServer.Server.Addr,
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var uncleModels []models.Uncle
+	err = json.NewDecoder(response.Body).Decode(&uncleModels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uncleModels) != 1 ||
+		uncleModels[0].Hash != uncle.Hash().Hex() ||
+		uncleModels[0].ParentHash != uncle.ParentHash().Hex() ||
+		models.NumericToBigInt(uncleModels[0].Number).Cmp(uncle.Number()) != 0 ||
+		uncleModels[0].BaseFee == nil || models.NumericToBigInt(*uncleModels[0].BaseFee).Cmp(uncle.BaseFee()) != 0 {
+		t.Fatal(errors.New("Incorrect uncles"))
+	}
+
+	response, err = http.Get(fmt.Sprintf(
+		"http://localhost%s/getUncleByHash/%s",
+		testAPIServer.Server.Addr,
+		uncle.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var uncleModel models.Uncle
+	err = json.NewDecoder(response.Body).Decode(&uncleModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uncleModel.BlockHash != block.Hash().Hex() || uncleModel.UncleIndex != 0 {
+		t.Fatal(errors.New("Uncle is not linked to its including block"))
+	}
+}
+
+func TestInternalTransactions(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewTestChain("A-B-C, B-D:3, C-E:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonicalBlock, err := chain.Block("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphanedBlock, err := chain.Block("D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonicalTransaction := canonicalBlock.Transactions()[0]
+	orphanedTransaction := orphanedBlock.Transactions()[0]
+
+	// C's transaction calls a contract, which delegates to
+	// another, then creates a contract. D's transaction calls the
+	// same contract
+	contractAddress := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	libraryAddress := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	createdAddress := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	testRPCServer.AddTraces([]*poller.TransactionTrace{
+		{
+			TxHash: canonicalTransaction.Hash(),
+			Result: &poller.CallFrame{
+				Type: "CALL",
+				To:   canonicalTransaction.To(),
+				Calls: []*poller.CallFrame{
+					{
+						Type:  "CALL",
+						From:  *canonicalTransaction.To(),
+						To:    &contractAddress,
+						Value: (*hexutil.Big)(big.NewInt(5)),
+						Calls: []*poller.CallFrame{
+							{
+								Type: "DELEGATECALL",
+								From: contractAddress,
+								To:   &libraryAddress,
+							},
+						},
+					},
+					{
+						Type:  "CREATE",
+						From:  *canonicalTransaction.To(),
+						To:    &createdAddress,
+						Value: (*hexutil.Big)(big.NewInt(1)),
+					},
+				},
+			},
+		},
+		{
+			TxHash: orphanedTransaction.Hash(),
+			Result: &poller.CallFrame{
+				Type: "CALL",
+				To:   orphanedTransaction.To(),
+				Calls: []*poller.CallFrame{
+					{
+						Type:  "CALL",
+						From:  *orphanedTransaction.To(),
+						To:    &contractAddress,
+						Value: (*hexutil.Big)(big.NewInt(7)),
+					},
+				},
+			},
+		},
+	})
+
+	tracingPoller := *testPoller
+	tracingPoller.TraceInternalTransactions = true
+
+	// D is traced as canonical, C is indexed as an orphan without
+	// being traced, then E makes C canonical and orphans D
+	err = chain.Deliver(&tracingPoller, "A", "B", "D", "C", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(&tracingPoller, "A-B-C-E", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getInternalTransactions/%s",
+		testAPIServer.Server.Addr,
+		canonicalTransaction.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var internalTransactionModels []models.InternalTransaction
+	err = json.NewDecoder(response.Body).Decode(&internalTransactionModels)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedInternalTransactions := []struct {
+		traceAddress string
+		callType     string
+		to           common.Address
+		value        int64
+	}{
+		{"0", "CALL", contractAddress, 5},
+		{"0.0", "DELEGATECALL", libraryAddress, 0},
+		{"1", "CREATE", createdAddress, 1},
+	}
+
+	if len(internalTransactionModels) != len(expectedInternalTransactions) {
+		t.Fatal(fmt.Errorf("Fetched %d internal transactions, expected %d", len(internalTransactionModels), len(expectedInternalTransactions)))
+	}
+
+	for i, expected := range expectedInternalTransactions {
+		internalTransactionModel := internalTransactionModels[i]
+		if internalTransactionModel.TraceIndex != uint(i) ||
+			internalTransactionModel.TraceAddress != expected.traceAddress ||
+			internalTransactionModel.Type != expected.callType ||
+			internalTransactionModel.To != expected.to.Hex() ||
+			models.NumericToBigInt(internalTransactionModel.Value).Int64() != expected.value ||
+			internalTransactionModel.BlockHash != canonicalBlock.Hash().Hex() ||
+			internalTransactionModel.TransactionHash != canonicalTransaction.Hash().Hex() {
+			t.Fatal(fmt.Errorf("Incorrect internal transaction %d", i))
+		}
+	}
+
+	response, err = http.Get(fmt.Sprintf(
+		"http://localhost%s/getInternalTransactions/%s",
+		testAPIServer.Server.Addr,
+		orphanedTransaction.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatal(fmt.Errorf("Fetching internal transactions of orphaned transaction returned status %d", response.StatusCode))
+	}
+
+	orphanedInternalTransactionModels, err := testPoller.Store.GetOrphanedInternalTransactionsForBlockHash(orphanedBlock.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(orphanedInternalTransactionModels) != 1 || orphanedInternalTransactionModels[0].TransactionHash != orphanedTransaction.Hash().Hex() {
+		t.Fatal(errors.New("Incorrect orphaned internal transactions"))
+	}
+
+	// Page through the canonical internal transactions of the
+	// contract, which it either received or made
+	cursor := ""
+	internalTransactionModels = []models.InternalTransaction{}
+	for {
+		response, err = http.Get(fmt.Sprintf(
+			"http://localhost%s/getInternalTransactionsByAddress/%s?limit=1&cursor=%s",
+			testAPIServer.Server.Addr,
+			contractAddress.Hex(),
+			cursor,
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var payload api_server.GetInternalTransactionsByAddressPayload
+		err = json.NewDecoder(response.Body).Decode(&payload)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		internalTransactionModels = append(internalTransactionModels, payload.InternalTransactions...)
+
+		if payload.NextCursor == "" {
+			break
+		}
+
+		cursor = payload.NextCursor
+	}
+
+	if len(internalTransactionModels) != 2 ||
+		internalTransactionModels[0].TraceAddress != "0" ||
+		internalTransactionModels[1].TraceAddress != "0.0" {
+		t.Fatal(errors.New("Incorrect internal transactions by address"))
+	}
+}
+
+func TestBalanceDeltas(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Withdrawal test chains process a withdrawal in every block
+	chain, err := test_utils.NewWithdrawalTestChain("A-B-C, B-D:3, C-E:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonicalBlock, err := chain.Block("C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphanedBlock, err := chain.Block("D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C's transaction moves 10 wei from the sender to the
+	// recipient, D's transaction moves 1000
+	senderAddress := common.HexToAddress("0x0000000000000000000000000000000000000011")
+	recipientAddress := common.HexToAddress("0x0000000000000000000000000000000000000022")
+	makeStateDiff := func(transaction *types.Transaction, value int64) *poller.TransactionStateDiff {
+		return &poller.TransactionStateDiff{
+			TxHash: transaction.Hash(),
+			Result: &poller.StateDiff{
+				Pre: map[common.Address]*poller.AccountState{
+					senderAddress:    {Balance: (*hexutil.Big)(big.NewInt(2000))},
+					recipientAddress: {Balance: (*hexutil.Big)(big.NewInt(5))},
+				},
+				Post: map[common.Address]*poller.AccountState{
+					senderAddress:    {Balance: (*hexutil.Big)(big.NewInt(2000 - value))},
+					recipientAddress: {Balance: (*hexutil.Big)(big.NewInt(5 + value))},
+				},
+			},
+		}
+	}
+
+	testRPCServer.AddStateDiffs([]*poller.TransactionStateDiff{
+		makeStateDiff(canonicalBlock.Transactions()[0], 10),
+		makeStateDiff(orphanedBlock.Transactions()[0], 1000),
+	})
+
+	tracingPoller := *testPoller
+	tracingPoller.TraceBalanceDeltas = true
+
+	// D is traced as canonical, C is indexed as an orphan without
+	// being traced, then E makes C canonical and orphans D
+	err = chain.Deliver(&tracingPoller, "A", "B", "D", "C", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(&tracingPoller, "A-B-C-E", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getBalance := func(address common.Address, blockName string) (*big.Int, int, error) {
+		block, err := chain.Block(blockName)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		response, err := http.Get(fmt.Sprintf(
+			"http://localhost%s/getAddressBalanceByBlockHash/%s/%s",
+			testAPIServer.Server.Addr,
+			address.Hex(),
+			block.Hash().Hex(),
+		))
+		if err != nil {
+			return nil, 0, err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return nil, response.StatusCode, nil
+		}
+
+		var balanceModel models.Balance
+		err = json.NewDecoder(response.Body).Decode(&balanceModel)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return models.NumericToBigInt(balanceModel.Balance), response.StatusCode, nil
+	}
+
+	// Balances carry over blocks that don't touch the address
+	for _, expected := range []struct {
+		address   common.Address
+		blockName string
+		balance   int64
+	}{
+		{senderAddress, "C", 1990},
+		{senderAddress, "E", 1990},
+		{recipientAddress, "C", 15},
+		{recipientAddress, "E", 15},
+	} {
+		balance, statusCode, err := getBalance(expected.address, expected.blockName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if statusCode != http.StatusOK || balance.Cmp(big.NewInt(expected.balance)) != 0 {
+			t.Fatal(fmt.Errorf("Incorrect balance of %s at block %s", expected.address.Hex(), expected.blockName))
+		}
+	}
+
+	// Nothing is known about the recipient before C
+	_, statusCode, err := getBalance(recipientAddress, "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusBadRequest {
+		t.Fatal(fmt.Errorf("Fetching balance before any delta returned status %d", statusCode))
+	}
+
+	// The withdrawal address only receives withdrawals, which add
+	// up from 0
+	canonicalBlocks, err := chain.Blocks("A", "B", "C", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBalance := new(big.Int)
+	for _, block := range canonicalBlocks {
+		for _, withdrawal := range block.Withdrawals() {
+			expectedBalance.Add(expectedBalance, new(big.Int).Mul(new(big.Int).SetUint64(withdrawal.Amount), big.NewInt(1e9)))
+		}
+	}
+
+	balance, statusCode, err := getBalance(test_utils.TestChainWithdrawalAddress, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusOK || balance.Cmp(expectedBalance) != 0 {
+		t.Fatal(errors.New("Incorrect balance of withdrawal address"))
+	}
+
+	orphanedBalanceDeltaModels, err := testPoller.Store.GetOrphanedBalanceDeltasForBlockHash(orphanedBlock.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sender, the recipient and the withdrawal address
+	if len(orphanedBalanceDeltaModels) != 3 {
+		t.Fatal(fmt.Errorf("Got %d orphaned balance deltas, expected 3", len(orphanedBalanceDeltaModels)))
+	}
+}
+
+func TestTrackedAddresses(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewTestChain("A-B-C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Like a node without archival state, the node can't give the
+	// balance at A. The address is added in lowercase, but tracked
+	// in its checksummed form
+	address := "0x00000000000000000000000000000000000000aa"
+	checksummedAddress := common.HexToAddress(address).Hex()
+	testRPCServer.SetBalances(test_utils.BalanceTable{
+		address: {
+			blocks[1].NumberU64(): big.NewInt(200),
+			blocks[2].NumberU64(): big.NewInt(300),
+		},
+	})
+
+	trackingPoller := *testPoller
+	trackingPoller.TrackedAddresses = []string{}
+
+	err = chain.Deliver(&trackingPoller, "A", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminRequest := func(method, path string, authorization string) (int, error) {
+		request, err := http.NewRequest(
+			method,
+			fmt.Sprintf("http://localhost%s%s", testAPIServer.Server.Addr, path),
+			nil,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return 0, err
+		}
+		defer response.Body.Close()
+
+		return response.StatusCode, nil
+	}
+
+	addPath := fmt.Sprintf("/addTrackedAddress/%s?backfill=true", address)
+
+	// Admin endpoints are disabled without an admin token
+	statusCode, err := adminRequest("POST", addPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusForbidden {
+		t.Fatal(fmt.Errorf("Adding address with admin endpoints disabled returned status %d", statusCode))
+	}
+
+	testAPIServer.AdminToken = "test-admin-token"
+	defer func() { testAPIServer.AdminToken = "" }()
+
+	statusCode, err = adminRequest("POST", addPath, "Bearer wrong-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusUnauthorized {
+		t.Fatal(fmt.Errorf("Adding address with wrong admin token returned status %d", statusCode))
+	}
+
+	// The admin token has to be sent as a bearer token
+	statusCode, err = adminRequest("POST", addPath, testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusUnauthorized {
+		t.Fatal(fmt.Errorf("Adding address with admin token not as a bearer token returned status %d", statusCode))
+	}
+
+	statusCode, err = adminRequest("POST", addPath, "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusOK {
+		t.Fatal(fmt.Errorf("Adding address returned status %d", statusCode))
+	}
+
+	statusCode, err = adminRequest("POST", fmt.Sprintf("/addTrackedAddress/%s", checksummedAddress), "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusBadRequest {
+		t.Fatal(fmt.Errorf("Adding tracked address again returned status %d", statusCode))
+	}
+
+	// The poller picks the address up and backfills B, stopping at
+	// A, then fetches its balance at C as it's indexed
+	_, err = trackingPoller.LoadTrackedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = trackingPoller.BackfillTrackedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trackingPoller.TrackedAddresses) != 1 || trackingPoller.TrackedAddresses[0] != checksummedAddress {
+		t.Fatal(errors.New("Poller did not pick up added address"))
+	}
+
+	trackedAddress, err := trackingPoller.Store.GetTrackedAddress(checksummedAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trackedAddress.BackfillPending {
+		t.Fatal(errors.New("Backfill of added address still pending"))
+	}
+
+	err = chain.Deliver(&trackingPoller, "C")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expectedBalance := range []*big.Int{nil, big.NewInt(200), big.NewInt(300)} {
+		balance, err := trackingPoller.Store.GetAddressBalanceByBlockHash(checksummedAddress, blocks[i].Hash().Hex())
+		if expectedBalance == nil {
+			if !errors.Is(err, models.ErrRecordNotFound) {
+				t.Fatal(fmt.Errorf("Unexpected balance at block %d", blocks[i].NumberU64()))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if models.NumericToBigInt(balance.Balance).Cmp(expectedBalance) != 0 {
+			t.Fatal(fmt.Errorf("Incorrect balance at block %d", blocks[i].NumberU64()))
+		}
+	}
+
+	statusCode, err = adminRequest("POST", "/removeTrackedAddress/0x33", "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusBadRequest {
+		t.Fatal(fmt.Errorf("Removing invalid address returned status %d", statusCode))
+	}
+
+	statusCode, err = adminRequest("POST", fmt.Sprintf("/removeTrackedAddress/%s", address), "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusOK {
+		t.Fatal(fmt.Errorf("Removing address returned status %d", statusCode))
+	}
+
+	_, err = trackingPoller.LoadTrackedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trackingPoller.TrackedAddresses) != 0 {
+		t.Fatal(errors.New("Poller did not pick up removed address"))
+	}
+
+	// Removed addresses aren't tracked again from the tracked
+	// addresses file, e.g. when the poller restarts
+	err = trackingPoller.SeedTrackedAddresses([]string{address})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = trackingPoller.LoadTrackedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trackingPoller.TrackedAddresses) != 0 {
+		t.Fatal(errors.New("Seeding tracked removed address again"))
+	}
+
+	statusCode, err = adminRequest("POST", fmt.Sprintf("/removeTrackedAddress/%s", address), "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusNotFound {
+		t.Fatal(fmt.Errorf("Removing removed address returned status %d", statusCode))
+	}
+
+	statusCode, err = adminRequest("POST", "/removeTrackedAddress/0x0000000000000000000000000000000000000066", "Bearer "+testAPIServer.AdminToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statusCode != http.StatusNotFound {
+		t.Fatal(fmt.Errorf("Removing untracked address returned status %d", statusCode))
+	}
+
+	// Balances indexed while the address was tracked are kept
+	_, err = trackingPoller.Store.GetAddressBalanceByBlockHash(checksummedAddress, blocks[2].Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackedAddressBackfill(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewTestChain("A-B-C-D-E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boundedAddress := common.HexToAddress("0x0000000000000000000000000000000000000044").Hex()
+	resumedAddress := common.HexToAddress("0x0000000000000000000000000000000000000055").Hex()
+	balanceTable := test_utils.BalanceTable{}
+	for _, address := range []string{boundedAddress, resumedAddress} {
+		balanceTable[strings.ToLower(address)] = map[uint64]*big.Int{}
+		for _, block := range blocks {
+			balanceTable[strings.ToLower(address)][block.NumberU64()] = new(big.Int).SetUint64(100 * block.NumberU64())
+		}
+	}
+
+	testRPCServer.SetBalances(balanceTable)
+
+	trackingPoller := *testPoller
+	trackingPoller.TrackedAddresses = []string{}
+	trackingPoller.TrackedAddressesBackfillDepth = 2
+
+	err = chain.Deliver(&trackingPoller, "A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The bounded address's backfill starts from E and goes down
+	// to C. The resumed address's backfill was interrupted after
+	// C, so it picks up at B
+	err = trackingPoller.Store.CreateTrackedAddress(&models.TrackedAddress{
+		Address:         boundedAddress,
+		BackfillPending: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resumedNextBlock := blocks[1].NumberU64()
+	err = trackingPoller.Store.CreateTrackedAddress(&models.TrackedAddress{
+		Address:           resumedAddress,
+		BackfillPending:   true,
+		BackfillFromBlock: blocks[0].NumberU64(),
+		BackfillNextBlock: &resumedNextBlock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed request leaves the backfill pending, to be retried
+	// from where it left off
+	testRPCServer.FailBalanceRequests(1)
+
+	err = trackingPoller.BackfillTrackedAddresses()
+	if err == nil {
+		t.Fatal(errors.New("Failed balance request did not fail backfill"))
+	}
+
+	trackedAddress, err := trackingPoller.Store.GetTrackedAddress(boundedAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !trackedAddress.BackfillPending {
+		t.Fatal(errors.New("Failed balance request ended backfill"))
+	}
+
+	err = trackingPoller.BackfillTrackedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []struct {
+		address    string
+		backfilled []bool
+	}{
+		{boundedAddress, []bool{false, false, true, true, true}},
+		{resumedAddress, []bool{true, true, false, false, false}},
+	} {
+		trackedAddress, err := trackingPoller.Store.GetTrackedAddress(expected.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if trackedAddress.BackfillPending {
+			t.Fatal(fmt.Errorf("Backfill of %s still pending", expected.address))
+		}
+
+		for i, block := range blocks {
+			_, err := trackingPoller.Store.GetAddressBalanceByBlockHash(expected.address, block.Hash().Hex())
+			if expected.backfilled[i] && err != nil {
+				t.Fatal(fmt.Errorf("Balance of %s at block %d was not backfilled: %w", expected.address, block.NumberU64(), err))
+			}
+
+			if !expected.backfilled[i] && !errors.Is(err, models.ErrRecordNotFound) {
+				t.Fatal(fmt.Errorf("Unexpected balance of %s at block %d", expected.address, block.NumberU64()))
+			}
+		}
+	}
+}
+
+func TestAddressBalanceHistory(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewTestChain("A-B-C-D-E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := "0x0000000000000000000000000000000000000044"
+	testRPCServer.SetBalances(test_utils.BalanceTable{
+		address: {
+			blocks[2].NumberU64(): big.NewInt(300),
+			blocks[3].NumberU64(): big.NewInt(400),
+		},
+	})
+
+	// The address is only tracked while C and D are indexed
+	trackingPoller := *testPoller
+	trackingPoller.TrackedAddresses = []string{}
+
+	err = chain.Deliver(&trackingPoller, "A", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trackingPoller.TrackedAddresses = []string{address}
+
+	err = chain.Deliver(&trackingPoller, "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trackingPoller.TrackedAddresses = []string{}
+
+	err = chain.Deliver(&trackingPoller, "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getJSON := func(path string, payload interface{}) (int, error) {
+		response, err := http.Get(fmt.Sprintf("http://localhost%s%s", testAPIServer.Server.Addr, path))
+		if err != nil {
+			return 0, err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return response.StatusCode, nil
+		}
+
+		return response.StatusCode, json.NewDecoder(response.Body).Decode(payload)
+	}
+
+	// Balances carry forward to the blocks after the address
+	// stopped being tracked, but not back to those before
+	for _, expected := range []struct {
+		block             string
+		statusCode        int
+		balance           int64
+		balanceBlockIndex int
+	}{
+		{blocks[1].Number().String(), http.StatusBadRequest, 0, 0},
+		{blocks[2].Number().String(), http.StatusOK, 300, 2},
+		{blocks[3].Hash().Hex(), http.StatusOK, 400, 3},
+		{"latest", http.StatusOK, 400, 3},
+		{"", http.StatusOK, 400, 3},
+		{"unknown", http.StatusBadRequest, 0, 0},
+	} {
+		var payload api_server.GetAddressBalancePayload
+		statusCode, err := getJSON(fmt.Sprintf("/getAddressBalance/%s?block=%s", address, expected.block), &payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if statusCode != expected.statusCode {
+			t.Fatal(fmt.Errorf("Fetching balance at block %q returned status %d", expected.block, statusCode))
+		}
+
+		if statusCode != http.StatusOK {
+			continue
+		}
+
+		if models.NumericToBigInt(payload.Balance).Cmp(big.NewInt(expected.balance)) != 0 ||
+			payload.BalanceBlockHash != blocks[expected.balanceBlockIndex].Hash().Hex() {
+			t.Fatal(fmt.Errorf("Incorrect balance at block %q", expected.block))
+		}
+	}
+
+	// A balance delta at E is more recent than the balance tracked
+	// at D, but not than the one tracked at C or D themselves
+	deltaBalance := new(pgtype.Numeric)
+	err = deltaBalance.Set(450)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockNumber := new(pgtype.Numeric)
+	err = blockNumber.Set(blocks[4].NumberU64())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testPoller.Store.CreateBalanceDelta(&models.BalanceDelta{
+		Address:     address,
+		BlockHash:   blocks[4].Hash().Hex(),
+		BlockNumber: *blockNumber,
+		Delta:       *deltaBalance,
+		Balance:     *deltaBalance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []struct {
+		block             string
+		balance           int64
+		balanceBlockIndex int
+	}{
+		{blocks[3].Number().String(), 400, 3},
+		{"latest", 450, 4},
+	} {
+		var payload api_server.GetAddressBalancePayload
+		statusCode, err := getJSON(fmt.Sprintf("/getAddressBalance/%s?block=%s", address, expected.block), &payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if statusCode != http.StatusOK ||
+			models.NumericToBigInt(payload.Balance).Cmp(big.NewInt(expected.balance)) != 0 ||
+			payload.BalanceBlockHash != blocks[expected.balanceBlockIndex].Hash().Hex() {
+			t.Fatal(fmt.Errorf("Incorrect balance with deltas at block %q", expected.block))
+		}
+	}
+
+	// Starting from D, the series opens with the balance at C
+	var history api_server.GetAddressBalanceHistoryPayload
+	_, err = getJSON(fmt.Sprintf("/getAddressBalanceHistory/%s?fromBlock=%d", address, blocks[3].NumberU64()), &history)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if history.StartingBalance == nil || history.StartingBalance.BlockHash != blocks[2].Hash().Hex() ||
+		len(history.Balances) != 1 || history.Balances[0].BlockHash != blocks[3].Hash().Hex() {
+		t.Fatal(errors.New("Incorrect balance history from D"))
+	}
+
+	// Paginate through the whole series
+	var balances []models.Balance
+	cursor := ""
+	for {
+		var page api_server.GetAddressBalanceHistoryPayload
+		_, err = getJSON(fmt.Sprintf("/getAddressBalanceHistory/%s?limit=1&cursor=%s", address, cursor), &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if page.StartingBalance != nil {
+			t.Fatal(errors.New("Unexpected starting balance without fromBlock"))
+		}
+
+		balances = append(balances, page.Balances...)
+
+		cursor = page.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+
+	if len(balances) != 2 || balances[0].BlockHash != blocks[2].Hash().Hex() || balances[1].BlockHash != blocks[3].Hash().Hex() {
+		t.Fatal(errors.New("Incorrect paginated balance history"))
+	}
+}
+
+func TestRPC(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := test_utils.NewBlobTestChain("A-B-D:2, A-C:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.Reset()
+
+	err = chain.Serve(testRPCServer, "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every transaction emits a single log
+	var receipts []*types.Receipt
+	for _, block := range blocks {
+		for i, transaction := range block.Transactions() {
+			receipts = append(receipts, &types.Receipt{
+				Type:              transaction.Type(),
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: transaction.Gas(),
+				TxHash:            transaction.Hash(),
+				GasUsed:           transaction.Gas(),
+				BlockHash:         block.Hash(),
+				BlockNumber:       block.Number(),
+				TransactionIndex:  uint(i),
+				Logs: []*types.Log{{
+					Address:     common.HexToAddress("0x0000000000000000000000000000000000000045"),
+					Topics:      []common.Hash{common.HexToHash("0x01")},
+					Data:        []byte(block.Hash().Hex()),
+					BlockNumber: block.NumberU64(),
+					TxHash:      transaction.Hash(),
+					TxIndex:     uint(i),
+					BlockHash:   block.Hash(),
+				}},
+			})
+		}
+	}
+
+	testRPCServer.AddReceipts(receipts)
+
+	// Tracked in its checksummed form, like the poller loads it
+	address := "0x00000000000000000000000000000000000000ab"
+	testRPCServer.SetBalances(test_utils.BalanceTable{
+		address: {
+			blocks[0].NumberU64(): big.NewInt(100),
+			blocks[1].NumberU64(): big.NewInt(200),
+			blocks[3].NumberU64(): big.NewInt(400),
+		},
+	})
+
+	rpcPoller := *testPoller
+	rpcPoller.IndexReceipts = true
+	rpcPoller.TrackedAddresses = []string{common.HexToAddress(address).Hex()}
+
+	err = chain.Deliver(&rpcPoller, "A", "B", "C", "D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := ethclient.Dial(fmt.Sprintf("http://localhost%s/rpc", testAPIServer.Server.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+
+	blockNumber, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockNumber != blocks[3].NumberU64() {
+		t.Fatal(fmt.Errorf("Incorrect block number %d", blockNumber))
+	}
+
+	// Blocks hash back to their own hash, so every header field is
+	// served as the node served it
+	block, err := client.BlockByHash(ctx, blocks[1].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash() != blocks[1].Hash() || len(block.Transactions()) != 1 ||
+		block.Transactions()[0].Hash() != blocks[1].Transactions()[0].Hash() ||
+		len(block.Withdrawals()) != 1 {
+		t.Fatal(errors.New("Incorrect block B"))
+	}
+
+	block, err = client.BlockByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash() != blocks[3].Hash() {
+		t.Fatal(errors.New("Latest block isn't D"))
+	}
+
+	// C is orphaned, so isn't served
+	_, err = client.BlockByHash(ctx, blocks[2].Hash())
+	if !errors.Is(err, ethereum.NotFound) {
+		t.Fatal(fmt.Errorf("Fetching orphaned block C returned %v", err))
+	}
+
+	transaction, _, err := client.TransactionByHash(ctx, blocks[1].Transactions()[0].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transaction.Hash() != blocks[1].Transactions()[0].Hash() {
+		t.Fatal(errors.New("Incorrect transaction of B"))
+	}
+
+	_, _, err = client.TransactionByHash(ctx, blocks[2].Transactions()[0].Hash())
+	if !errors.Is(err, ethereum.NotFound) {
+		t.Fatal(fmt.Errorf("Fetching transaction of orphaned block C returned %v", err))
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, blocks[1].Transactions()[0].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if receipt.Status != types.ReceiptStatusSuccessful || receipt.BlockHash != blocks[1].Hash() ||
+		len(receipt.Logs) != 1 || receipt.Logs[0].Topics[0] != common.HexToHash("0x01") {
+		t.Fatal(errors.New("Incorrect receipt of B's transaction"))
+	}
+
+	balance, err := client.BalanceAt(ctx, common.HexToAddress(address), blocks[1].Number())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Cmp(big.NewInt(200)) != 0 {
+		t.Fatal(fmt.Errorf("Incorrect balance %s at B", balance))
+	}
+
+	balance, err = client.BalanceAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Cmp(big.NewInt(400)) != 0 {
+		t.Fatal(fmt.Errorf("Incorrect latest balance %s", balance))
+	}
+
+	// The balance of an address that was tracked at B, but whose
+	// last balance delta is from A, is the tracked one, as with
+	// /getAddressBalance
+	otherAddress := common.HexToAddress("0x00000000000000000000000000000000000000ac").Hex()
+	numerics := make([]*pgtype.Numeric, 4)
+	for i, value := range []*big.Int{blocks[0].Number(), blocks[1].Number(), big.NewInt(150), big.NewInt(250)} {
+		numerics[i], err = api_server.BigIntToNumeric(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = testPoller.Store.CreateBalanceDelta(&models.BalanceDelta{
+		Address:     otherAddress,
+		BlockHash:   blocks[0].Hash().Hex(),
+		BlockNumber: *numerics[0],
+		Delta:       *numerics[2],
+		Balance:     *numerics[2],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testPoller.Store.CreateBalance(&models.Balance{
+		Address:     otherAddress,
+		BlockHash:   blocks[1].Hash().Hex(),
+		BlockNumber: *numerics[1],
+		Balance:     *numerics[3],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err = client.BalanceAt(ctx, common.HexToAddress(otherAddress), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance.Cmp(big.NewInt(250)) != 0 {
+		t.Fatal(fmt.Errorf("Incorrect latest balance %s of address tracked after its last delta", balance))
+	}
+
+	// Untracked addresses have no balance to serve
+	_, err = client.BalanceAt(ctx, common.HexToAddress("0x0000000000000000000000000000000000000046"), nil)
+	if err == nil {
+		t.Fatal(errors.New("Fetched the balance of an untracked address"))
+	}
+}
+
+// PostgreSQL normalizes numerics, reading e.g. 1000000 back as 1e6
+func TestNumericToBigInt(t *testing.T) {
+	for _, expected := range []struct {
+		numeric pgtype.Numeric
+		value   int64
+	}{
+		{pgtype.Numeric{Int: big.NewInt(1000000), Status: pgtype.Present}, 1000000},
+		{pgtype.Numeric{Int: big.NewInt(1), Exp: 6, Status: pgtype.Present}, 1000000},
+		{pgtype.Numeric{Int: big.NewInt(-12345), Exp: 3, Status: pgtype.Present}, -12345000},
+		{pgtype.Numeric{Int: big.NewInt(123450), Exp: -1, Status: pgtype.Present}, 12345},
+		{pgtype.Numeric{Int: big.NewInt(123456), Exp: -2, Status: pgtype.Present}, 1234},
+		{pgtype.Numeric{Status: pgtype.Null}, 0},
+	} {
+		value := models.NumericToBigInt(expected.numeric)
+		if value.Cmp(big.NewInt(expected.value)) != 0 {
+			t.Fatal(fmt.Errorf("Converted %se%d to %s instead of %d", expected.numeric.Int, expected.numeric.Exp, value, expected.value))
+		}
+	}
+}
+
+// A difficulty with trailing zeros, which PostgreSQL stores with an
+// exponent, still outweighs the canonical fork
+func TestNormalizedDifficulty(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C-D, B-E:1000000", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.Deliver(testPoller, "A", "B", "C", "D", "E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = chain.AssertIndexed(testPoller, "A-B-E", "C-D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := chain.Block("E")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blockModel, err := testPoller.Store.GetBlockByHash(block.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if models.NumericToBigInt(blockModel.Difficulty).Cmp(big.NewInt(1000000)) != 0 {
+		t.Fatal(errors.New("Incorrect difficulty read back for E"))
+	}
+}
+
+// Numbers are compared and ordered as numbers, including across
+// digit counts (block 9 comes before block 10) and up to uint256
+func TestNumericOrdering(t *testing.T) {
+	requireMockRPC(t)
+
+	_, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := serveTestChain("A-B-C-D-E-F-G-H-I-J-K-L", "L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks, err := chain.Blocks("A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Balances count down from the largest uint256
+	address := "0x0000000000000000000000000000000000000047"
+	maxBalance := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	balanceTable := test_utils.BalanceTable{address: {}}
+	for _, block := range blocks {
+		balanceTable[address][block.NumberU64()] = new(big.Int).Sub(maxBalance, block.Number())
+	}
+
+	testRPCServer.SetBalances(balanceTable)
+
+	// Each block's transaction mints tokens and an NFT to the
+	// chain's sender, worth (and numbered) 1000 times the block
+	// number
+	receipts, err := test_utils.MakeTransferReceipts(blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRPCServer.AddReceipts(receipts)
+
+	trackingPoller := *testPoller
+	trackingPoller.TrackedAddresses = []string{address}
+	trackingPoller.IndexReceipts = true
+
+	err = chain.Deliver(&trackingPoller, "A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head, err := testPoller.Store.GetHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head.Hash != blocks[len(blocks)-1].Hash().Hex() {
+		t.Fatal(errors.New("Head isn't L"))
+	}
+
+	// Blocks 9 and up
+	fromBlock, err := api_server.BigIntToNumeric(big.NewInt(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	balances, err := testPoller.Store.GetAddressBalances(models.BalanceFilter{Address: address, FromBlock: fromBlock, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(balances) != len(blocks)-8 {
+		t.Fatal(fmt.Errorf("Got %d balances from block 9, expected %d", len(balances), len(blocks)-8))
+	}
+
+	for i, balance := range balances {
+		block := blocks[8+i]
+		if balance.BlockHash != block.Hash().Hex() ||
+			models.NumericToBigInt(balance.BlockNumber).Cmp(block.Number()) != 0 ||
+			models.NumericToBigInt(balance.Balance).Cmp(new(big.Int).Sub(maxBalance, block.Number())) != 0 {
+			t.Fatal(fmt.Errorf("Incorrect balance %d from block 9", i))
+		}
+	}
+
+	latestBalance, err := testPoller.Store.GetLatestAddressBalance(address, *fromBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latestBalance.BlockHash != blocks[8].Hash().Hex() {
+		t.Fatal(errors.New("Incorrect latest balance at block 9"))
+	}
+
+	toBlock, err := api_server.BigIntToNumeric(blocks[len(blocks)-1].Number())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logModels, err := testPoller.Store.GetLogs(models.LogFilter{FromBlock: *fromBlock, ToBlock: *toBlock, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logModels) != 2*(len(blocks)-8) {
+		t.Fatal(fmt.Errorf("Got %d logs from block 9, expected %d", len(logModels), 2*(len(blocks)-8)))
+	}
+
+	for i, logModel := range logModels {
+		if logModel.BlockHash != blocks[8+i/2].Hash().Hex() || logModel.LogIndex != uint(i%2) {
+			t.Fatal(fmt.Errorf("Incorrect log %d from block 9", i))
+		}
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(test_utils.TestChainID), blocks[0].Transactions()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedTokenBalance := new(big.Int)
+	for _, block := range blocks {
+		expectedTokenBalance.Add(expectedTokenBalance, new(big.Int).Mul(big.NewInt(1000*block.Number().Int64()), big.NewInt(params.Ether)))
+	}
+
+	tokenBalance, err := testPoller.Store.GetTokenBalance(test_utils.TestTokenAddress.Hex(), sender.Hex(), *toBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokenBalance.Cmp(expectedTokenBalance) != 0 {
+		t.Fatal(fmt.Errorf("Expected token balance %s, got %s", expectedTokenBalance.String(), tokenBalance.String()))
+	}
+
+	nftHoldings, err := testPoller.Store.GetNFTHoldingsForHolder(sender.Hex(), *toBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nftHoldings) != len(blocks) {
+		t.Fatal(fmt.Errorf("Got %d NFT holdings, expected %d", len(nftHoldings), len(blocks)))
+	}
+}
+
+// Runs the indexing, API and RPC tests again against a SQLite database,
+// whose numerics are stored as zero-padded text, unlike in the
+// in-memory store
+func TestSQLite(t *testing.T) {
+	memoryStore, ok := testPoller.Store.(*models.MemoryStore)
+	if !ok {
+		t.Skip("Already testing against a database")
+	}
+
+	db := new(models.DB)
+	err := db.Connect(models.SQLitePrefix + filepath.Join(t.TempDir(), "getherscan.db") + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	_, err = db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testPoller.Store = db
+	testAPIServer.Store = db
+	testAPIServer.EthService.Store = db
+	defer func() {
+		testPoller.Store = memoryStore
+		testAPIServer.Store = memoryStore
+		testAPIServer.EthService.Store = memoryStore
+	}()
+
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{"BasicIndexing", TestBasicIndexing},
+		{"ReorgIndexing", TestReorgIndexing},
+		{"FailedIndexingIsRolledBack", TestFailedIndexingIsRolledBack},
+		{"FailedReorgIsRolledBack", TestFailedReorgIsRolledBack},
+		{"OutOfOrderIndexing", TestOutOfOrderIndexing},
+		{"ResumedBackfill", TestResumedBackfill},
+		{"DeepReorg", TestDeepReorg},
+		{"OutOfOrderFork", TestOutOfOrderFork},
+		{"MultipleReorgs", TestMultipleReorgs},
+		{"HeavierShorterFork", TestHeavierShorterFork},
+		{"ForkChoice", TestForkChoice},
+		{"UpdateFinality", TestUpdateFinality},
+		{"BlobTransactions", TestBlobTransactions},
+		{"Withdrawals", TestWithdrawals},
+		{"PreLondonBlocks", TestPreLondonBlocks},
+		{"Uncles", TestUncles},
+		{"InternalTransactions", TestInternalTransactions},
+		{"BalanceDeltas", TestBalanceDeltas},
+		{"TrackedAddresses", TestTrackedAddresses},
+		{"TrackedAddressBackfill", TestTrackedAddressBackfill},
+		{"AddressBalanceHistory", TestAddressBalanceHistory},
+		{"NormalizedDifficulty", TestNormalizedDifficulty},
+		{"NumericOrdering", TestNumericOrdering},
+		{"RPC", TestRPC},
+		{"GetHead", TestGetHead},
+		{"GetFinalizedHead", TestGetFinalizedHead},
+		{"GetBlockByHash", TestGetBlockByHash},
+		{"GetBlockByNumber", TestGetBlockByNumber},
+		{"GetBlocksByTransactionHash", TestGetBlocksByTransactionHash},
+		{"GetTransactionByHash", TestGetTransactionByHash},
+		{"GetTransactionsByAddress", TestGetTransactionsByAddress},
+		{"GetTransactionReceipt", TestGetTransactionReceipt},
+		{"GetLogs", TestGetLogs},
+		{"GetTokenBalance", TestGetTokenBalance},
+		{"GetNFTOwners", TestGetNFTOwners},
+		{"GetAddressBalanceByBlockHash", TestGetAddressBalanceByBlockHash},
+	} {
+		t.Run(test.name, test.run)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	connectionString := models.SQLitePrefix + filepath.Join(t.TempDir(), "migrations.db")
+
+	db := new(models.DB)
+	err := db.Initialize(connectionString)
+	if !errors.Is(err, models.ErrUnexpectedSchemaVersion) {
+		t.Fatal(fmt.Errorf("Expected unexpected schema version, got %v", err))
+	}
+
+	appliedMigrations, err := db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(appliedMigrations) != len(models.Migrations) {
+		t.Fatal(fmt.Errorf("Applied %d migrations, expected %d", len(appliedMigrations), len(models.Migrations)))
+	}
+
+	err = db.CheckSchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating an up to date database does nothing
+	appliedMigrations, err = db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(appliedMigrations) != 0 {
+		t.Fatal(fmt.Errorf("Applied %d migrations to an up to date database", len(appliedMigrations)))
+	}
+
+	revertedMigrations, err := db.MigrateDown(len(models.Migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revertedMigrations) != len(models.Migrations) {
+		t.Fatal(fmt.Errorf("Reverted %d migrations, expected %d", len(revertedMigrations), len(models.Migrations)))
+	}
+
+	schemaVersion, err := db.GetSchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if schemaVersion != 0 {
+		t.Fatal(fmt.Errorf("Schema is at version %d after reverting every migration", schemaVersion))
+	}
+
+	if db.Migrator().HasTable("blocks") {
+		t.Fatal(errors.New("Reverting every migration left the blocks table behind"))
+	}
+}
+
+func TestGetHead(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf("http://localhost%s/getHead", testAPIServer.Server.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var head models.Block
+	err = json.NewDecoder(response.Body).Decode(&head)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if head.Hash != blocks[len(blocks)-1].Hash().Hex() {
+		t.Fatal(errors.New("Incorrect head"))
+	}
+}
+
+func TestGetFinalizedHead(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Finalize all but the last 2 blocks, and mark the
+	// second-to-last block as safe
+	finalizedBlock := blocks[len(blocks)-3]
+	safeBlock := blocks[len(blocks)-2]
+
+	finalizedBlockNumber := new(pgtype.Numeric)
+	err = finalizedBlockNumber.Set(finalizedBlock.Number().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testPoller.Store.FinalizeBlocksUpTo(*finalizedBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	safeBlockNumber := new(pgtype.Numeric)
+	err = safeBlockNumber.Set(safeBlock.Number().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testPoller.Store.MarkBlocksSafeUpTo(*safeBlockNumber)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf("http://localhost%s/getFinalizedHead", testAPIServer.Server.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var finalizedHead models.Block
+	err = json.NewDecoder(response.Body).Decode(&finalizedHead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if finalizedHead.Hash != finalizedBlock.Hash().Hex() {
+		t.Fatal(errors.New("Incorrect finalized head"))
+	}
+
+	response, err = http.Get(fmt.Sprintf("http://localhost%s/getSafeHead", testAPIServer.Server.Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var safeHead models.Block
+	err = json.NewDecoder(response.Body).Decode(&safeHead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if safeHead.Hash != safeBlock.Hash().Hex() {
+		t.Fatal(errors.New("Incorrect safe head"))
+	}
+}
+
+func TestGetBlockByHash(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	blockHash := block.Hash().Hex()
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getBlockByHash/%s",
+		testAPIServer.Server.Addr,
+		blockHash,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var blockModel models.Block
+	err = json.NewDecoder(response.Body).Decode(&blockModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockModel.Hash != blockHash {
+		t.Fatal(errors.New("Incorrect block"))
+	}
+}
+
+func TestGetBlockByNumber(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	blockHash := block.Hash().Hex()
+	// NOTE: big.Int.String() will truncate trailing zeroes...
+	blockNumberString := block.Number().String()
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getBlockByNumber/%s",
+		testAPIServer.Server.Addr,
+		blockNumberString,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var blockModel models.Block
+	err = json.NewDecoder(response.Body).Decode(&blockModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if blockModel.Hash != blockHash {
+		t.Fatal(errors.New("Incorrect block"))
+	}
+}
+
+func TestGetBlocksByTransactionHash(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/reorg_test/reorg_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Assumes same ordering as in TestReorgIndexing
+
+	// Find highest-fee transaction in canonical block, it should
+	// be present in orphaned block
+	block := blocks[2]
+	blockHash := block.Hash().Hex()
+	// Assumes proper indexing transactions (delegate the checking
+	// of this to TestReorgIndexing)
+	transaction, err := testPoller.Store.GetMostExpensiveTransactionForBlockHash(blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getBlocksByTransactionHash/%s",
+		testAPIServer.Server.Addr,
+		transaction.Hash,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var payload api_server.GetBlocksByTransactionHashPayload
+	err = json.NewDecoder(response.Body).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.CanonicalBlock.Hash != blockHash {
+		t.Fatal(errors.New("Incorrect canonical block"))
+	}
+
+	if len(payload.OrphanedBlocks) == 0 {
+		t.Fatal(errors.New("No orphaned blocks"))
+	}
+
+	if len(payload.OrphanedBlocks) > 1 {
+		t.Fatal(errors.New("Too many orphaned blocks"))
+	}
+
+	if payload.OrphanedBlocks[0].Hash != blocks[1].Hash().Hex() {
+		t.Fatal(errors.New("Incorrect orphaned block"))
+	}
+}
+
+func TestGetTransactionByHash(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	transactions := block.Transactions()
+	transaction := transactions[rand.Intn(len(transactions))]
+
+	// Prefer a transaction with an access list, if there is one
+	for _, block := range blocks {
+		for _, blockTransaction := range block.Transactions() {
+			if len(blockTransaction.AccessList()) > 0 {
+				transaction = blockTransaction
+			}
+		}
+	}
+
+	transactionHash := transaction.Hash().Hex()
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getTransactionByHash/%s",
+		testAPIServer.Server.Addr,
+		transactionHash,
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var transactionModel models.Transaction
+	err = json.NewDecoder(response.Body).Decode(&transactionModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if transactionModel.Hash != transactionHash {
+		t.Fatal(errors.New("Incorrect transaction"))
+	}
+
+	v, r, s := transaction.RawSignatureValues()
+	if models.NumericToBigInt(transactionModel.V).Cmp(v) != 0 ||
+		models.NumericToBigInt(transactionModel.R).Cmp(r) != 0 ||
+		models.NumericToBigInt(transactionModel.S).Cmp(s) != 0 {
+		t.Fatal(errors.New("Incorrect signature values"))
+	}
+
+	accessList := transaction.AccessList()
+	if len(transactionModel.AccessList) != len(accessList) {
+		t.Fatal(fmt.Errorf("Access list has %d tuples, expected %d", len(transactionModel.AccessList), len(accessList)))
+	}
+
+	for i, accessTuple := range accessList {
+		accessTupleModel := transactionModel.AccessList[i]
+		if accessTupleModel.Address != accessTuple.Address.Hex() || len(accessTupleModel.StorageKeys) != len(accessTuple.StorageKeys) {
+			t.Fatal(fmt.Errorf("Incorrect access list tuple %d", i))
+		}
+	}
+}
+
+func TestGetTransactionsByAddress(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(testPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	transactions := block.Transactions()
+	transaction := transactions[rand.Intn(len(transactions))]
+	transactionModel, err := testPoller.Store.GetTransactionByHash(transaction.Hash().Hex(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Page through the transactions sent by the transaction's
+	// sender a couple at a time
+
+	var fetchedTransactionModels []models.Transaction
+	cursor := ""
+	for {
+		response, err := http.Get(fmt.Sprintf(
+			"http://localhost%s/getTransactionsByAddress/%s?direction=out&limit=2&cursor=%s",
+			testAPIServer.Server.Addr,
+			transactionModel.From,
+			cursor,
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var payload api_server.GetTransactionsByAddressPayload
+		err = json.NewDecoder(response.Body).Decode(&payload)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(payload.Transactions) > 2 {
+			t.Fatal(errors.New("Limit not applied"))
+		}
+
+		fetchedTransactionModels = append(fetchedTransactionModels, payload.Transactions...)
+
+		if payload.NextCursor == "" {
+			break
+		}
+
+		cursor = payload.NextCursor
+	}
+
+	found := false
+	for i, fetchedTransactionModel := range fetchedTransactionModels {
+		if fetchedTransactionModel.From != transactionModel.From {
+			t.Fatal(errors.New("Transaction not sent by address"))
+		}
+
+		if i > 0 && models.NumericToBigInt(fetchedTransactionModel.Nonce).Cmp(models.NumericToBigInt(fetchedTransactionModels[i-1].Nonce)) <= 0 {
+			t.Fatal(errors.New("Transactions out of order"))
+		}
+
+		if fetchedTransactionModel.Hash == transactionModel.Hash {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatal(errors.New("Transaction missing from address's transactions"))
+	}
+}
+
+func TestGetTransactionReceipt(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexingPoller := receiptIndexingPoller()
+	if !indexingPoller.IndexReceipts {
+		t.Log("Not indexing receipts, skipping...")
+		return
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(indexingPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
 	}
 
 	block := blocks[rand.Intn(len(blocks))]
@@ -514,7 +3457,7 @@ func TestGetTransactionByHash(t *testing.T) {
 	transactionHash := transaction.Hash().Hex()
 
 	response, err := http.Get(fmt.Sprintf(
-		"http://localhost%s/getTransactionByHash/%s",
+		"http://localhost%s/getTransactionReceipt/%s",
 		testAPIServer.Server.Addr,
 		transactionHash,
 	))
@@ -523,15 +3466,336 @@ func TestGetTransactionByHash(t *testing.T) {
 	}
 	defer response.Body.Close()
 
-	var transactionModel models.Transaction
-	err = json.NewDecoder(response.Body).Decode(&transactionModel)
+	var receiptModel models.Receipt
+	err = json.NewDecoder(response.Body).Decode(&receiptModel)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if transactionModel.Hash != transactionHash {
-		t.Fatal(errors.New("Incorrect transaction"))
+	if receiptModel.TransactionHash != transactionHash {
+		t.Fatal(errors.New("Incorrect receipt"))
+	}
+
+	if receiptModel.BlockHash != block.Hash().Hex() {
+		t.Fatal(errors.New("Incorrect receipt block"))
+	}
+
+	for i, logModel := range receiptModel.Logs {
+		if logModel.TransactionHash != transactionHash {
+			t.Fatal(errors.New("Incorrect log"))
+		}
+
+		if i > 0 && logModel.LogIndex <= receiptModel.Logs[i-1].LogIndex {
+			t.Fatal(errors.New("Logs out of order"))
+		}
+	}
+}
+
+func TestGetLogs(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexingPoller := receiptIndexingPoller()
+	if !indexingPoller.IndexReceipts {
+		t.Log("Not indexing receipts, skipping...")
+		return
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(indexingPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	logModels, err := testPoller.Store.GetLogsForBlockHash(block.Hash().Hex(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logModels) == 0 {
+		t.Log("Block has no logs, skipping...")
+		return
+	}
+
+	// Page through the block's logs a few at a time, filtering
+	// on the first log's topic0
+
+	topic0 := logModels[0].Topic0
+	var expectedLogModels []models.Log
+	for _, logModel := range logModels {
+		if logModel.Topic0 == topic0 {
+			expectedLogModels = append(expectedLogModels, logModel)
+		}
+	}
+
+	var fetchedLogModels []models.Log
+	cursor := ""
+	for {
+		response, err := http.Get(fmt.Sprintf(
+			"http://localhost%s/getLogs?fromBlock=%s&toBlock=%s&topic0=%s&limit=2&cursor=%s",
+			testAPIServer.Server.Addr,
+			block.Number().String(),
+			block.Number().String(),
+			topic0,
+			cursor,
+		))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var payload api_server.GetLogsPayload
+		err = json.NewDecoder(response.Body).Decode(&payload)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(payload.Logs) > 2 {
+			t.Fatal(errors.New("Limit not applied"))
+		}
+
+		fetchedLogModels = append(fetchedLogModels, payload.Logs...)
+
+		if payload.NextCursor == "" {
+			break
+		}
+
+		cursor = payload.NextCursor
+	}
+
+	if len(fetchedLogModels) != len(expectedLogModels) {
+		t.Fatal(fmt.Errorf(
+			"Expected %d logs, got %d",
+			len(expectedLogModels),
+			len(fetchedLogModels),
+		))
+	}
+
+	for i, logModel := range fetchedLogModels {
+		if logModel.BlockHash != expectedLogModels[i].BlockHash || logModel.LogIndex != expectedLogModels[i].LogIndex {
+			t.Fatal(errors.New("Incorrect log"))
+		}
+	}
+}
+
+func TestGetTokenBalance(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexingPoller := receiptIndexingPoller()
+	if !indexingPoller.IndexReceipts {
+		t.Log("Not indexing receipts, skipping...")
+		return
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(indexingPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := blocks[rand.Intn(len(blocks))]
+	tokenTransferModels, err := testPoller.Store.GetTokenTransfersForBlockHash(block.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokenTransferModels) == 0 {
+		t.Log("Block has no token transfers, skipping...")
+		return
+	}
+
+	tokenTransferModel := tokenTransferModels[rand.Intn(len(tokenTransferModels))]
+	token := tokenTransferModel.Token
+	holder := tokenTransferModel.To
+
+	// Add up the holder's indexed transfers of the token up to
+	// the block
+
+	holderTokenTransferModels, err := testPoller.Store.GetTokenTransfers(models.TokenTransferFilter{
+		Address: holder,
+		Token:   token,
+		Limit:   -1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedBalance := new(big.Int)
+	for _, holderTokenTransferModel := range holderTokenTransferModels {
+		if models.NumericToBigInt(holderTokenTransferModel.BlockNumber).Cmp(block.Number()) > 0 {
+			break
+		}
+
+		value := models.NumericToBigInt(holderTokenTransferModel.Value)
+		if holderTokenTransferModel.From == holder {
+			expectedBalance.Sub(expectedBalance, value)
+		}
+
+		if holderTokenTransferModel.To == holder {
+			expectedBalance.Add(expectedBalance, value)
+		}
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getTokenBalance/%s/%s/%s",
+		testAPIServer.Server.Addr,
+		token,
+		holder,
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var payload api_server.GetTokenBalancePayload
+	err = json.NewDecoder(response.Body).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if models.NumericToBigInt(payload.Balance).Cmp(expectedBalance) != 0 {
+		t.Fatal(fmt.Errorf(
+			"Expected balance %s, got %s",
+			expectedBalance.String(),
+			models.NumericToBigInt(payload.Balance).String(),
+		))
+	}
+}
+
+func TestGetNFTOwners(t *testing.T) {
+	trackedAddressesFlagIsSet, err := testPrologue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexingPoller := receiptIndexingPoller()
+	if !indexingPoller.IndexReceipts {
+		t.Log("Not indexing receipts, skipping...")
+		return
+	}
+
+	var blocks []types.Block
+	if trackedAddressesFlagIsSet {
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		blocks, err = getBlocksFromDir("testdata/basic_test/basic_blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = test_utils.TestPoll(indexingPoller, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find the last ERC-721 transfer (that isn't a burn) in any of
+	// the blocks, its recipient must own the token at that block
+
+	var nftTransferModel *models.NFTTransfer
+	var block types.Block
+	for _, currentBlock := range blocks {
+		nftTransferModels, err := testPoller.Store.GetNFTTransfersForBlockHash(currentBlock.Hash().Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range nftTransferModels {
+			if nftTransferModels[i].Standard == models.NFTStandardERC721 && nftTransferModels[i].To != (common.Address{}).Hex() {
+				nftTransferModel = &nftTransferModels[i]
+				block = currentBlock
+			}
+		}
+
+		if nftTransferModel != nil {
+			break
+		}
 	}
+
+	if nftTransferModel == nil {
+		t.Log("Blocks have no ERC-721 transfers, skipping...")
+		return
+	}
+
+	response, err := http.Get(fmt.Sprintf(
+		"http://localhost%s/getNFTOwners/%s/%s/%s",
+		testAPIServer.Server.Addr,
+		nftTransferModel.Token,
+		models.NumericToBigInt(nftTransferModel.TokenID).String(),
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	var nftHoldings []models.NFTHolding
+	err = json.NewDecoder(response.Body).Decode(&nftHoldings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(nftHoldings) != 1 || nftHoldings[0].Holder != nftTransferModel.To {
+		t.Fatal(errors.New("Incorrect NFT owner"))
+	}
+
+	response, err = http.Get(fmt.Sprintf(
+		"http://localhost%s/getNFTsByOwner/%s/%s",
+		testAPIServer.Server.Addr,
+		nftTransferModel.To,
+		block.Hash().Hex(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	err = json.NewDecoder(response.Body).Decode(&nftHoldings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, nftHolding := range nftHoldings {
+		if nftHolding.Token == nftTransferModel.Token && models.NumericToBigInt(nftHolding.TokenID).Cmp(models.NumericToBigInt(nftTransferModel.TokenID)) == 0 {
+			return
+		}
+	}
+
+	t.Fatal(errors.New("NFT missing from owner's holdings"))
 }
 
 // Before running this test, make sure to use the save_blocks CLI
@@ -548,7 +3812,7 @@ func TestGetAddressBalanceByBlockHash(t *testing.T) {
 		t.Log("tracked-addresses flag not set, skipping...")
 		return
 	} else {
-		blocks, err = test_utils.GetBlocksFromDir("testdata/balance_test/recent_blocks")
+		blocks, err = getBlocksFromDir("testdata/balance_test/recent_blocks")
 		if err != nil {
 			t.Fatal(err)
 		}